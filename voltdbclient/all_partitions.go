@@ -0,0 +1,86 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2017 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+)
+
+// PartitionResponse is the response of one partition to a call made with
+// CallAllPartitions. Err is set when the call failed on that partition,
+// otherwise Rows holds the tables the partition returned.
+type PartitionResponse struct {
+	PartitionID int
+	// Key is the value of the partition parameter the call was routed to the
+	// partition with.
+	Key  int32
+	Rows driver.Rows
+	Err  error
+}
+
+// CallAllPartitions invokes the single partition procedure proc once on each
+// partition of the cluster and returns the response of every partition, in
+// the order @GetPartitionKeys lists them. proc must be partitioned on its
+// first parameter with an INTEGER column, the partition key routing each call
+// to its partition is passed as that parameter and args are the parameters
+// that follow it. The calls run concurrently.
+//
+// An error is returned when the partition keys can't be fetched, the calls
+// failing on some partitions are reported by their PartitionResponse.
+func (c *Conn) CallAllPartitions(ctx context.Context, proc string, args ...driver.Value) ([]PartitionResponse, error) {
+	resps, err := c.partitionKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var wg sync.WaitGroup
+	for i := range resps {
+		wg.Add(1)
+		go func(r *PartitionResponse) {
+			defer wg.Done()
+			params := append([]driver.Value{r.Key}, args...)
+			r.Rows, r.Err = c.CallContext(ctx, proc, params...)
+		}(&resps[i])
+	}
+	wg.Wait()
+	return resps, nil
+}
+
+// partitionKeys invokes @GetPartitionKeys INTEGER, it returns an INTEGER key
+// hashing to each of the partitions.
+func (c *Conn) partitionKeys(ctx context.Context) ([]PartitionResponse, error) {
+	rows, err := c.CallContext(ctx, "@GetPartitionKeys", "INTEGER")
+	if err != nil {
+		return nil, err
+	}
+	vr := rows.(VoltRows)
+	var keys []PartitionResponse
+	for vr.AdvanceRow() {
+		r := sysRow{rows: vr}
+		k := PartitionResponse{
+			PartitionID: int(r.int64("PARTITION_ID")),
+			Key:         int32(r.int64("PARTITION_KEY")),
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
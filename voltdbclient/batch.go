@@ -0,0 +1,103 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2017 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var errEmptyStatement = errors.New("voltdbclient: empty statement")
+
+// Batch accumulates SQL statements that are sent to the server in a single
+// @AdHoc invocation. The statements are executed as one transaction, when one
+// of them fails none of them takes effect.
+type Batch struct {
+	c     *Conn
+	stmts []string
+}
+
+// NewBatch returns an empty Batch executed on c.
+func (c *Conn) NewBatch() *Batch {
+	return &Batch{c: c}
+}
+
+// Add appends a statement to the batch, the statement can't take parameters.
+func (b *Batch) Add(stmt string) {
+	b.stmts = append(b.stmts, strings.TrimSuffix(strings.TrimSpace(stmt), ";"))
+}
+
+// Len returns the number of statements in the batch.
+func (b *Batch) Len() int {
+	return len(b.stmts)
+}
+
+// BatchError is returned when a statement of a Batch fails. Index is the
+// position of the statement in the batch, -1 when the server didn't tell which
+// statement failed.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e BatchError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("voltdbclient: batch failed: %v", e.Err)
+	}
+	return fmt.Sprintf("voltdbclient: statement %d of batch failed: %v", e.Index, e.Err)
+}
+
+// Exec sends the statements of the batch to the server and returns the
+// response of every statement, in the order they were added.
+func (b *Batch) Exec(ctx context.Context) ([]*Response, error) {
+	for i, stmt := range b.stmts {
+		if stmt == "" {
+			return nil, BatchError{Index: i, Err: errEmptyStatement}
+		}
+	}
+	resp, err := b.c.invokeContext(ctx, true, "@AdHoc", []driver.Value{strings.Join(b.stmts, ";\n") + ";"})
+	if err != nil {
+		if verr, ok := err.(VoltError); ok {
+			return nil, BatchError{Index: b.failedStatement(verr.StatusString()), Err: err}
+		}
+		return nil, err
+	}
+	rows := resp.(VoltRows)
+	if len(rows.tables) != len(b.stmts) {
+		return nil, fmt.Errorf("voltdbclient: batch of %d statements returned %d tables", len(b.stmts), len(rows.tables))
+	}
+	rsps := make([]*Response, len(rows.tables))
+	for i, t := range rows.tables {
+		rsps[i] = &Response{Rows: *newVoltRows(rows.voltResponse, []*voltTable{t})}
+	}
+	return rsps, nil
+}
+
+// failedStatement returns the index of the statement the server quotes in its
+// error message, or -1.
+func (b *Batch) failedStatement(msg string) int {
+	for i, stmt := range b.stmts {
+		if strings.Contains(msg, stmt) {
+			return i
+		}
+	}
+	return -1
+}
@@ -551,3 +551,82 @@ VALUES (?,?,?,?,?,?,?,?);`
 		rows.Close()
 	}
 }
+
+// a million row table of a BIGINT and a STRING column.
+func millionRowTable() []byte {
+	var rows [][]interface{}
+	for i := 0; i < 1000000; i++ {
+		rows = append(rows, []interface{}{int64(i), "a value of the row"})
+	}
+	return stubTable([]int8{wire.LongColumn, wire.StringColumn}, []string{"ID", "V"}, rows...)
+}
+
+func BenchmarkDecodeTableForRows(b *testing.B) {
+	table := millionRowTable()
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		vt, err := decodeTableForRows(wire.NewDecoder(bytes.NewReader(table)))
+		if err != nil {
+			b.Fatal(err)
+		}
+		vr := *newVoltRows(voltResponseInfo{numTables: 1}, []*voltTable{vt})
+		for vr.AdvanceRow() {
+			if _, err := vr.GetBigInt(0); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkRowStream(b *testing.B) {
+	table := millionRowTable()
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rs := newRowStream(voltResponseInfo{numTables: 1}, wire.NewDecoder(bytes.NewReader(table)))
+		for rs.Next() {
+			if _, err := rs.Row().GetBigInt(0); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := rs.Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodePI(b *testing.B) {
+	params := []driver.Value{
+		int64(1), int32(2), int16(3), int8(4), 5.5,
+		"volt", []byte{1, 2, 3}, time.Unix(1500000000, 0),
+		[]int32{1, 2, 3}, []string{"a", "b", "c"},
+	}
+	e := wire.NewEncoder()
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		e.Reset()
+		pi := newProcedureInvocationByHandle(int64(i), true, "proc", params)
+		if err := EncodePI(e, pi); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodePIScalars(b *testing.B) {
+	params := []driver.Value{
+		int64(1), "volt", 1.5, int64(2), "db", 2.5,
+		int64(3), "client", 3.5, int64(4),
+	}
+	e := wire.NewEncoder()
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		e.Reset()
+		pi := newProcedureInvocationByHandle(int64(i), true, "proc", params)
+		if err := EncodePI(e, pi); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
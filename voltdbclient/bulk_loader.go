@@ -0,0 +1,157 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2017 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"database/sql/driver"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBulkBatchSize is the number of rows a BulkLoader buffers before
+// sending them when no batch size is given.
+const DefaultBulkBatchSize = 200
+
+// BulkLoader inserts rows with an insert procedure, such as the TABLE.insert
+// procedure VoltDB creates for every table. The rows are buffered and sent in
+// batches, every row of a batch is invoked asynchronously so the batch is
+// pipelined to the servers and each row is routed to its partition.
+//
+// A BulkLoader is safe for concurrent use.
+type BulkLoader struct {
+	c         *Conn
+	proc      string
+	batchSize int
+	onFailure func(row []driver.Value, err error)
+
+	mu   sync.Mutex
+	rows [][]driver.Value
+
+	wg     sync.WaitGroup
+	loaded int64
+	failed int64
+}
+
+// NewBulkLoader returns a BulkLoader inserting rows with proc, batchSize rows
+// are buffered before they're sent, DefaultBulkBatchSize when it isn't
+// positive. onFailure is called with every row that failed to be inserted and
+// the error, it may be nil. onFailure is called from the goroutine serving the
+// connection and mustn't block.
+func (c *Conn) NewBulkLoader(proc string, batchSize int, onFailure func(row []driver.Value, err error)) *BulkLoader {
+	if batchSize <= 0 {
+		batchSize = DefaultBulkBatchSize
+	}
+	return &BulkLoader{
+		c:         c,
+		proc:      proc,
+		batchSize: batchSize,
+		onFailure: onFailure,
+		rows:      make([][]driver.Value, 0, batchSize),
+	}
+}
+
+// Insert buffers a row, the batch is sent once it's full.
+func (bl *BulkLoader) Insert(row ...driver.Value) error {
+	bl.mu.Lock()
+	bl.rows = append(bl.rows, row)
+	if len(bl.rows) < bl.batchSize {
+		bl.mu.Unlock()
+		return nil
+	}
+	rows := bl.takeRows()
+	bl.mu.Unlock()
+	return bl.send(rows)
+}
+
+// Flush sends the buffered rows without waiting for their responses.
+func (bl *BulkLoader) Flush() error {
+	bl.mu.Lock()
+	rows := bl.takeRows()
+	bl.mu.Unlock()
+	return bl.send(rows)
+}
+
+// Drain sends the buffered rows and waits until every row sent by the loader
+// is answered.
+func (bl *BulkLoader) Drain() error {
+	err := bl.Flush()
+	bl.wg.Wait()
+	return err
+}
+
+// Loaded returns the number of rows inserted so far.
+func (bl *BulkLoader) Loaded() int64 {
+	return atomic.LoadInt64(&bl.loaded)
+}
+
+// Failed returns the number of rows that failed to be inserted so far.
+func (bl *BulkLoader) Failed() int64 {
+	return atomic.LoadInt64(&bl.failed)
+}
+
+// takeRows returns the buffered rows and starts a new buffer, bl.mu is held.
+func (bl *BulkLoader) takeRows() [][]driver.Value {
+	rows := bl.rows
+	bl.rows = make([][]driver.Value, 0, bl.batchSize)
+	return rows
+}
+
+func (bl *BulkLoader) send(rows [][]driver.Value) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if bl.c.isClosed() {
+		for _, row := range rows {
+			bl.fail(row, errConnClosed)
+		}
+		return errConnClosed
+	}
+	bl.wg.Add(len(rows))
+	for _, row := range rows {
+		bl.c.ExecAsync(bulkRowConsumer{bl: bl, row: row}, bl.proc, row)
+	}
+	return nil
+}
+
+func (bl *BulkLoader) fail(row []driver.Value, err error) {
+	atomic.AddInt64(&bl.failed, 1)
+	if bl.onFailure != nil {
+		bl.onFailure(row, err)
+	}
+}
+
+// bulkRowConsumer consumes the response to the insert of a row.
+type bulkRowConsumer struct {
+	bl  *BulkLoader
+	row []driver.Value
+}
+
+func (rc bulkRowConsumer) ConsumeError(err error) {
+	rc.bl.fail(rc.row, err)
+	rc.bl.wg.Done()
+}
+
+func (rc bulkRowConsumer) ConsumeResult(res driver.Result) {
+	atomic.AddInt64(&rc.bl.loaded, 1)
+	rc.bl.wg.Done()
+}
+
+func (rc bulkRowConsumer) ConsumeRows(rows driver.Rows) {
+	atomic.AddInt64(&rc.bl.loaded, 1)
+	rc.bl.wg.Done()
+}
@@ -0,0 +1,128 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2017 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// CallCapError is returned by a call made when MaxConcurrentCalls or
+// MaxConcurrentProcCalls calls are outstanding already and FailAtCallCap is
+// set.
+type CallCapError struct {
+	// Proc is the procedure whose cap was reached, it is empty when the cap
+	// of the connection was reached.
+	Proc string
+	Max  int
+}
+
+func (e CallCapError) Error() string {
+	if e.Proc == "" {
+		return fmt.Sprintf("voltdbclient: too many outstanding requests, %d calls are in flight", e.Max)
+	}
+	return fmt.Sprintf("voltdbclient: too many outstanding requests, %d calls of %s are in flight", e.Max, e.Proc)
+}
+
+// callLimiter caps the number of calls outstanding over a connection, overall
+// and per procedure.
+type callLimiter struct {
+	max     int
+	procMax int
+	fail    bool
+	all     semaphore
+
+	mu    sync.Mutex
+	procs map[string]semaphore
+}
+
+// newCallLimiter returns the limiter for the caps in opts, or nil when there
+// is no cap.
+func newCallLimiter(opts ConnectOptions) *callLimiter {
+	if opts.MaxConcurrentCalls <= 0 && opts.MaxConcurrentProcCalls <= 0 {
+		return nil
+	}
+	l := &callLimiter{
+		max:     opts.MaxConcurrentCalls,
+		procMax: opts.MaxConcurrentProcCalls,
+		fail:    opts.FailAtCallCap,
+		procs:   make(map[string]semaphore),
+	}
+	if l.max > 0 {
+		l.all = make(semaphore, l.max)
+	}
+	return l
+}
+
+// acquire takes a permit for a call of proc, waiting for one until ctx is done
+// unless the limiter fails at the cap. The returned function gives the permit
+// back once the call is answered, it is nil when there is no limiter.
+func (l *callLimiter) acquire(ctx context.Context, proc string) (func(), error) {
+	if l == nil {
+		return nil, nil
+	}
+	var procSem semaphore
+	if l.procMax > 0 {
+		l.mu.Lock()
+		procSem = l.procs[proc]
+		if procSem == nil {
+			procSem = make(semaphore, l.procMax)
+			l.procs[proc] = procSem
+		}
+		l.mu.Unlock()
+		if err := l.take(ctx, procSem, CallCapError{Proc: proc, Max: l.procMax}); err != nil {
+			return nil, err
+		}
+	}
+	if l.all != nil {
+		if err := l.take(ctx, l.all, CallCapError{Max: l.max}); err != nil {
+			if procSem != nil {
+				<-procSem
+			}
+			return nil, err
+		}
+	}
+	return func() {
+		if l.all != nil {
+			<-l.all
+		}
+		if procSem != nil {
+			<-procSem
+		}
+	}, nil
+}
+
+// take takes a permit of sem, capErr is returned when there is none left and
+// the limiter fails at the cap.
+func (l *callLimiter) take(ctx context.Context, sem semaphore, capErr error) error {
+	if l.fail {
+		select {
+		case sem <- empty{}:
+			return nil
+		default:
+			return capErr
+		}
+	}
+	select {
+	case sem <- empty{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -22,6 +22,8 @@ import (
 	"encoding/json"
 	"errors"
 	"math/rand"
+	"strconv"
+	"strings"
 )
 
 var errLegacyHashinator = errors.New("Not support Legacy hashinator.")
@@ -49,36 +51,20 @@ func (c *Conn) getProcedureInfo(nc *nodeConn) <-chan voltResponse {
 	return responseCh
 }
 
-func (c *Conn) updateAffinityTopology(rows VoltRows) (hashinator, *map[int][]*nodeConn, error) {
+// updateAffinityTopology builds the hashinator and finds the connections to the
+// replicas and the master of every partition from the @Statistics TOPO
+// response. Hosts the client isn't connected to are left out.
+func (c *Conn) updateAffinityTopology(rows VoltRows, hostIDToConnection map[int]*nodeConn) (hashinator, *map[int][]*nodeConn, map[int]*nodeConn, error) {
 	if !rows.isValidTable() {
-		return nil, nil, errors.New("Not a validated topo statistic.")
+		return nil, nil, nil, errors.New("Not a validated topo statistic.")
 	}
 
-	if !rows.AdvanceTable() {
-		// Just in case the new client connects to the old version of Volt that only
-		// returns 1 topology table
-		return nil, nil, errLegacyHashinator
-	} else if !rows.AdvanceRow() { //Second table contains the hash function
-		return nil, nil, errors.New("Topology description received from Volt was incomplete " +
-			"performance will be lower because transactions can't be routed at this client")
-	}
-	hashType, hashTypeErr := rows.GetString(0)
-	panicIfnotNil("Error get hashtype ", hashTypeErr)
-	hashConfig, hashConfigErr := rows.GetVarbinary(1)
-	panicIfnotNil("Error get hashConfig ", hashConfigErr)
-	var hnator hashinator
-	var err error
-	switch hashType.(string) {
-	case Elastic:
-		configFormat := JSONFormat
-		cooked := true // json format is by default cooked
-		if hnator, err = newHashinatorElastic(configFormat, cooked, hashConfig.([]byte)); err != nil {
-			return nil, nil, err
-		}
-	default:
-		return nil, nil, errors.New("Not support Legacy hashinator.")
+	hnator, err := decodeHashinator(rows)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 	partitionReplicas := make(map[int][]*nodeConn)
+	partitionMasters := make(map[int]*nodeConn)
 
 	// First table contains the description of partition ids master/slave
 	// relationships
@@ -87,50 +73,80 @@ func (c *Conn) updateAffinityTopology(rows VoltRows) (hashinator, *map[int][]*no
 	// The MPI's partition ID is 16383 (MpInitiator.MPInitPID), so we shouldn't
 	// inadvertently hash to it. Go ahead and include it in the maps, we can use
 	// it at some point to route MP transactions directly to the MPI node.
-
-	// TODO GetXXXBYName seems broken
 	for rows.AdvanceRow() {
-		// partition, partitionErr := rows.GetBigIntByName("Partition")
 		partition, partitionErr := rows.GetInteger(0)
 		panicIfnotNil("Error get partition ", partitionErr)
-		// sites, sitesErr := rows.GetStringByName("Sites")
-		_, sitesErr := rows.GetString(1) //sites, sitesErr := rows.GetString(1)
+		sites, sitesErr := rows.GetString(1)
 		panicIfnotNil("Error get sites ", sitesErr)
+		leader, leaderErr := rows.GetString(2)
+		panicIfnotNil("Error get leader ", leaderErr)
 
+		// sites are listed as hostId:siteId separated by commas.
 		var connections []*nodeConn
-		//for _, site := range strings.Split(sites.(string), ",") {
-		//site = strings.TrimSpace(site)
-		////hostId, hostIdErr := strconv.Atoi(strings.Split(site, ":")[0])
-		//panicIfnotNil("Error get hostId", hostIdErr)
-		//if _, ok := c.hostIdToConnection[hostId]; ok {
-		//	connections = append(connections, c.hostIdToConnection[hostId])
-		//}
-		//}
+		for _, site := range strings.Split(sites.(string), ",") {
+			if nc, ok := hostIDToConnection[siteHostID(site)]; ok {
+				connections = append(connections, nc)
+			}
+		}
 		partitionReplicas[int(partition.(int32))] = connections
+		if nc, ok := hostIDToConnection[siteHostID(leader.(string))]; ok {
+			partitionMasters[int(partition.(int32))] = nc
+		}
+	}
+	return hnator, &partitionReplicas, partitionMasters, nil
+}
+
+// decodeHashinator builds the hashinator from the hash function in the second
+// table of the @Statistics TOPO response.
+func decodeHashinator(rows VoltRows) (hashinator, error) {
+	if !rows.AdvanceToTable(1) {
+		// Just in case the new client connects to the old version of Volt that only
+		// returns 1 topology table
+		return nil, errLegacyHashinator
+	} else if !rows.AdvanceRow() { //Second table contains the hash function
+		return nil, errors.New("Topology description received from Volt was incomplete " +
+			"performance will be lower because transactions can't be routed at this client")
+	}
+	hashType, err := rows.GetString(0)
+	if err != nil {
+		return nil, err
+	}
+	hashConfig, err := rows.GetVarbinary(1)
+	if err != nil {
+		return nil, err
+	}
+	if hashType.(string) != Elastic {
+		return nil, errors.New("Not support Legacy hashinator.")
+	}
+	configFormat := JSONFormat
+	cooked := true // json format is by default cooked
+	hnator, err := newHashinatorElastic(configFormat, cooked, hashConfig.([]byte))
+	if err != nil {
+		return nil, err
+	}
+	return hnator, nil
+}
 
-		// leaderHost, leaderHostErr := rows.GetStringByName("Leader")
-		//leaderHost, leaderHostErr := rows.GetString(2)
-		//panicIfnotNil("Error get leaderHost", leaderHostErr)
-		//leaderHostId, leaderHostIdErr := strconv.Atoi(strings.Split(leaderHost.(string), ":")[0])
-		//panicIfnotNil("Error get leaderHostId", leaderHostIdErr)
-		//if _, ok := c.hostIdToConnection[leaderHostId]; ok {
-		//	c.partitionMasters[int(partition.(int32))] = c.hostIdToConnection[leaderHostId]
-		//}
+// siteHostID returns the host id of a site given as hostId:siteId, -1 is
+// returned when site is malformed.
+func siteHostID(site string) int {
+	hostID, err := strconv.Atoi(strings.TrimSpace(strings.Split(site, ":")[0]))
+	if err != nil {
+		return -1
 	}
-	return hnator, &partitionReplicas, nil
+	return hostID
 }
 
 func (c *Conn) updateProcedurePartitioning(rows VoltRows) (*map[string]procedure, error) {
 	procedureInfos := make(map[string]procedure)
 	for rows.AdvanceRow() {
 		// proc information embedded in JSON object in remarks column
-		remarks, remarksErr := rows.GetVarbinary(6)
+		remarks, remarksErr := rows.GetString(6)
 		panicIfnotNil("Error get Remarks column", remarksErr)
 		procedureName, procedureNameErr := rows.GetString(2)
 		panicIfnotNil("Error get procedureName column", procedureNameErr)
 		proc := procedure{}
-		procErr := json.Unmarshal(remarks.([]byte), &proc)
-		// log.Println("remarks", string(remarks.([]byte)), "proc after unmarshal", proc)
+		procErr := json.Unmarshal([]byte(remarks.(string)), &proc)
 		panicIfnotNil("Error parse remarks ", procErr)
 		proc.setDefaults()
 		procedureInfos[procedureName.(string)] = proc
@@ -0,0 +1,102 @@
+package voltdbclient
+
+import (
+	"context"
+	"io/ioutil"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VoltDB/voltdb-client-go/wire"
+)
+
+// affinityHandler answers the system procedures the client invokes to set up
+// client affinity. The master of every partition in tp alternates between the
+// hosts 0 and 1, ECHO is partitioned on its first parameter.
+func affinityHandler(hashConfig []byte, tp Token2PartitionSlice) func(inv stubInvocation) []byte {
+	var topo [][]interface{}
+	seen := make(map[int]bool)
+	for _, p := range tp {
+		if seen[p.partition] {
+			continue
+		}
+		seen[p.partition] = true
+		site := strconv.Itoa(p.partition%2) + ":0"
+		topo = append(topo, []interface{}{int32(p.partition), site, site})
+	}
+	return func(inv stubInvocation) []byte {
+		switch inv.proc {
+		case "@Statistics":
+			return stubResponse(inv.handle,
+				stubTable([]int8{wire.IntColumn, wire.StringColumn, wire.StringColumn},
+					[]string{"Partition", "Sites", "Leader"}, topo...),
+				stubTable([]int8{wire.StringColumn, wire.VarBinColumn},
+					[]string{"HASHTYPE", "HASHCONFIG"}, []interface{}{Elastic, hashConfig}))
+		case "@SystemCatalog":
+			remarks := `{"singlePartition":true,"readOnly":false,"partitionParameter":0,"partitionParameterType":6}`
+			types := []int8{wire.StringColumn, wire.StringColumn, wire.StringColumn,
+				wire.StringColumn, wire.StringColumn, wire.StringColumn, wire.StringColumn}
+			names := []string{"PROCEDURE_CAT", "PROCEDURE_SCHEM", "PROCEDURE_NAME",
+				"RESERVED1", "RESERVED2", "RESERVED3", "REMARKS"}
+			return stubResponse(inv.handle, stubTable(types, names,
+				[]interface{}{"", "", "ECHO", "", "", "", remarks}))
+		}
+		return nil
+	}
+}
+
+func TestConn_ClientAffinity(t *testing.T) {
+	hashConfig, err := ioutil.ReadFile("./test_resources/jsonConfigC.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := newHashinatorElastic(JSONFormat, true, hashConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	system := affinityHandler(hashConfig, h.tp)
+
+	// last holds the id of the host that served the last invocation.
+	var last int32
+	var servers [2]*stubServer
+	for i := range servers {
+		hostID := int32(i)
+		servers[i] = newClusterStubServer(t, hostID, system, func(inv stubInvocation) []byte {
+			atomic.StoreInt32(&last, hostID)
+			return echoHandler(inv)
+		})
+		defer servers[i].close()
+	}
+	conn, err := OpenConn(servers[0].url() + "," + servers[1].url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// the topology is loaded in the background, until then invocations are
+	// spread round robin. Retry until a whole round lands on the masters.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		routed := true
+		for i := int64(0); i < 20; i++ {
+			partition, err := h.getHashedPartitionForParameter(6, i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := conn.CallContext(context.Background(), "ECHO", i); err != nil {
+				t.Fatal(err)
+			}
+			if host := atomic.LoadInt32(&last); host != int32(partition%2) {
+				routed = false
+			}
+		}
+		if routed {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected calls to be routed to the partition masters")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -28,7 +28,7 @@ import (
 
 /* TODO: fix CallOnClosedConn
 func CallOnClosedConn(t *testing.T) {
-	conn := newNodeConn("", nil)
+	conn := newNodeConn("", nil, ConnectOptions{})
 	pi := newSyncProcedureInvocation(0, true, "HELLOWORLD.select", []driver.Value{}, time.Minute*2)
 	_, err := conn.query(pi, func(int32) {})
 	if err == nil {
@@ -249,7 +249,7 @@ func checkRowData(t *testing.T, rows VoltRows, expectedID int32, nIDIsNull bool,
 		t.Fatal(err)
 	}
 	if iBalance != nil {
-		balance := iBalance.(*big.Float)
+		balance := iBalance.(*big.Rat)
 		fl, _ := balance.Float64()
 		if balanceIsNull || expectedBalance != fl {
 			t.Error(fmt.Printf("For BALANCE, expected value %f", expectedBalance))
@@ -0,0 +1,72 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2017 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import "github.com/VoltDB/voltdb-client-go/wire"
+
+// ColumnType is the type of a column of a table returned by VoltDB, as
+// reported by VoltRows.ColumnType and ColumnTypesTyped. Its String method
+// returns the SQL name of the type.
+type ColumnType int8
+
+// The column types.
+const (
+	TinyIntColumn        = ColumnType(wire.TinyIntColumn)
+	SmallIntColumn       = ColumnType(wire.ShortColumn)
+	IntegerColumn        = ColumnType(wire.IntColumn)
+	BigIntColumn         = ColumnType(wire.LongColumn)
+	FloatColumn          = ColumnType(wire.FloatColumn)
+	DecimalColumn        = ColumnType(wire.DecimalColumn)
+	VarcharColumn        = ColumnType(wire.StringColumn)
+	VarbinaryColumn      = ColumnType(wire.VarBinColumn)
+	TimestampColumn      = ColumnType(wire.TimestampColumn)
+	GeographyColumn      = ColumnType(wire.GeographyColumn)
+	GeographyPointColumn = ColumnType(wire.GeographyPointColumn)
+
+	// BoolColumn is the type booleans are stored as, VoltDB has no boolean
+	// type and stores them as TINYINT.
+	BoolColumn = TinyIntColumn
+)
+
+func (ct ColumnType) String() string {
+	switch ct {
+	case TinyIntColumn:
+		return "TINYINT"
+	case SmallIntColumn:
+		return "SMALLINT"
+	case IntegerColumn:
+		return "INTEGER"
+	case BigIntColumn:
+		return "BIGINT"
+	case FloatColumn:
+		return "FLOAT"
+	case DecimalColumn:
+		return "DECIMAL"
+	case VarcharColumn:
+		return "VARCHAR"
+	case VarbinaryColumn:
+		return "VARBINARY"
+	case TimestampColumn:
+		return "TIMESTAMP"
+	case GeographyColumn:
+		return "GEOGRAPHY"
+	case GeographyPointColumn:
+		return "GEOGRAPHY_POINT"
+	}
+	return "UNKNOWN"
+}
@@ -0,0 +1,114 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2017 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import "sync"
+
+// ConnEventType is the kind of a ConnEvent.
+type ConnEventType int
+
+// The kinds of connection events.
+const (
+	// ServerConnected means a server was connected to, when the connection
+	// was opened or later for a server that was down then.
+	ServerConnected ConnEventType = iota
+	// ServerLost means the connection to a server was lost, it is being
+	// reestablished following the ReconnectPolicy.
+	ServerLost
+	// ServerReconnected means the connection to a server was reestablished
+	// after it was lost.
+	ServerReconnected
+	// ServerGaveUp means the ReconnectPolicy gave up connecting to a server,
+	// the calls meant for it fail.
+	ServerGaveUp
+)
+
+func (t ConnEventType) String() string {
+	switch t {
+	case ServerConnected:
+		return "CONNECTED"
+	case ServerLost:
+		return "LOST"
+	case ServerReconnected:
+		return "RECONNECTED"
+	case ServerGaveUp:
+		return "GAVE_UP"
+	}
+	return "UNKNOWN"
+}
+
+// ConnEvent is a change of the state of the connection to a server.
+type ConnEvent struct {
+	Type ConnEventType
+
+	// Host is the host:port address of the server.
+	Host string
+
+	// Err is why the connection was lost for ServerLost events, it is nil
+	// otherwise.
+	Err error
+}
+
+// connNotifier hands the connection events to the OnConnEvent callback. The
+// events are queued so the goroutines serving the connections never wait for
+// the callback, they are delivered in order by a goroutine that runs while
+// the queue isn't empty.
+type connNotifier struct {
+	fn func(ConnEvent)
+
+	mu      sync.Mutex
+	queue   []ConnEvent
+	running bool
+}
+
+// newConnNotifier returns a notifier calling fn, or nil when fn is nil.
+func newConnNotifier(fn func(ConnEvent)) *connNotifier {
+	if fn == nil {
+		return nil
+	}
+	return &connNotifier{fn: fn}
+}
+
+// notify queues ev for the callback, a nil notifier drops it.
+func (n *connNotifier) notify(ev ConnEvent) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.queue = append(n.queue, ev)
+	if !n.running {
+		n.running = true
+		go n.deliver()
+	}
+}
+
+func (n *connNotifier) deliver() {
+	for {
+		n.mu.Lock()
+		if len(n.queue) == 0 {
+			n.running = false
+			n.mu.Unlock()
+			return
+		}
+		ev := n.queue[0]
+		n.queue = n.queue[1:]
+		n.mu.Unlock()
+		n.fn(ev)
+	}
+}
@@ -19,12 +19,17 @@ package voltdbclient
 
 import (
 	"database/sql/driver"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
+	"net"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/VoltDB/voltdb-client-go/wire"
 )
 
 const (
@@ -32,6 +37,8 @@ const (
 	DefaultQueryTimeout time.Duration = 2 * time.Minute
 )
 
+var errTxNotSupported = errors.New("voltdbclient: transactions are not supported, use a stored procedure instead")
+
 var handle int64
 var sHandle int64 = -1
 
@@ -40,7 +47,10 @@ var sHandle int64 = -1
 // prior to that use version 0.
 var ProtocolVersion = 1
 
-// Conn holds the set of currently active connections.
+// Conn holds the set of currently active connections. A Conn is safe for
+// concurrent use, the invocations are written to a server by a single
+// goroutine per connection and every response is matched to its invocation by
+// its handle.
 type Conn struct {
 	inPiCh                                   chan *procedureInvocation
 	allNcsPiCh                               chan *procedureInvocation
@@ -50,16 +60,49 @@ type Conn struct {
 	drainCh                                  chan chan bool
 	useClientAffinity                        bool
 	sendReadsToReplicasBytDefaultIfCAEnabled bool
+	opts                                     ConnectOptions
+	// nodes that connected after the connection was opened.
+	lateNcCh chan *nodeConn
+
+	// the parameters of the procedures, used by CallNamed and to check the
+	// parameters of calls. It's loaded when first needed.
+	catalogMu sync.Mutex
+	catalog   *ProcedureCatalog
+
+	stats *clientStats
+
+	// events delivers the connection events to opts.OnConnEvent, it is nil
+	// when there is no callback.
+	events *connNotifier
+
+	// calls caps the outstanding calls, it is nil when there is no cap.
+	calls *callLimiter
+
+	// the hashinator of the cluster, set by the loop once the topology is
+	// known and used by HashToPartition.
+	hnatorMu sync.Mutex
+	hnator   hashinator
+
+	closeOnce sync.Once
+
+	// the node connections of every server, connected or not.
+	ncsMu sync.Mutex
+	ncs   []*nodeConn
 }
 
-func newConn(cis []string) (*Conn, error) {
+func newConn(cis []string, opts ConnectOptions) (*Conn, error) {
 	var c = &Conn{
 		inPiCh:            make(chan *procedureInvocation, 1000),
 		allNcsPiCh:        make(chan *procedureInvocation, 1000),
 		closeCh:           make(chan chan bool),
 		rl:                newTxnLimiter(),
 		drainCh:           make(chan chan bool),
+		lateNcCh:          make(chan *nodeConn, len(cis)),
 		useClientAffinity: true,
+		opts:              opts,
+		stats:             newClientStats(),
+		events:            newConnNotifier(opts.OnConnEvent),
+		calls:             newCallLimiter(opts),
 	}
 	c.open.Store(true)
 
@@ -92,9 +135,13 @@ func newConn(cis []string) (*Conn, error) {
 //
 // You can omit the port,and the default port of 21212 will be automatically
 // added for you.
+//
+// Several servers of a cluster can be listed separated by commas. Invocations
+// are spread over the servers round robin, servers that are down when the
+// connection is opened are connected to once they come up.
 func OpenConn(ci string) (*Conn, error) {
 	cis := strings.Split(ci, ",")
-	return newConn(cis)
+	return newConn(cis, ConnectOptions{})
 }
 
 // OpenConnWithLatencyTarget returns a new connection to the VoltDB server.
@@ -102,7 +149,7 @@ func OpenConn(ci string) (*Conn, error) {
 // throttling the rate at which asynchronous transactions are submitted.
 func OpenConnWithLatencyTarget(ci string, latencyTarget int32) (*Conn, error) {
 	cis := strings.Split(ci, ",")
-	c, err := newConn(cis)
+	c, err := newConn(cis, ConnectOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -116,7 +163,7 @@ func OpenConnWithLatencyTarget(ci string, latencyTarget int32) (*Conn, error) {
 // the server but for which no response has been received.
 func OpenConnWithMaxOutstandingTxns(ci string, maxOutTxns int) (*Conn, error) {
 	cis := strings.Split(ci, ",")
-	c, err := newConn(cis)
+	c, err := newConn(cis, ConnectOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -134,7 +181,12 @@ func (c *Conn) start(cis []string) error {
 
 	for _, ci := range cis {
 		ncPiCh := make(chan *procedureInvocation, 1000)
-		nc := newNodeConn(ci, ncPiCh)
+		nc := newNodeConn(ci, ncPiCh, c.opts)
+		nc.stats = c.stats
+		nc.events = c.events
+		c.ncsMu.Lock()
+		c.ncs = append(c.ncs, nc)
+		c.ncsMu.Unlock()
 
 		if err = nc.connect(ProtocolVersion, c.allNcsPiCh); err != nil {
 			disconnected = append(disconnected, nc)
@@ -142,18 +194,67 @@ func (c *Conn) start(cis []string) error {
 		}
 		connected = append(connected, nc)
 		if c.useClientAffinity {
-			hostIDToConnection[int(nc.connData.HostID)] = nc
+			hostIDToConnection[int(nc.getConnData().HostID)] = nc
 		}
 	}
 
 	if len(connected) == 0 {
+		// the errors telling why connecting failed are returned as they are,
+		// so the reason can be inspected.
+		switch err.(type) {
+		case wire.AuthError, DialError, LoginError:
+			return err
+		}
 		return fmt.Errorf("No valid connections %v", err)
 	}
 
 	go c.loop(connected, disconnected, &hostIDToConnection)
+	for _, nc := range disconnected {
+		go c.connectLater(nc)
+	}
 	return nil
 }
 
+// connectLater keeps trying to connect to a server that was down when the
+// connection was opened, following the reconnect policy. Once connected the
+// node is handed to the loop.
+func (c *Conn) connectLater(nc *nodeConn) {
+	policy := c.opts.reconnectPolicy()
+	for attempt := 0; policy.MaxRetries < 0 || attempt < policy.MaxRetries; attempt++ {
+		time.Sleep(policy.delay(attempt))
+		if c.isClosed() {
+			return
+		}
+		if err := nc.connect(ProtocolVersion, c.allNcsPiCh); err != nil {
+			continue
+		}
+		if c.isClosed() {
+			<-nc.close()
+			return
+		}
+		c.lateNcCh <- nc
+		return
+	}
+	nc.setState(Disconnected)
+	nc.events.notify(ConnEvent{Type: ServerGaveUp, Host: nc.host()})
+}
+
+// submitRoundRobin hands pi to the next connected node that has room to queue
+// it, nodes are tried in round robin order starting at next. When every node is
+// backed up pi is left for the first node to free up.
+func (c *Conn) submitRoundRobin(connected []*nodeConn, next *int, pi *procedureInvocation) {
+	for i := range connected {
+		nc := connected[(*next+i)%len(connected)]
+		select {
+		case nc.ncPiCh <- pi:
+			*next = (*next + i + 1) % len(connected)
+			return
+		default:
+		}
+	}
+	c.allNcsPiCh <- pi
+}
+
 func (c *Conn) loop(connected []*nodeConn, disconnected []*nodeConn, hostIDToConnection *map[int]*nodeConn) {
 
 	// TODO: resubsribe when we lose the subscribed connection
@@ -179,6 +280,9 @@ func (c *Conn) loop(connected []*nodeConn, disconnected []*nodeConn, hostIDToCon
 		partitionMasters  = make(map[int]*nodeConn)
 
 		procedureInfos *map[string]procedure
+
+		// the next node to submit to when not using client affinity.
+		next int
 	)
 
 	for {
@@ -210,19 +314,20 @@ func (c *Conn) loop(connected []*nodeConn, disconnected []*nodeConn, hostIDToCon
 
 		select {
 		case closeRespCh = <-c.closeCh:
+			c.failQueued()
 			c.inPiCh = nil
 			c.allNcsPiCh = nil
 			c.drainCh = nil
 			c.closeCh = nil
 			if len(connected) == 0 {
 				closeRespCh <- true
-			} else {
-				outstandingCloseCount = len(connected)
-				closingNcsCh = make(chan bool, len(connected))
-				for _, connectedNc := range connected {
-					responseCh := connectedNc.close()
-					go func() { closingNcsCh <- <-responseCh }()
-				}
+				return
+			}
+			outstandingCloseCount = len(connected)
+			closingNcsCh = make(chan bool, len(connected))
+			for _, connectedNc := range connected {
+				responseCh := connectedNc.close()
+				go func() { closingNcsCh <- <-responseCh }()
 			}
 		case <-closingNcsCh:
 			outstandingCloseCount--
@@ -246,10 +351,12 @@ func (c *Conn) loop(connected []*nodeConn, disconnected []*nodeConn, hostIDToCon
 		case topoStatsResp := <-topoStatsCh:
 			switch topoStatsResp.(type) {
 			case VoltRows:
-				tmpHnator, tmpPartitionReplicas, err := c.updateAffinityTopology(topoStatsResp.(VoltRows))
+				tmpHnator, tmpPartitionReplicas, tmpPartitionMasters, err := c.updateAffinityTopology(topoStatsResp.(VoltRows), *hostIDToConnection)
 				if err == nil {
 					hnator = tmpHnator
+					c.setHashinator(tmpHnator)
 					partitionReplicas = tmpPartitionReplicas
+					partitionMasters = tmpPartitionMasters
 					topoStatsCh = nil
 				} else {
 					if err.Error() != errLegacyHashinator.Error() {
@@ -279,10 +386,15 @@ func (c *Conn) loop(connected []*nodeConn, disconnected []*nodeConn, hostIDToCon
 			if c.useClientAffinity && hnator != nil && partitionReplicas != nil && procedureInfos != nil {
 				nc, backpressure, err = c.getConnByCA(connected, hnator, &partitionMasters, partitionReplicas, procedureInfos, pi)
 			}
-			if err != nil && !backpressure && nc != nil {
+			if err == nil && !backpressure && nc != nil {
 				nc.submit(pi)
 			} else {
-				c.allNcsPiCh <- pi
+				c.submitRoundRobin(connected, &next, pi)
+			}
+		case nc := <-c.lateNcCh:
+			connected = append(connected, nc)
+			if c.useClientAffinity {
+				(*hostIDToConnection)[int(nc.getConnData().HostID)] = nc
 			}
 		case drainRespCh = <-c.drainCh:
 			if !draining {
@@ -313,22 +425,163 @@ func (c *Conn) loop(connected []*nodeConn, disconnected []*nodeConn, hostIDToCon
 	// check error channel to see if any lost connections.
 }
 
-// Begin starts a transaction.
+// Begin is not supported, every procedure invocation runs in its own
+// transaction on the VoltDB server. Begin always returns errTxNotSupported.
 func (c *Conn) Begin() (driver.Tx, error) {
-	return nil, nil
+	return nil, errTxNotSupported
 }
 
 // Close closes the connection to the VoltDB server.  Connections to the server
 // are meant to be long lived; it should not be necessary to continually close
 // and reopen connections.  Close would typically be called using a defer.
-// Operations using a closed connection cause a panic.
+// The calls that weren't answered yet fail with a connection closed error.
+// Calling Close more than once has no effect.
 func (c *Conn) Close() error {
-	respCh := make(chan bool)
-	c.closeCh <- respCh
-	<-respCh
+	c.closeOnce.Do(func() {
+		c.setClosed()
+		respCh := make(chan bool)
+		c.closeCh <- respCh
+		<-respCh
+	})
 	return nil
 }
 
+// ServerInfo describes a server a Conn logged in to, as reported by the server
+// in its login response.
+type ServerInfo struct {
+	// Address is the host:port of the server as given in the connection
+	// string, without the credentials it may hold.
+	Address string
+	HostID  int32
+	// ConnectionID identifies the connection on the server.
+	ConnectionID  int64
+	ClusterStart  time.Time
+	LeaderAddress net.IP
+	BuildString   string
+	// Version is parsed from BuildString, it's the zero value when the build
+	// string holds no version.
+	Version ServerVersion
+}
+
+// Servers returns the servers the connection logged in to, in the order they
+// appear in the connection string. Servers that couldn't be reached yet are
+// left out, the login response of the last login is reported for servers the
+// connection reconnected to.
+func (c *Conn) Servers() []ServerInfo {
+	c.ncsMu.Lock()
+	defer c.ncsMu.Unlock()
+	var servers []ServerInfo
+	for _, nc := range c.ncs {
+		ci := nc.getConnData()
+		if ci == nil {
+			continue
+		}
+		v, _ := nc.serverVersion()
+		servers = append(servers, ServerInfo{
+			Address:       nc.host(),
+			HostID:        ci.HostID,
+			ConnectionID:  ci.Connection,
+			ClusterStart:  ci.ClusterStart,
+			LeaderAddress: ci.LeaderAddr.IP,
+			BuildString:   ci.Build,
+			Version:       v,
+		})
+	}
+	return servers
+}
+
+// ConnectionID returns the id the first server the connection logged in to
+// gave the connection, it appears in the server logs. It is -1 when no server
+// was logged in to, Servers reports the ids of every server.
+func (c *Conn) ConnectionID() int64 {
+	servers := c.Servers()
+	if len(servers) == 0 {
+		return -1
+	}
+	return servers[0].ConnectionID
+}
+
+// HostID returns the host id of the first server the connection logged in to,
+// or -1 when no server was logged in to. Servers reports the ids of every
+// server.
+func (c *Conn) HostID() int32 {
+	servers := c.Servers()
+	if len(servers) == 0 {
+		return -1
+	}
+	return servers[0].HostID
+}
+
+// ConnState is the state of a connection to the servers.
+type ConnState int
+
+// The states of a connection.
+const (
+	// Connecting means no server is connected yet, a server that was down
+	// when the connection was opened is still being connected to.
+	Connecting ConnState = iota
+	// Connected means at least one server is connected.
+	Connected
+	// Reconnecting means the connections to the servers were lost and at
+	// least one of them is being reestablished.
+	Reconnecting
+	// Disconnected means the connection was closed or no server can be
+	// connected to anymore.
+	Disconnected
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case Connecting:
+		return "CONNECTING"
+	case Connected:
+		return "CONNECTED"
+	case Reconnecting:
+		return "RECONNECTING"
+	case Disconnected:
+		return "DISCONNECTED"
+	}
+	return "UNKNOWN"
+}
+
+// State returns the state of the connection. It is Connected as long as one
+// of the servers is connected, calls are spread over the connected servers.
+func (c *Conn) State() ConnState {
+	if c.isClosed() {
+		return Disconnected
+	}
+	c.ncsMu.Lock()
+	defer c.ncsMu.Unlock()
+	state := Disconnected
+	for _, nc := range c.ncs {
+		switch nc.getState() {
+		case Connected:
+			return Connected
+		case Reconnecting:
+			state = Reconnecting
+		case Connecting:
+			if state == Disconnected {
+				state = Connecting
+			}
+		}
+	}
+	return state
+}
+
+// failQueued fails the invocations that weren't handed to a node connection
+// when the connection is closed.
+func (c *Conn) failQueued() {
+	verr := connectionClosedError()
+	for {
+		select {
+		case pi := <-c.inPiCh:
+			failInvocation(pi, verr)
+		default:
+			return
+		}
+	}
+}
+
 // Drain blocks until all outstanding asynchronous requests have been satisfied.
 // Asynchronous requests are processed in a background thread; this call blocks
 // the current thread until that background thread has finished with all
@@ -0,0 +1,180 @@
+package voltdbclient
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConn_RoundRobin(t *testing.T) {
+	var counts [3]int32
+	var urls []string
+	for i := range counts {
+		count := &counts[i]
+		s := newStubServer(t, func(inv stubInvocation) []byte {
+			atomic.AddInt32(count, 1)
+			return echoHandler(inv)
+		})
+		defer s.close()
+		urls = append(urls, s.url())
+	}
+	conn, err := OpenConn(strings.Join(urls, ","))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 30; i++ {
+		if _, err := conn.CallContext(context.Background(), "ECHO", int64(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range counts {
+		if n := atomic.LoadInt32(&counts[i]); n != 10 {
+			t.Errorf("expected node %d to get 10 calls got %d", i, n)
+		}
+	}
+}
+
+func TestConn_ConnectLater(t *testing.T) {
+	var late int32
+	handler := func(inv stubInvocation) []byte {
+		atomic.StoreInt32(&late, 1)
+		return echoHandler(inv)
+	}
+	up := newStubServer(t, echoHandler)
+	defer up.close()
+	down := newStubServer(t, handler)
+	addr := down.addr()
+	down.close()
+
+	policy := &ReconnectPolicy{MaxRetries: -1, BaseDelay: 10 * time.Millisecond, MaxDelay: 20 * time.Millisecond}
+	conn, err := OpenConnWithOptions(up.url()+","+down.url(), ConnectOptions{ReconnectPolicy: policy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	down = newStubServerAt(t, addr, handler)
+	defer down.close()
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&late) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected calls to reach the server that came up late")
+		}
+		if _, err := conn.CallContext(context.Background(), "ECHO", int64(1)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestConn_CloseFailsOutstanding(t *testing.T) {
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		// SLOW is never answered.
+		return nil
+	})
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	inFlight := mustAsyncCall(t, conn, "SLOW")
+	// give the invocation time to be written before closing.
+	time.Sleep(50 * time.Millisecond)
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case rsp := <-inFlight:
+		verr, ok := rsp.Err.(VoltError)
+		if !ok || verr.error != errConnClosed {
+			t.Errorf("expected a connection closed error got %v", rsp.Err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the outstanding call didn't fail")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		conn.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("closing the connection twice blocked")
+	}
+	if _, err := conn.AsyncCall("SLOW"); err != errConnClosed {
+		t.Errorf("expected %v got %v", errConnClosed, err)
+	}
+}
+
+func TestConn_Servers(t *testing.T) {
+	var servers [2]*stubServer
+	for i := range servers {
+		servers[i] = newClusterStubServer(t, int32(i+3), nil, echoHandler)
+		defer servers[i].close()
+	}
+	conn, err := OpenConn(servers[0].url() + "," + servers[1].url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	infos := conn.Servers()
+	if len(infos) != 2 {
+		t.Fatalf("expected 2 servers got %d", len(infos))
+	}
+	for i, info := range infos {
+		if info.Address != servers[i].addr() {
+			t.Errorf("expected address %s got %s", servers[i].addr(), info.Address)
+		}
+		if info.HostID != int32(i+3) {
+			t.Errorf("expected host id %d got %d", i+3, info.HostID)
+		}
+		if info.ConnectionID != 1 || info.BuildString != "stub" {
+			t.Errorf("unexpected connection id %d or build %s", info.ConnectionID, info.BuildString)
+		}
+		if !info.LeaderAddress.Equal(net.IPv4(127, 0, 0, 1)) {
+			t.Errorf("expected leader 127.0.0.1 got %v", info.LeaderAddress)
+		}
+	}
+}
+
+func TestConn_ServersWithoutCredentials(t *testing.T) {
+	s := newStubServer(t, echoHandler)
+	defer s.close()
+	conn, err := Connect(s.addr(), "user", "secret", ConnectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	infos := conn.Servers()
+	if len(infos) != 1 {
+		t.Fatalf("expected 1 server got %d", len(infos))
+	}
+	if a := infos[0].Address; a != s.addr() || strings.Contains(a, "@") || strings.Contains(a, "secret") {
+		t.Errorf("expected address %s got %s", s.addr(), a)
+	}
+}
+
+func TestConn_ConnectionID(t *testing.T) {
+	s := newClusterStubServer(t, 7, nil, echoHandler)
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// the ids of the login response of the stub server.
+	if id := conn.ConnectionID(); id != 1 {
+		t.Errorf("expected connection id 1 got %d", id)
+	}
+	if id := conn.HostID(); id != 7 {
+		t.Errorf("expected host id 7 got %d", id)
+	}
+}
@@ -0,0 +1,136 @@
+package voltdbclient
+
+import (
+	"bytes"
+	"database/sql"
+	"testing"
+
+	"github.com/VoltDB/voltdb-client-go/wire"
+)
+
+func TestVoltDriver(t *testing.T) {
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		switch inv.proc {
+		case "HELLOWORLD.select":
+			types := []int8{wire.StringColumn, wire.StringColumn}
+			names := []string{"HELLO", "WORLD"}
+			return stubResponse(inv.handle, stubTable(types, names, []interface{}{"Bonjour", "Monde"}))
+		case "@AdHoc":
+			return stubResponse(inv.handle, stubResult(1))
+		}
+		return stubErrorResponse(inv.handle, GracefulFailure, "unknown procedure")
+	})
+	defer s.close()
+
+	db, err := sql.Open("voltdb", "voltdb://user:secret@"+s.addr()+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("HELLOWORLD.select", "French")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cols) != 2 || cols[0] != "HELLO" || cols[1] != "WORLD" {
+		t.Errorf("expected [HELLO WORLD] got %v", cols)
+	}
+	if !rows.Next() {
+		t.Fatalf("expected a row %v", rows.Err())
+	}
+	var hello, world string
+	if err = rows.Scan(&hello, &world); err != nil {
+		t.Fatal(err)
+	}
+	if hello != "Bonjour" || world != "Monde" {
+		t.Errorf("expected Bonjour Monde got %s %s", hello, world)
+	}
+	if rows.Next() {
+		t.Error("expected a single row")
+	}
+	rows.Close()
+
+	stmt, err := db.Prepare("insert into HELLOWORLD values (?, ?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := stmt.Exec("Bonjour", "Monde", "French")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 got %d", n)
+	}
+
+	if _, err = db.Query("NOSUCHPROC"); err == nil {
+		t.Error("expected an error")
+	}
+
+	if _, err = db.Begin(); err != errTxNotSupported {
+		t.Errorf("expected %v got %v", errTxNotSupported, err)
+	}
+}
+
+func TestVoltDriver_NullValue(t *testing.T) {
+	params := make(chan []byte, 1)
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		params <- inv.params
+		return stubResponse(inv.handle, stubResult(1))
+	})
+	defer s.close()
+
+	db, err := sql.Open("voltdb", "voltdb://"+s.addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("USERS.insert", wire.NullInteger()); err != nil {
+		t.Fatal(err)
+	}
+	// the parameter count, then a NULL INTEGER.
+	exp := []byte{0, 1, byte(wire.IntColumn), 0x80, 0, 0, 0}
+	if p := <-params; !bytes.Equal(p, exp) {
+		t.Errorf("expected %v got %v", exp, p)
+	}
+}
+
+func TestVoltDriver_SQLNullTypes(t *testing.T) {
+	params := make(chan []byte, 1)
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		params <- inv.params
+		return stubResponse(inv.handle, stubResult(1))
+	})
+	defer s.close()
+
+	db, err := sql.Open("voltdb", "voltdb://"+s.addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	sample := []struct {
+		v   sql.NullInt64
+		exp []byte
+	}{
+		{sql.NullInt64{Int64: 7, Valid: true}, []byte{0, 1, byte(wire.LongColumn), 0, 0, 0, 0, 0, 0, 0, 7}},
+		// a typed NULL, not a NULL of unknown type.
+		{sql.NullInt64{}, []byte{0, 1, byte(wire.LongColumn), 0x80, 0, 0, 0, 0, 0, 0, 0}},
+	}
+	for _, s := range sample {
+		if _, err := db.Exec("USERS.insert", s.v); err != nil {
+			t.Fatal(err)
+		}
+		if p := <-params; !bytes.Equal(p, s.exp) {
+			t.Errorf("%v: expected %v got %v", s.v, s.exp, p)
+		}
+	}
+}
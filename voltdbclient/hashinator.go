@@ -18,14 +18,17 @@
 package voltdbclient
 
 import (
+	"context"
 	"database/sql/driver"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"strconv"
 
 	"sync"
 
+	"github.com/VoltDB/voltdb-client-go/wire"
 	"github.com/spaolacci/murmur3"
 )
 
@@ -76,43 +79,55 @@ func (h *hashinatorElastic) getConfigurationType() string {
 	return Elastic
 }
 
+// getHashedPartitionForParameter returns the partition of v the way the server
+// hashes it. Integers are hashed as 8 little endian bytes, strings as their
+// UTF-8 bytes and NULL values are in partition 0.
 func (h *hashinatorElastic) getHashedPartitionForParameter(partitionParameterType int, v driver.Value) (hashedPartition int, err error) {
 	if v == nil {
 		return 0, nil
 	}
 	var value uint64
-	switch v.(type) {
-	case nullValue:
+	switch v := v.(type) {
+	case nullValue, wire.NullValue:
 		return 0, nil
 	case []byte:
-		v1, _ := murmur3.Sum128(v.([]byte))
-		hash := int(v1 >> 32)
-		return SearchToken2Partitions(h.tp, hash), nil
+		return SearchToken2Partitions(h.tp, hashToken(v)), nil
 	case string:
-		v1, _ := murmur3.Sum128([]byte(v.(string)))
-		hash := int(v1 >> 32)
-		return SearchToken2Partitions(h.tp, hash), nil
+		return SearchToken2Partitions(h.tp, hashToken([]byte(v))), nil
 	case byte:
-		value = uint64(v.(byte))
+		value = uint64(v)
 	case int8:
-		value = uint64(v.(int8))
+		value = uint64(v)
 	case int16:
-		value = uint64(v.(int16))
+		value = uint64(v)
 	case int32:
-		value = uint64(v.(int32))
+		value = uint64(v)
 	case int64:
-		value = uint64(v.(int64))
+		value = uint64(v)
+	case int:
+		value = uint64(v)
+	case driver.Valuer:
+		// the database/sql Null types hash like the value they hold.
+		dv, err := v.Value()
+		if err != nil {
+			return 0, err
+		}
+		return h.getHashedPartitionForParameter(partitionParameterType, dv)
+	default:
+		return 0, fmt.Errorf("voltdbclient: a %T can't be hashed to a partition", v)
 	}
 	buf := spool.Get().([]byte)
 	defer spool.Put(buf)
 	binary.LittleEndian.PutUint64(buf, value)
-	return h.checkPertition(&buf)
+	return SearchToken2Partitions(h.tp, hashToken(buf)), nil
 }
 
-func (h *hashinatorElastic) checkPertition(v *[]byte) (int, error) {
-	v1, _ := murmur3.Sum128(*v)
-	hash := int(v1 >> 32)
-	return SearchToken2Partitions(h.tp, hash), nil
+// hashToken returns the token of b on the hash ring. The server keeps the
+// upper 32 bits of the first half of the 128 bit murmur3 hash as a signed
+// int, tokens are negative when the top bit is set.
+func hashToken(b []byte) int {
+	h1, _ := murmur3.Sum128(b)
+	return int(int32(h1 >> 32))
 }
 
 // until go 1.7, go lang won't support non-string type keys for (un-)marshal
@@ -127,7 +142,7 @@ func (h *hashinatorElastic) unmarshalJSONConfig(bytes []byte) (err error) {
 		return
 	}
 
-	h.tp = make(Token2PartitionSlice, 128)
+	h.tp = make(Token2PartitionSlice, 0, len(sk))
 	// Copy the values
 
 	var ki int
@@ -142,3 +157,35 @@ func (h *hashinatorElastic) unmarshalJSONConfig(bytes []byte) (err error) {
 
 	return
 }
+
+// HashToPartition returns the partition the server stores rows with the
+// partitioning column value key in, the way single partition procedures are
+// routed. Keys can be integers, strings and []byte, NULL keys are in partition
+// 0. The hash function of the cluster is fetched with @Statistics TOPO when
+// the connection doesn't know it yet.
+func (c *Conn) HashToPartition(ctx context.Context, key driver.Value) (int, error) {
+	h := c.getHashinator()
+	if h == nil {
+		rsp, err := c.CallContext(ctx, "@Statistics", "TOPO", int32(JSONFormat))
+		if err != nil {
+			return 0, err
+		}
+		if h, err = decodeHashinator(rsp.(VoltRows)); err != nil {
+			return 0, err
+		}
+		c.setHashinator(h)
+	}
+	return h.getHashedPartitionForParameter(-1, key)
+}
+
+func (c *Conn) setHashinator(h hashinator) {
+	c.hnatorMu.Lock()
+	c.hnator = h
+	c.hnatorMu.Unlock()
+}
+
+func (c *Conn) getHashinator() hashinator {
+	c.hnatorMu.Lock()
+	defer c.hnatorMu.Unlock()
+	return c.hnator
+}
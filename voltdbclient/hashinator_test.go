@@ -1,15 +1,153 @@
 package voltdbclient
 
 import (
+	"context"
 	"crypto/rand"
+	"database/sql"
 	"database/sql/driver"
 	"io/ioutil"
 	r "math/rand"
 	"testing"
+	"time"
 
 	"github.com/VoltDB/voltdb-client-go/wire"
 )
 
+// The partitions of keys under the hash function in jsonConfigC.bin, they were
+// computed with the murmur3 hash and token search of the server's
+// ElasticHashinator.
+var hashinatorVectors = []struct {
+	key       driver.Value
+	token     int
+	partition int
+}{
+	{int64(0), 685728695, 2},
+	{int64(1), 4457399, 6},
+	{int64(-1), -1595624838, 9},
+	{int64(7), 820062611, 3},
+	{int64(42), -1230191719, 8},
+	{int64(1000), -1370562377, 6},
+	{int64(123456789), 636466778, 2},
+	{int64(-987654321), -502152403, 8},
+	{int64(9223372036854775807), 1819732939, 0},
+	{"", 0, 6},
+	{"a", -2058005147, 10},
+	{"volt", -313007341, 1},
+	{"123456789012345", -2005925458, 10},
+	{"Hello, VoltDB", 1316712383, 5},
+	{[]byte{0, 1, 2}, -1200447185, 8},
+	{[]byte("abcdefghijklmnopq"), 1969517695, 0},
+}
+
+func TestHashinatorElastic_Vectors(t *testing.T) {
+	jsonBytes, err := ioutil.ReadFile("./test_resources/jsonConfigC.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := newHashinatorElastic(JSONFormat, true, jsonBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.tp) != 1024 {
+		t.Errorf("expected 1024 tokens got %d", len(h.tp))
+	}
+	for _, v := range hashinatorVectors {
+		p, err := h.getHashedPartitionForParameter(-1, v.key)
+		if err != nil || p != v.partition {
+			t.Errorf("%v: expected partition %d got %d %v", v.key, v.partition, p, err)
+		}
+		if n, ok := v.key.(int64); ok {
+			// narrower integers hash like the same int64 value.
+			if n == int64(int32(n)) {
+				if p, _ := h.getHashedPartitionForParameter(-1, int32(n)); p != v.partition {
+					t.Errorf("int32 %v: expected partition %d got %d", n, v.partition, p)
+				}
+				if p, _ := h.getHashedPartitionForParameter(-1, int(n)); p != v.partition {
+					t.Errorf("int %v: expected partition %d got %d", n, v.partition, p)
+				}
+			}
+			if p, _ := h.getHashedPartitionForParameter(-1, sql.NullInt64{Int64: n, Valid: true}); p != v.partition {
+				t.Errorf("sql.NullInt64 %v: expected partition %d got %d", n, v.partition, p)
+			}
+		}
+	}
+	if p, err := h.getHashedPartitionForParameter(-1, sql.NullInt64{}); err != nil || p != 0 {
+		t.Errorf("expected an invalid sql.NullInt64 in partition 0 got %d %v", p, err)
+	}
+	if p, err := h.getHashedPartitionForParameter(-1, wire.NullString()); err != nil || p != 0 {
+		t.Errorf("expected NULL in partition 0 got %d %v", p, err)
+	}
+	if _, err := h.getHashedPartitionForParameter(-1, 1.5); err == nil {
+		t.Error("expected a float key to fail")
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	for _, v := range hashinatorVectors {
+		var b []byte
+		switch k := v.key.(type) {
+		case int64:
+			b = make([]byte, 8)
+			for i := range b {
+				b[i] = byte(k >> (8 * uint(i)))
+			}
+		case string:
+			b = []byte(k)
+		case []byte:
+			b = k
+		}
+		if token := hashToken(b); token != v.token {
+			t.Errorf("%v: expected token %d got %d", v.key, v.token, token)
+		}
+	}
+}
+
+func TestSearchToken2Partitions(t *testing.T) {
+	tp := Token2PartitionSlice{{-100, 1}, {0, 2}, {100, 3}}
+	tokens := map[int]int{
+		-100: 1,
+		-1:   1,
+		0:    2,
+		99:   2,
+		100:  3,
+		1000: 3,
+		// tokens below the smallest wrap around the ring.
+		-1000: 3,
+	}
+	for token, partition := range tokens {
+		if p := SearchToken2Partitions(tp, token); p != partition {
+			t.Errorf("%d: expected partition %d got %d", token, partition, p)
+		}
+	}
+}
+
+func TestConn_HashToPartition(t *testing.T) {
+	hashConfig, err := ioutil.ReadFile("./test_resources/jsonConfigC.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := newHashinatorElastic(JSONFormat, true, hashConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newStubServer(t, affinityHandler(hashConfig, h.tp))
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, v := range hashinatorVectors {
+		p, err := conn.HashToPartition(ctx, v.key)
+		if err != nil || p != v.partition {
+			t.Errorf("%v: expected partition %d got %d %v", v.key, v.partition, p, err)
+		}
+	}
+}
+
 func BenchmarkHashinater_getHashedPartitionForParameter_int32(b *testing.B) {
 	jsonBytes, err := ioutil.ReadFile("./test_resources/jsonConfigC.bin")
 	if err != nil {
@@ -0,0 +1,58 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2017 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import "time"
+
+// Metrics receives the events of the connections to the servers, it lets the
+// client be instrumented with any metrics library, e.g. by incrementing
+// Prometheus counters. The methods are called concurrently from the goroutines
+// serving the connections, they must be safe for concurrent use and must not
+// block.
+//
+// The number of calls in flight is the number of CallStarted events minus the
+// number of CallFinished events.
+type Metrics interface {
+	// CallStarted is called when the invocation of proc is sent to a server.
+	CallStarted(proc string)
+
+	// CallFinished is called once for every started invocation of proc, when
+	// it is answered, times out or its connection is lost. err is nil when
+	// the invocation succeeded. latency is the time from sending the
+	// invocation to receiving the response, it is negative when no response
+	// was received.
+	CallFinished(proc string, latency time.Duration, err error)
+
+	// BytesSent and BytesReceived are called with the size of every message
+	// written to or read from a server.
+	BytesSent(n int)
+	BytesReceived(n int)
+
+	// Reconnected is called when the connection to host is reestablished
+	// after it was lost.
+	Reconnected(host string)
+}
+
+// nopMetrics is the Metrics used when none is configured.
+type nopMetrics struct{}
+
+func (nopMetrics) CallStarted(string)                        {}
+func (nopMetrics) CallFinished(string, time.Duration, error) {}
+func (nopMetrics) BytesSent(int)                             {}
+func (nopMetrics) BytesReceived(int)                         {}
+func (nopMetrics) Reconnected(string)                        {}
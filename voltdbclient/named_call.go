@@ -0,0 +1,54 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2017 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// CallNamed invokes the stored procedure proc with the parameters given by
+// name, it is analogous to CallContext. The order of the parameters is looked
+// up with @SystemCatalog PROCEDURECOLUMNS the first time a procedure is
+// called, parameter names are case insensitive. An error is returned when the
+// parameters of proc are unknown, such procedures need to be called with
+// positional parameters. See LoadProcedureCatalog.
+func (c *Conn) CallNamed(ctx context.Context, proc string, params map[string]interface{}) (driver.Rows, error) {
+	procParams, err := c.procParams(ctx, proc)
+	if err != nil {
+		return nil, err
+	}
+	if len(params) != len(procParams) {
+		return nil, fmt.Errorf("voltdbclient: procedure %s takes %d parameters, %d were given", proc, len(procParams), len(params))
+	}
+	byName := make(map[string]interface{}, len(params))
+	for name, v := range params {
+		byName[strings.ToUpper(name)] = v
+	}
+	args := make([]driver.Value, len(procParams))
+	for i, p := range procParams {
+		v, ok := byName[p.Name]
+		if !ok {
+			return nil, fmt.Errorf("voltdbclient: parameter %s of procedure %s is missing", p.Name, proc)
+		}
+		args[i] = v
+	}
+	return c.CallContext(ctx, proc, args...)
+}
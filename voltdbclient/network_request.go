@@ -23,9 +23,12 @@ import (
 
 type networkRequest struct {
 	handle int64
+	// the name of the invoked procedure.
+	proc   string
 	query  bool
 	ch     chan voltResponse
 	sync   bool
+	stream bool
 	arc    AsyncResponseConsumer
 	// the size of the serialized request written to the server.
 	numBytes  int
@@ -67,6 +70,10 @@ func (nr *networkRequest) isSync() bool {
 	return nr.sync
 }
 
+func (nr *networkRequest) isStream() bool {
+	return nr.stream
+}
+
 func (nr *networkRequest) isQuery() bool {
 	return nr.query
 }
@@ -18,42 +18,85 @@
 package voltdbclient
 
 import (
+	"encoding/binary"
+	"math"
+	"time"
+
 	"github.com/VoltDB/voltdb-client-go/wire"
 )
 
+// The batch timeout types that start an invocation. A timeout override is
+// followed by the timeout in milliseconds.
+const (
+	noBatchTimeout       int8 = 0
+	batchTimeoutOverride int8 = 1
+)
+
+// timeoutMillis converts d to milliseconds, it's at least 1 so that a short
+// timeout isn't sent as none.
+func timeoutMillis(d time.Duration) int32 {
+	ms := d / time.Millisecond
+	if ms < 1 {
+		return 1
+	}
+	if ms > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int32(ms)
+}
+
+// EncodePI encodes pi as a message, its int32 length prefix followed by the
+// invocation. The length is known once the parameters are encoded, a
+// placeholder is written first and replaced with the number of bytes encoded.
+// The length includes the bytes of StreamValue parameters and is kept in pi,
+// see getLen.
 func EncodePI(e *wire.Encoder, pi *procedureInvocation) error {
-	_, err := e.Int32(int32(pi.getLen()))
+	start := e.Len()
+	_, err := e.Int32(0)
 	if err != nil {
 		return err
 	}
 
-	// batch timeout type
-	_, err = e.Byte(0)
+	var size, n int
+	if pi.queryTimeout > 0 {
+		n, err = e.Byte(batchTimeoutOverride)
+		if err != nil {
+			return err
+		}
+		size += n
+		n, err = e.Int32(timeoutMillis(pi.queryTimeout))
+	} else {
+		n, err = e.Byte(noBatchTimeout)
+	}
 	if err != nil {
 		return err
 	}
+	size += n
 
-	_, err = e.String(pi.query)
+	n, err = e.String(pi.query)
 	if err != nil {
 		return err
 	}
-	_, err = e.Int64(pi.handle)
+	size += n
+	n, err = e.Int64(pi.handle)
 	if err != nil {
 		return err
 	}
+	size += n
 
-	_, err = e.Int16(int16(len(pi.params)))
+	n, err = e.Int16(int16(len(pi.params)))
 	if err != nil {
 		return err
 	}
+	size += n
 	for i := 0; i < len(pi.params); i++ {
-		_, err = e.Marshal(pi.params[i])
+		n, err = e.Marshal(pi.params[i])
 		if err != nil {
 			return err
 		}
+		size += n
 	}
-	if err != nil {
-		return err
-	}
+	binary.BigEndian.PutUint32(e.Bytes()[start:], uint32(size))
+	pi.slen = size
 	return nil
 }
@@ -19,25 +19,62 @@ package voltdbclient
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"database/sql/driver"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/VoltDB/voltdb-client-go/wire"
 )
 
+var errConnectionLost = errors.New("voltdbclient: connection to the server was lost")
+
+var errReadTimeout = errors.New("voltdbclient: the server didn't answer within the read timeout")
+
 // start back pressure when this many bytes are queued for write
 const maxQueuedBytes = 262144
 const maxResponseBuffer = 10000
 
 type nodeConn struct {
+	// lastWrite is the time in nanoseconds of the last invocation written
+	// and inFlight the number of invocations waiting for a response. They
+	// are set by the loop and read by the listener to tell a stalled server
+	// from an idle connection. The 64-bit fields are first to keep them
+	// aligned.
+	lastWrite int64
+
+	// the traffic of the connection, see ConnStats.
+	bytesSent         int64
+	bytesReceived     int64
+	callsSent         int64
+	responsesReceived int64
+
+	inFlight int32
+
+	// state is the ConnState of the connection to the server, it's read by
+	// Conn.State.
+	state int32
+
 	connInfo string
-	connData *wire.ConnInfo
-	tcpConn  *net.TCPConn
+	conn     net.Conn
+	opts     ConnectOptions
+
+	// connData is the login response of the server, it's replaced when
+	// reconnecting and guarded by connDataMu. version is parsed from its
+	// build string, versionOK is false when it couldn't be.
+	connDataMu sync.Mutex
+	connData   *wire.ConnInfo
+	version    ServerVersion
+	versionOK  bool
+
+	// the protocol version used to login, it is used again to reconnect.
+	protocolVersion int
 
 	drainCh chan chan bool
 	bpCh    chan chan bool
@@ -47,17 +84,30 @@ type nodeConn struct {
 	ncPiCh  chan *procedureInvocation
 	decoder *wire.Decoder
 	encoder *wire.Encoder
+
+	// stats collects the statistics of the procedures invoked on the
+	// connection, it is shared with the other nodes of the Conn.
+	stats *clientStats
+
+	// events receives the connection events, it's shared with the other
+	// nodes of the Conn.
+	events *connNotifier
 }
 
-func newNodeConn(ci string, ncPiCh chan *procedureInvocation) *nodeConn {
+func newNodeConn(ci string, ncPiCh chan *procedureInvocation, opts ConnectOptions) *nodeConn {
+	encoder := wire.NewEncoder()
+	encoder.SetMaxStringLen(opts.MaxStringLength)
+	encoder.SetMapAsJSON(opts.MapAsJSON)
 	return &nodeConn{
 		connInfo: ci,
+		state:    int32(Connecting),
+		opts:     opts,
 		ncPiCh:   ncPiCh,
 		bpCh:     make(chan chan bool),
 		closeCh:  make(chan chan bool),
 		drainCh:  make(chan chan bool),
 		decoder:  wire.NewDecoder(nil),
-		encoder:  wire.NewEncoder(),
+		encoder:  encoder,
 	}
 }
 
@@ -73,44 +123,196 @@ func (nc *nodeConn) close() chan bool {
 }
 
 func (nc *nodeConn) connect(protocolVersion int, piCh <-chan *procedureInvocation) error {
-	tcpConn, connData, err := nc.networkConnect(protocolVersion)
+	conn, connData, err := nc.networkConnect(protocolVersion)
 	if err != nil {
 		return err
 	}
-	nc.connData = connData
-	nc.tcpConn = tcpConn
+	nc.protocolVersion = protocolVersion
+	nc.setConnData(connData)
+	nc.conn = conn
+	nc.setState(Connected)
+	nc.events.notify(ConnEvent{Type: ServerConnected, Host: nc.host()})
 
-	responseCh := make(chan *bytes.Buffer, maxResponseBuffer)
-	go nc.listen(tcpConn, responseCh)
+	responseCh, lostCh := nc.startListener(conn)
 
 	nc.drainCh = make(chan chan bool, 1)
 
-	go nc.loop(tcpConn, piCh, responseCh, nc.bpCh, nc.drainCh)
+	go nc.loop(conn, piCh, responseCh, lostCh, nc.bpCh, nc.drainCh)
 	return nil
 }
 
-// called when the network listener loses connection.
-// the 'processAsyncs' goroutine and channel stay in place over
-// a reconnect, they're not affected.
-func (nc *nodeConn) reconnect(protocolVersion int, piCh <-chan *procedureInvocation) {
-	for {
-		tcpConn, connData, err := nc.networkConnect(protocolVersion)
+// startListener starts listening for responses on conn. The error that ends
+// the listener is sent on lostCh.
+func (nc *nodeConn) startListener(conn net.Conn) (<-chan *bytes.Buffer, <-chan error) {
+	responseCh := make(chan *bytes.Buffer, maxResponseBuffer)
+	lostCh := make(chan error, 1)
+	var r io.Reader = conn
+	if nc.opts.ReadTimeout > 0 {
+		r = &timeoutReader{conn: conn, nc: nc, timeout: nc.opts.ReadTimeout}
+	}
+	go nc.listen(r, responseCh, lostCh)
+	return responseCh, lostCh
+}
+
+// redial is called by the loop when the connection to the server is lost, it
+// reconnects following the reconnect policy of the connection. It returns the
+// new connection, or nil if the policy gave up. If the node conn is closed
+// while reconnecting the channel to respond on is returned.
+func (nc *nodeConn) redial(bpCh <-chan chan bool) (net.Conn, chan bool) {
+	policy := nc.opts.reconnectPolicy()
+	for attempt := 0; policy.MaxRetries < 0 || attempt < policy.MaxRetries; attempt++ {
+		timer := time.NewTimer(policy.delay(attempt))
+	wait:
+		for {
+			select {
+			case respCh := <-nc.closeCh:
+				timer.Stop()
+				return nil, respCh
+			case respBPCh := <-bpCh:
+				respBPCh <- true
+			case <-timer.C:
+				break wait
+			}
+		}
+		nc.opts.logger().Infof("reconnecting to server %s, attempt %d", nc.host(), attempt+1)
+		conn, connData, err := nc.networkConnect(nc.protocolVersion)
 		if err != nil {
-			log.Println(fmt.Printf("Failed to reconnect to server with %s, retrying\n", err))
-			time.Sleep(5 * time.Second)
 			continue
 		}
-		nc.tcpConn = tcpConn
-		nc.connData = connData
+		nc.conn = conn
+		nc.setConnData(connData)
+		nc.setState(Connected)
+		nc.opts.metrics().Reconnected(nc.host())
+		nc.opts.logger().Infof("reconnected to server %s", nc.host())
+		nc.events.notify(ConnEvent{Type: ServerReconnected, Host: nc.host()})
+		return conn, nil
+	}
+	nc.opts.logger().Errorf("gave up reconnecting to server %s", nc.host())
+	nc.events.notify(ConnEvent{Type: ServerGaveUp, Host: nc.host()})
+	return nil, nil
+}
+
+func (nc *nodeConn) setState(s ConnState) {
+	atomic.StoreInt32(&nc.state, int32(s))
+}
+
+func (nc *nodeConn) getState() ConnState {
+	return ConnState(atomic.LoadInt32(&nc.state))
+}
+
+func (nc *nodeConn) setConnData(connData *wire.ConnInfo) {
+	v, err := ParseServerVersion(connData.Build)
+	nc.connDataMu.Lock()
+	nc.connData = connData
+	nc.version, nc.versionOK = v, err == nil
+	nc.connDataMu.Unlock()
+}
+
+// serverVersion returns the version of the server, ok is false when it isn't
+// known.
+func (nc *nodeConn) serverVersion() (v ServerVersion, ok bool) {
+	nc.connDataMu.Lock()
+	defer nc.connDataMu.Unlock()
+	return nc.version, nc.versionOK
+}
+
+// getConnData returns the login response of the server, or nil if it was
+// never logged in to.
+func (nc *nodeConn) getConnData() *wire.ConnInfo {
+	nc.connDataMu.Lock()
+	defer nc.connDataMu.Unlock()
+	return nc.connData
+}
+
+// serveLost is run by the loop once reconnecting to the server has failed.
+// Procedure invocations meant for this connection fail until it is closed.
+func (nc *nodeConn) serveLost(bpCh <-chan chan bool, drainCh chan chan bool) {
+	nc.setState(Disconnected)
+	verr := connectionLostError()
+	for {
+		select {
+		case respCh := <-nc.closeCh:
+			nc.failPending(nil, nil)
+			respCh <- true
+			return
+		case pi := <-nc.ncPiCh:
+			failInvocation(pi, verr)
+		case respBPCh := <-bpCh:
+			respBPCh <- true
+		case respCh := <-drainCh:
+			respCh <- true
+		}
+	}
+}
+
+// networkConnect connects and logs in to the server, logging the outcome.
+func (nc *nodeConn) networkConnect(protocolVersion int) (net.Conn, *wire.ConnInfo, error) {
+	conn, info, err := nc.dialAndLogin(protocolVersion)
+	logger := nc.opts.logger()
+	switch err.(type) {
+	case nil:
+		logger.Infof("logged in to server %s, host id %d", nc.host(), info.HostID)
+	case wire.AuthError, LoginError:
+		logger.Errorf("login to server %s failed: %v", nc.host(), err)
+	default:
+		logger.Warnf("connecting to server %s failed: %v", nc.host(), err)
+	}
+	return conn, info, err
+}
+
+// host returns the address of the server, without the credentials the
+// connection string may hold.
+func (nc *nodeConn) host() string {
+	u, err := parseURL(nc.connInfo)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// dial establishes the TCP connection to host, with the DialContext option when
+// it is set, and sets TCP_NODELAY on it unless DisableTCPNoDelay is set.
+func (nc *nodeConn) dial(host string) (net.Conn, error) {
+	conn, err := nc.dialTCP(host)
+	if err != nil {
+		return nil, err
+	}
+	// connections returned by DialContext may not be TCP connections.
+	if tc, ok := conn.(interface{ SetNoDelay(bool) error }); ok {
+		if err := tc.SetNoDelay(!nc.opts.DisableTCPNoDelay); err != nil {
+			conn.Close()
+			return nil, DialError{Addr: host, Err: err}
+		}
+	}
+	return conn, nil
+}
 
-		responseCh := make(chan *bytes.Buffer, maxResponseBuffer)
-		go nc.listen(tcpConn, responseCh)
-		go nc.loop(tcpConn, piCh, responseCh, nc.bpCh, nc.drainCh)
-		break
+func (nc *nodeConn) dialTCP(host string) (net.Conn, error) {
+	if nc.opts.DialContext == nil {
+		raddr, err := net.ResolveTCPAddr("tcp", host)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving %v", nc.connInfo)
+		}
+		conn, err := net.DialTimeout("tcp", raddr.String(), nc.opts.DialTimeout)
+		if err != nil {
+			return nil, DialError{Addr: host, Err: err}
+		}
+		return conn, nil
+	}
+	ctx := context.Background()
+	if nc.opts.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, nc.opts.DialTimeout)
+		defer cancel()
 	}
+	conn, err := nc.opts.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, DialError{Addr: host, Err: err}
+	}
+	return conn, nil
 }
 
-func (nc *nodeConn) networkConnect(protocolVersion int) (*net.TCPConn, *wire.ConnInfo, error) {
+func (nc *nodeConn) dialAndLogin(protocolVersion int) (net.Conn, *wire.ConnInfo, error) {
 	defer func() {
 		nc.decoder.Reset()
 		nc.encoder.Reset()
@@ -119,33 +321,99 @@ func (nc *nodeConn) networkConnect(protocolVersion int) (*net.TCPConn, *wire.Con
 	if err != nil {
 		return nil, nil, err
 	}
-	raddr, err := net.ResolveTCPAddr("tcp", u.Host)
+	conn, err := nc.dial(u.Host)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error resolving %v", nc.connInfo)
+		return nil, nil, err
 	}
-	tcpConn, err := net.DialTCP("tcp", nil, raddr)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to connect to server %v", nc.connInfo)
+	if nc.opts.LoginTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(nc.opts.LoginTimeout))
+	}
+	if nc.opts.TLSConfig != nil {
+		host, _, _ := net.SplitHostPort(u.Host)
+		tlsConn := tls.Client(conn, tlsConfigFor(nc.opts.TLSConfig, host))
+		if err = tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, nil, fmt.Errorf("TLS handshake with server %v failed %v", nc.connInfo, err)
+		}
+		conn = tlsConn
 	}
 	pass, _ := u.User.Password()
 	nc.encoder.Reset()
-	login, err := nc.encoder.Login(protocolVersion, u.User.Username(), pass)
+	login, err := nc.encoder.EncodeLogin(wire.LoginRequest{
+		Version:      protocolVersion,
+		Scheme:       nc.opts.AuthScheme.hashScheme(protocolVersion),
+		Service:      nc.opts.Service,
+		User:         u.User.Username(),
+		Password:     pass,
+		PasswordHash: nc.opts.PasswordHash,
+	})
 	if err != nil {
-		tcpConn.Close()
-		return nil, nil, fmt.Errorf("failed to serialize login message %v", nc.connInfo)
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to serialize login message %v %v", nc.connInfo, err)
 	}
-	_, err = tcpConn.Write(login)
+	_, err = conn.Write(login)
 	if err != nil {
-		return nil, nil, err
+		conn.Close()
+		return nil, nil, LoginError{Addr: u.Host, Err: err}
 	}
 	nc.decoder.Reset()
-	nc.decoder.SetReader(tcpConn)
+	nc.decoder.SetReader(conn)
 	i, err := nc.decoder.Login()
 	if err != nil {
-		tcpConn.Close()
-		return nil, nil, fmt.Errorf("failed to login to server %v", nc.connInfo)
+		conn.Close()
+		if _, ok := err.(wire.AuthError); ok {
+			return nil, nil, err
+		}
+		return nil, nil, LoginError{Addr: u.Host, Err: err}
 	}
-	return tcpConn, i, nil
+	// the deadline only covers logging in.
+	conn.SetDeadline(time.Time{})
+	return conn, i, nil
+}
+
+// DialError is returned when the TCP connection to the server at Addr can't
+// be established, such as when the server is down or unreachable.
+type DialError struct {
+	Addr string
+	Err  error
+}
+
+func (e DialError) Error() string {
+	return fmt.Sprintf("voltdbclient: failed to connect to server %s, %v", e.Addr, e.Err)
+}
+
+// LoginError is returned when logging in to the server at Addr fails without
+// the server rejecting the login, such as when it doesn't answer within the
+// LoginTimeout. A rejected login returns a wire.AuthError instead.
+type LoginError struct {
+	Addr string
+	Err  error
+}
+
+func (e LoginError) Error() string {
+	return fmt.Sprintf("voltdbclient: failed to login to server %s, %v", e.Addr, e.Err)
+}
+
+// ConnectionLostDuringCallError is the error of calls that were sent to the
+// server at Addr when the connection to it was lost, before their response
+// arrived. The server may or may not have executed them, only calls that can
+// safely run twice should be retried. Err tells why the connection was lost.
+//
+// The VoltError of such calls has the ConnectionLost status, or
+// ConnectionTimeout when the server didn't answer within the read timeout, use
+// errors.As to tell them apart from calls that were never sent.
+type ConnectionLostDuringCallError struct {
+	Addr string
+	Err  error
+}
+
+func (e ConnectionLostDuringCallError) Error() string {
+	return fmt.Sprintf("voltdbclient: lost the connection to server %s during the call, it may or may not have been executed, %v", e.Addr, e.Err)
+}
+
+// Unwrap returns the reason the connection was lost.
+func (e ConnectionLostDuringCallError) Unwrap() error {
+	return e.Err
 }
 
 func (nc *nodeConn) drain(respCh chan bool) {
@@ -158,36 +426,79 @@ func (nc *nodeConn) hasBP() bool {
 	return <-respCh
 }
 
+// timeoutReader reads from conn with a read deadline. A read that times out
+// fails with errReadTimeout when an invocation has been waiting for a response
+// for the timeout, otherwise the connection is idle and the read is retried.
+type timeoutReader struct {
+	conn    net.Conn
+	nc      *nodeConn
+	timeout time.Duration
+}
+
+func (r *timeoutReader) Read(p []byte) (int, error) {
+	deadline := time.Now().Add(r.timeout)
+	for {
+		r.conn.SetReadDeadline(deadline)
+		n, err := r.conn.Read(p)
+		if ne, ok := err.(net.Error); n > 0 || !ok || !ne.Timeout() {
+			return n, err
+		}
+		now := time.Now()
+		deadline = now.Add(r.timeout)
+		if atomic.LoadInt32(&r.nc.inFlight) == 0 {
+			continue
+		}
+		// the invocation may have been written after the read started.
+		waiting := time.Unix(0, atomic.LoadInt64(&r.nc.lastWrite)).Add(r.timeout)
+		if !now.Before(waiting) {
+			return 0, errReadTimeout
+		}
+		deadline = waiting
+	}
+}
+
+// setInFlight records the number of invocations waiting for a response for
+// the listener.
+func (nc *nodeConn) setInFlight(n int) {
+	atomic.StoreInt32(&nc.inFlight, int32(n))
+}
+
+// write writes the values encoded by e to writer, within the WriteTimeout
+// when it is set. The deadline is cleared again once the write returned.
+func (nc *nodeConn) write(writer io.Writer, e *wire.Encoder) (int64, error) {
+	c, ok := writer.(net.Conn)
+	if !ok || nc.opts.WriteTimeout <= 0 {
+		return e.WriteTo(writer)
+	}
+	c.SetWriteDeadline(time.Now().Add(nc.opts.WriteTimeout))
+	defer c.SetWriteDeadline(time.Time{})
+	return e.WriteTo(c)
+}
+
 // listen listens for messages from the server and calls back a registered listener.
 // listen blocks on input from the server and should be run as a go routine.
-func (nc *nodeConn) listen(reader io.Reader, responseCh chan<- *bytes.Buffer) {
+func (nc *nodeConn) listen(reader io.Reader, responseCh chan<- *bytes.Buffer, lostCh chan<- error) {
 	d := wire.NewDecoder(reader)
-	s := &wire.Decoder{}
 	for {
 		b, err := d.Message()
 		if err != nil {
-			if responseCh == nil {
-				// exiting
-				return
-			}
-			// TODO: put the error on the channel
 			// the owner needs to reconnect
+			lostCh <- err
 			return
 		}
-		buf := bytes.NewBuffer(b)
-		s.SetReader(buf)
-		_, err = s.Byte()
-		if err != nil {
-			if responseCh == nil {
-				return
-			}
+		nc.opts.metrics().BytesReceived(wire.IntegerSize + len(b))
+		atomic.AddInt64(&nc.bytesReceived, int64(wire.IntegerSize+len(b)))
+		atomic.AddInt64(&nc.responsesReceived, 1)
+		// the loop skips the version, a response without one is corrupt.
+		if len(b) < wire.ByteSize {
+			lostCh <- io.ErrUnexpectedEOF
 			return
 		}
-		responseCh <- buf
+		responseCh <- bytes.NewBuffer(b)
 	}
 }
 
-func (nc *nodeConn) loop(writer io.Writer, piCh <-chan *procedureInvocation, responseCh <-chan *bytes.Buffer, bpCh <-chan chan bool, drainCh chan chan bool) {
+func (nc *nodeConn) loop(writer io.Writer, piCh <-chan *procedureInvocation, responseCh <-chan *bytes.Buffer, lostCh <-chan error, bpCh <-chan chan bool, drainCh chan chan bool) {
 	// declare mutable state
 	requests := make(map[int64]*networkRequest)
 	ncPiCh := nc.ncPiCh
@@ -199,9 +510,17 @@ func (nc *nodeConn) loop(writer io.Writer, piCh <-chan *procedureInvocation, res
 	var tci = int64(DefaultQueryTimeout / 10)                    // timeout check interval
 	tcc := time.NewTimer(time.Duration(tci) * time.Nanosecond).C // timeout check timer channel
 
-	// for ping
-	var pingTimeout = 2 * time.Minute
+	// for ping, the connection is pinged once it has been idle for an
+	// interval.
+	var pingTickCh <-chan time.Time
+	if !nc.opts.DisablePing {
+		pingTicker := time.NewTicker(nc.opts.pingInterval())
+		defer pingTicker.Stop()
+		pingTickCh = pingTicker.C
+	}
+	pingTimeout := 3 * nc.opts.pingInterval()
 	pingSentTime := time.Now()
+	lastActivity := time.Now()
 	var pingOutstanding bool
 	for {
 		// setup select cases
@@ -221,28 +540,39 @@ func (nc *nodeConn) loop(writer io.Writer, piCh <-chan *procedureInvocation, res
 			bp = false
 		}
 
-		// ping
-		pingSinceSent := time.Now().Sub(pingSentTime)
-		if pingOutstanding {
-			if pingSinceSent > pingTimeout {
-				// TODO: should disconnect
-			}
-		} else if pingSinceSent > pingTimeout/3 {
-			nc.sendPing(writer)
-			pingOutstanding = true
-			pingSentTime = time.Now()
-		}
-
 		select {
 		case respCh := <-nc.closeCh:
-			nc.tcpConn.Close()
+			nc.conn.Close()
+			nc.setState(Disconnected)
+			nc.failPending(requests, piCh)
 			respCh <- true
 			return
 		case pi := <-ncPiCh:
 			nc.handleProcedureInvocation(writer, pi, &requests, &queuedBytes)
+			lastActivity = time.Now()
 		case pi := <-piCh:
 			nc.handleProcedureInvocation(writer, pi, &requests, &queuedBytes)
+			lastActivity = time.Now()
+		case <-pingTickCh:
+			if pingOutstanding {
+				if time.Since(pingSentTime) > pingTimeout {
+					// the server stopped answering, closing the connection
+					// makes the listener report it lost.
+					nc.conn.Close()
+				}
+			} else if time.Since(lastActivity) >= nc.opts.pingInterval() {
+				nc.sendPing(writer)
+				pingOutstanding = true
+				pingSentTime = time.Now()
+			}
 		case resp := <-responseCh:
+			lastActivity = time.Now()
+			var raw []byte
+			if nc.opts.KeepRawResponses {
+				raw = append([]byte(nil), resp.Bytes()...)
+			}
+			// the protocol version.
+			resp.Next(wire.ByteSize)
 			nc.decoder.SetReader(resp)
 			handle, err := nc.decoder.Int64()
 			nc.decoder.Reset()
@@ -264,12 +594,52 @@ func (nc *nodeConn) loop(writer io.Writer, piCh <-chan *procedureInvocation, res
 			queuedBytes -= req.numBytes
 
 			delete(requests, handle)
+			nc.setInFlight(len(requests))
 			if req.isSync() {
-				nc.handleSyncResponse(handle, resp, req)
+				nc.handleSyncResponse(handle, resp, raw, req)
 			} else {
-				nc.handleAsyncResponse(handle, resp, req)
+				nc.handleAsyncResponse(handle, resp, raw, req)
 			}
 
+		case err := <-lostCh:
+			nc.opts.logger().Warnf("lost the connection to server %s: %v", nc.host(), err)
+			nc.events.notify(ConnEvent{Type: ServerLost, Host: nc.host(), Err: err})
+			// the requests in flight can't be answered anymore, whether the
+			// server executed them is unknown.
+			status := ConnectionLost
+			if err == errReadTimeout {
+				status = ConnectionTimeout
+			}
+			verr := VoltError{
+				voltResponse: voltResponseInfo{status: status, clusterRoundTripTime: -1},
+				error:        ConnectionLostDuringCallError{Addr: nc.host(), Err: err},
+			}
+			for _, req := range requests {
+				nc.finished(req, -1, verr)
+				nc.failRequest(req, verr)
+			}
+			requests = make(map[int64]*networkRequest)
+			nc.setInFlight(0)
+			queuedBytes = 0
+			// a connection that timed out is still open, the server would
+			// keep its session and send the late responses on it.
+			nc.conn.Close()
+			nc.setState(Reconnecting)
+			conn, closeRespCh := nc.redial(bpCh)
+			if closeRespCh != nil {
+				nc.setState(Disconnected)
+				closeRespCh <- true
+				return
+			}
+			if conn == nil {
+				nc.serveLost(bpCh, drainCh)
+				return
+			}
+			writer = conn
+			responseCh, lostCh = nc.startListener(conn)
+			pingOutstanding = false
+			pingSentTime = time.Now()
+			lastActivity = time.Now()
 		case respBPCh := <-bpCh:
 			respBPCh <- bp
 		case drainRespCh = <-drainCh:
@@ -279,10 +649,11 @@ func (nc *nodeConn) loop(writer io.Writer, piCh <-chan *procedureInvocation, res
 			for _, req := range requests {
 				if time.Now().After(req.submitted.Add(req.timeout)) {
 					queuedBytes -= req.numBytes
-					nc.handleAsyncTimeout(req)
+					nc.handleTimeout(req)
 					delete(requests, req.handle)
 				}
 			}
+			nc.setInFlight(len(requests))
 			tcc = time.NewTimer(time.Duration(tci) * time.Nanosecond).C
 		}
 	}
@@ -291,77 +662,214 @@ func (nc *nodeConn) loop(writer io.Writer, piCh <-chan *procedureInvocation, res
 func (nc *nodeConn) handleProcedureInvocation(writer io.Writer, pi *procedureInvocation, requests *map[int64]*networkRequest, queuedBytes *int) {
 	var nr *networkRequest
 	if pi.isAsync() {
-		nr = newAsyncRequest(pi.handle, pi.responseCh, pi.isQuery, pi.arc, pi.getLen(), pi.timeout, time.Now())
+		nr = newAsyncRequest(pi.handle, pi.responseCh, pi.isQuery, pi.arc, 0, pi.timeout, time.Now())
 	} else {
-		nr = newSyncRequest(pi.handle, pi.responseCh, pi.isQuery, pi.getLen(), pi.timeout, time.Now())
+		nr = newSyncRequest(pi.handle, pi.responseCh, pi.isQuery, 0, pi.timeout, time.Now())
+		nr.stream = pi.stream
+	}
+	nr.proc = pi.query
+	if v, ok := nc.serverVersion(); ok {
+		if err := checkParamSupport(v, pi.params); err != nil {
+			verr := VoltError{voltResponse: emptyVoltResponseInfo(), error: err}
+			nc.finished(nr, -1, verr)
+			nc.failRequest(nr, verr)
+			return
+		}
+	}
+	if nc.opts.ValidateUTF8 {
+		if err := checkUTF8(pi.params); err != nil {
+			verr := VoltError{voltResponse: emptyVoltResponseInfo(), error: err}
+			nc.finished(nr, -1, verr)
+			nc.failRequest(nr, verr)
+			return
+		}
 	}
-	(*requests)[pi.handle] = nr
-	*queuedBytes += pi.slen
 	nc.encoder.Reset()
-	EncodePI(nc.encoder, pi)
-	writer.Write(nc.encoder.Bytes())
+	if err := EncodePI(nc.encoder, pi); err != nil {
+		// nothing is sent, the parameters can't be encoded.
+		nc.encoder.Reset()
+		verr := VoltError{voltResponse: emptyVoltResponseInfo(), error: err}
+		nc.finished(nr, -1, verr)
+		nc.failRequest(nr, verr)
+		return
+	}
+	// the length is known once the invocation is encoded.
+	nr.numBytes = pi.getLen()
+	// the encoder is reused for the next invocation, it's only reset once the
+	// write returned.
+	n, err := nc.write(writer, nc.encoder)
+	if err != nil {
+		nc.encoder.Reset()
+		// a partly written invocation leaves the stream corrupt, closing the
+		// connection makes the listener report it lost.
+		if c, ok := writer.(io.Closer); ok {
+			c.Close()
+		}
+		verr := VoltError{voltResponse: voltResponseInfo{status: ConnectionLost, clusterRoundTripTime: -1}, error: err}
+		nc.finished(nr, -1, verr)
+		nc.failRequest(nr, verr)
+		return
+	}
+	(*requests)[pi.handle] = nr
+	*queuedBytes += nr.numBytes
+	atomic.StoreInt64(&nc.lastWrite, time.Now().UnixNano())
+	nc.setInFlight(len(*requests))
+	metrics := nc.opts.metrics()
+	metrics.CallStarted(pi.query)
+	metrics.BytesSent(int(n))
+	nc.sent(n)
 	nc.encoder.Reset()
 }
 
-func (nc *nodeConn) handleSyncResponse(handle int64, r io.Reader, req *networkRequest) {
-	respCh := req.getChan()
-	nc.decoder.SetReader(r)
-	defer nc.decoder.Reset()
-	rsp, err := decodeResponse(nc.decoder, handle)
-	if err != nil {
-		respCh <- err.(voltResponse)
-	} else if req.isQuery() {
-
-		if rows, err := decodeRows(nc.decoder, rsp); err != nil {
-			respCh <- err.(voltResponse)
-		} else {
-			respCh <- rows
-		}
+func (nc *nodeConn) handleSyncResponse(handle int64, r io.Reader, raw []byte, req *networkRequest) {
+	var d *wire.Decoder
+	if req.isStream() {
+		// the rows are decoded by the caller, it needs a decoder of its own.
+		d = wire.NewDecoder(r)
 	} else {
-
-		if result, err := decodeResult(nc.decoder, rsp); err != nil {
-			respCh <- err.(voltResponse)
-		} else {
-			respCh <- result
-		}
+		nc.decoder.SetReader(r)
+		defer nc.decoder.Reset()
+		d = nc.decoder
 	}
+	rsp, err := nc.decodeFor(d, handle, raw, req)
+	if err != nil {
+		req.getChan() <- err.(voltResponse)
+		return
+	}
+	req.getChan() <- rsp
+}
 
+func (nc *nodeConn) handleAsyncResponse(handle int64, r io.Reader, raw []byte, req *networkRequest) {
+	rsp, err := nc.decodeFor(wire.NewDecoder(r), handle, raw, req)
+	switch {
+	case err != nil:
+		req.arc.ConsumeError(err)
+	case req.isQuery():
+		req.arc.ConsumeRows(rsp.(VoltRows))
+	default:
+		req.arc.ConsumeResult(rsp.(VoltResult))
+	}
 }
 
-func (nc *nodeConn) handleAsyncResponse(handle int64, r io.Reader, req *networkRequest) {
-	d := wire.NewDecoder(r)
+// decodeFor decodes the response to req from d, the latency of req is recorded
+// in the response, the statistics and the metrics. raw is kept in the response
+// when it is not nil, see ConnectOptions.KeepRawResponses.
+func (nc *nodeConn) decodeFor(d *wire.Decoder, handle int64, raw []byte, req *networkRequest) (voltResponse, error) {
+	latency := time.Since(req.submitted)
 	rsp, err := decodeResponse(d, handle)
-	if err != nil {
-		req.arc.ConsumeError(err)
-	} else if req.isQuery() {
-		if rows, err := decodeRows(d, rsp); err != nil {
-			req.arc.ConsumeError(err)
-		} else {
-			req.arc.ConsumeRows(rows)
+	if verr, ok := err.(VoltError); ok && raw != nil {
+		if info, ok := verr.voltResponse.(voltResponseInfo); ok {
+			info.raw = raw
+			verr.voltResponse = info
+			err = verr
 		}
-	} else {
-		if result, err := decodeResult(d, rsp); err != nil {
-			req.arc.ConsumeError(err)
-		} else {
-			req.arc.ConsumeResult(result)
+	}
+	if err == nil {
+		info := rsp.(voltResponseInfo)
+		info.latency = latency
+		info.raw = raw
+		switch {
+		case req.isStream():
+			rsp = newRowStream(info, d)
+		case req.isQuery():
+			rsp, err = decodeRows(d, info)
+		default:
+			rsp, err = decodeResult(d, info)
 		}
 	}
+	nc.finished(req, latency, err)
+	return rsp, err
 }
 
-func (nc *nodeConn) handleAsyncTimeout(req *networkRequest) {
+// finished records the outcome of req in the statistics and the metrics, a
+// negative latency means no response was received.
+func (nc *nodeConn) finished(req *networkRequest, latency time.Duration, err error) {
+	if nc.stats != nil {
+		nc.stats.record(req.proc, latency, err != nil)
+	}
+	nc.opts.metrics().CallFinished(req.proc, latency, err)
+	if err != nil {
+		nc.opts.logger().Debugf("call to %s failed: %v", req.proc, err)
+	}
+}
+
+func (nc *nodeConn) handleTimeout(req *networkRequest) {
 	err := errors.New("timeout")
 	verr := VoltError{voltResponse: emptyVoltResponseInfo(), error: err}
+	nc.finished(req, -1, verr)
+	nc.failRequest(req, verr)
+}
+
+// failRequest delivers verr as the response to req.
+func (nc *nodeConn) failRequest(req *networkRequest, verr VoltError) {
+	if req.isSync() {
+		// the channel is buffered, the caller may have stopped waiting already.
+		select {
+		case req.getChan() <- verr:
+		default:
+		}
+		return
+	}
 	req.arc.ConsumeError(verr)
 }
 
+// failPending fails the requests in flight and the invocations waiting to be
+// sent when the connection is closed.
+func (nc *nodeConn) failPending(requests map[int64]*networkRequest, piCh <-chan *procedureInvocation) {
+	verr := connectionClosedError()
+	for _, req := range requests {
+		nc.finished(req, -1, verr)
+		nc.failRequest(req, verr)
+	}
+	for {
+		select {
+		case pi := <-nc.ncPiCh:
+			failInvocation(pi, verr)
+		case pi := <-piCh:
+			failInvocation(pi, verr)
+		default:
+			return
+		}
+	}
+}
+
+// failInvocation delivers verr as the response to pi, which wasn't sent.
+func failInvocation(pi *procedureInvocation, verr VoltError) {
+	if pi.isAsync() {
+		pi.arc.ConsumeError(verr)
+		return
+	}
+	pi.responseCh <- verr
+}
+
+// connectionClosedError is the error of requests that weren't answered before
+// the connection was closed.
+func connectionClosedError() VoltError {
+	return VoltError{voltResponse: voltResponseInfo{status: ConnectionLost, clusterRoundTripTime: -1}, error: errConnClosed}
+}
+
+// connectionLostError is the error of requests that can't be sent because the
+// connection to the server was lost.
+func connectionLostError() VoltError {
+	return VoltError{voltResponse: voltResponseInfo{status: ConnectionLost, clusterRoundTripTime: -1}, error: errConnectionLost}
+}
+
 func (nc *nodeConn) sendPing(writer io.Writer) {
 	pi := newProcedureInvocationByHandle(PingHandle, true, "@Ping", []driver.Value{})
 	nc.encoder.Reset()
 	EncodePI(nc.encoder, pi)
-	writer.Write(nc.encoder.Bytes())
+	n, _ := nc.write(writer, nc.encoder)
+	nc.opts.metrics().BytesSent(int(n))
+	nc.sent(n)
 	nc.encoder.Reset()
 }
 
+// sent counts an invocation of n bytes written to the server.
+func (nc *nodeConn) sent(n int64) {
+	atomic.AddInt64(&nc.bytesSent, n)
+	atomic.AddInt64(&nc.callsSent, 1)
+}
+
 // AsyncResponseConsumer is a type that consumes responses from asynchronous
 // Queries and Execs.
 // In the VoltDB go client, asynchronous requests are continuously processed by
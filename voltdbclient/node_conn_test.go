@@ -1,13 +1,19 @@
 package voltdbclient
 
 import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestNodeConn_Close(t *testing.T) {
 	conn := "localhost:21212"
-	c := newNodeConn(conn, nil)
+	c := newNodeConn(conn, nil, ConnectOptions{})
 	i := make(chan *procedureInvocation)
 	err := c.connect(1, i)
 	if err != nil {
@@ -21,7 +27,7 @@ func TestNodeConn_Close(t *testing.T) {
 
 		// To make sure the connection is closed we are sending a small chunk of
 		// insignificant payload
-		_, err := c.tcpConn.Write([]byte("hello"))
+		_, err := c.conn.Write([]byte("hello"))
 		if err == nil {
 			t.Fatal("expected an error")
 		}
@@ -31,3 +37,128 @@ func TestNodeConn_Close(t *testing.T) {
 		}
 	}
 }
+
+func TestNodeConn_HandleTimeoutSync(t *testing.T) {
+	nc := newNodeConn("localhost:21212", nil, ConnectOptions{})
+	ch := make(chan voltResponse, 1)
+	req := newSyncRequest(1, ch, true, 0, time.Millisecond, time.Now())
+	nc.handleTimeout(req)
+	select {
+	case rsp := <-ch:
+		if _, ok := rsp.(VoltError); !ok {
+			t.Errorf("expected VoltError got %T", rsp)
+		}
+	default:
+		t.Fatal("expected a timeout error")
+	}
+	// a second timeout must not block when nobody reads the channel.
+	ch <- VoltError{}
+	nc.handleTimeout(req)
+}
+
+// failingConn is a connection whose writes fail after writing n bytes.
+type failingConn struct {
+	n      int
+	closed bool
+}
+
+func (c *failingConn) Write(b []byte) (int, error) {
+	if len(b) > c.n {
+		return c.n, io.ErrShortWrite
+	}
+	return len(b), nil
+}
+
+func (c *failingConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestNodeConn_WriteError(t *testing.T) {
+	nc := newNodeConn("localhost:21212", nil, ConnectOptions{})
+	ch := make(chan voltResponse, 1)
+	pi := newSyncProcedureInvocation(1, true, "proc", []driver.Value{int64(1)}, ch, time.Second)
+	conn := &failingConn{n: 3}
+	requests := make(map[int64]*networkRequest)
+	var queuedBytes int
+	nc.handleProcedureInvocation(conn, pi, &requests, &queuedBytes)
+	select {
+	case rsp := <-ch:
+		verr, ok := rsp.(VoltError)
+		if !ok {
+			t.Fatalf("expected VoltError got %T", rsp)
+		}
+		if verr.error != io.ErrShortWrite || verr.Status() != ConnectionLost {
+			t.Errorf("unexpected error %v status %v", verr.error, verr.Status())
+		}
+	default:
+		t.Fatal("expected the write error")
+	}
+	if len(requests) != 0 || queuedBytes != 0 {
+		t.Errorf("expected the request not to be registered, got %d requests %d bytes", len(requests), queuedBytes)
+	}
+	if !conn.closed {
+		t.Error("expected the connection to be closed")
+	}
+}
+
+func TestNodeConn_WriteTimeout(t *testing.T) {
+	nc := newNodeConn("localhost:21212", nil, ConnectOptions{WriteTimeout: 50 * time.Millisecond})
+	ch := make(chan voltResponse, 1)
+	pi := newSyncProcedureInvocation(1, true, "proc", []driver.Value{int64(1)}, ch, time.Second)
+	// nothing reads from the other end of the pipe, the write blocks.
+	client, server := net.Pipe()
+	defer server.Close()
+	requests := make(map[int64]*networkRequest)
+	var queuedBytes int
+	done := make(chan struct{})
+	go func() {
+		nc.handleProcedureInvocation(client, pi, &requests, &queuedBytes)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the write to time out")
+	}
+	rsp := <-ch
+	verr, ok := rsp.(VoltError)
+	if !ok {
+		t.Fatalf("expected VoltError got %T", rsp)
+	}
+	if ne, ok := verr.error.(net.Error); !ok || !ne.Timeout() {
+		t.Errorf("expected a timeout error got %v", verr.error)
+	}
+	if len(requests) != 0 {
+		t.Errorf("expected the request not to be registered, got %d requests", len(requests))
+	}
+}
+
+func TestNodeConn_LostDuringCall(t *testing.T) {
+	var s *stubServer
+	s = newStubServer(t, func(inv stubInvocation) []byte {
+		// the server dies halfway through writing the response.
+		go s.close()
+		return stubResponse(inv.handle)[:10]
+	})
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, err = conn.CallContext(context.Background(), "PROC")
+	var lerr ConnectionLostDuringCallError
+	if !errors.As(err, &lerr) {
+		t.Fatalf("expected ConnectionLostDuringCallError got %v", err)
+	}
+	if lerr.Addr != s.addr() || lerr.Err == nil {
+		t.Errorf("unexpected address %s or reason %v", lerr.Addr, lerr.Err)
+	}
+	if verr, ok := err.(VoltError); !ok || verr.Status() != ConnectionLost {
+		t.Errorf("expected a VoltError with the ConnectionLost status got %v", err)
+	}
+	if !strings.Contains(err.Error(), "may or may not have been executed") {
+		t.Errorf("unexpected message %q", err)
+	}
+}
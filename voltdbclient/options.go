@@ -0,0 +1,420 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2017 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/VoltDB/voltdb-client-go/wire"
+)
+
+// ConnectOptions configures the connections to the VoltDB servers. The zero
+// value connects the way OpenConn does.
+type ConnectOptions struct {
+
+	// TLSConfig enables TLS on the client port when it is not nil, the
+	// connection is wrapped with TLS before logging in. The server certificate
+	// is verified against TLSConfig.RootCAs, or the system roots when it is
+	// nil. Set TLSConfig.InsecureSkipVerify to connect to servers using self
+	// signed certificates, such as development clusters.
+	TLSConfig *tls.Config
+
+	// ReconnectPolicy controls how a connection to a server is reestablished
+	// after it is lost. DefaultReconnectPolicy is used when it is nil.
+	ReconnectPolicy *ReconnectPolicy
+
+	// RetryPolicy controls how calls made with CallIdempotent are retried.
+	// DefaultRetryPolicy is used when it is nil.
+	RetryPolicy *RetryPolicy
+
+	// AuthScheme is the algorithm the password is hashed with when logging
+	// in. AuthDefault picks the scheme the protocol version calls for,
+	// clusters configured for SHA-1 need AuthSHA1.
+	AuthScheme AuthScheme
+
+	// PasswordHash is the precomputed digest of the password, for callers
+	// that do not have the plaintext. When set it is sent instead of hashing
+	// the password of the connection url, its size must match the digest of
+	// the AuthScheme in use: 20 bytes for SHA-1 and 32 for SHA-256.
+	PasswordHash []byte
+
+	// Service is the service to log in to, "database" when empty. Export
+	// clients log in to the "export" service.
+	Service string
+
+	// KeepRawResponses keeps the message of each response, it is returned by
+	// the Raw method of the response for decoding parts of it the client
+	// doesn't. The messages are kept in memory as long as their responses
+	// are, which is why it is off by default.
+	KeepRawResponses bool
+
+	// PingInterval is how long a connection may be idle before a @Ping is
+	// sent to keep it from being closed by the server, DefaultPingInterval is
+	// used when it is 0. A connection whose ping isn't answered within three
+	// intervals is considered lost.
+	PingInterval time.Duration
+
+	// DisablePing turns off pinging idle connections.
+	DisablePing bool
+
+	// Metrics receives the call, traffic and reconnection events of the
+	// connections when it is not nil.
+	Metrics Metrics
+
+	// Logger receives the connection events, such as logins, lost
+	// connections and reconnects, when it is not nil.
+	Logger Logger
+
+	// Tracer traces the calls made with a context when it is not nil.
+	Tracer Tracer
+
+	// DialTimeout is how long establishing the TCP connection to a server may
+	// take, there is no timeout when it is 0. A server that can't be reached
+	// fails with a DialError.
+	DialTimeout time.Duration
+
+	// DialContext establishes the TCP connection to a server when it is not
+	// nil, for instance to tunnel through a SOCKS5 proxy with
+	// golang.org/x/net/proxy. It is called with "tcp" and the host:port of
+	// the server as it appears in the connection url, which is not resolved
+	// locally. The context expires after DialTimeout when it is set. TLS and
+	// logging in happen on the returned connection.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// DisableTCPNoDelay turns Nagle's algorithm back on for the TCP
+	// connections to the servers. TCP_NODELAY is set by default so small
+	// calls are sent right away instead of being delayed to be coalesced.
+	DisableTCPNoDelay bool
+
+	// LoginTimeout is how long the TLS handshake and logging in to a server
+	// may take once connected, there is no timeout when it is 0. A server that
+	// doesn't answer in time fails with a LoginError.
+	LoginTimeout time.Duration
+
+	// ReadTimeout is how long the server may stay silent while calls are
+	// waiting for a response, there is no timeout when it is 0. An idle
+	// connection is never timed out. When the timeout expires the connection
+	// is considered lost and the calls in flight fail with a
+	// ConnectionTimeout status.
+	ReadTimeout time.Duration
+
+	// WriteTimeout is how long writing a call to the server may take, there
+	// is no timeout when it is 0. A call that can't be written in time fails
+	// and the connection is considered lost.
+	WriteTimeout time.Duration
+
+	// StrictParams checks the parameters of the calls made with a context
+	// against the procedure catalog before they are sent, a call with the
+	// wrong number of parameters or a parameter of the wrong type fails
+	// without a round trip to the server. The catalog is loaded the first time
+	// a procedure is called, see LoadProcedureCatalog. System procedures
+	// aren't checked.
+	StrictParams bool
+
+	// MaxStringLength is the maximum length in bytes of VARCHAR parameters,
+	// a call with a longer string fails with a wire.StringLengthError without
+	// being sent. wire.DefaultMaxStringLen is used when it is 0, a negative
+	// value sends strings of any length for the server to check.
+	MaxStringLength int
+
+	// MapAsJSON sends parameters that are maps with string keys, such as a
+	// map[string]interface{}, as a VARCHAR holding their JSON encoding.
+	// VoltDB has no map type, calls with a map parameter fail without being
+	// sent when it isn't set.
+	MapAsJSON bool
+
+	// ValidateUTF8 checks that the string parameters, VARCHAR in VoltDB, are
+	// valid UTF-8 before a call is sent. A call with an invalid string fails
+	// with an error naming the index of the parameter, binary data has to be
+	// sent as []byte. Strings read from a wire.StreamValue aren't checked.
+	ValidateUTF8 bool
+
+	// OnConnEvent is called when a server is connected to, when the
+	// connection to it is lost and when it is reconnected, to react to
+	// reconnects beyond logging them. The events are delivered in order from
+	// a goroutine of their own, a slow callback delays the events that follow
+	// but not the connections.
+	OnConnEvent func(ConnEvent)
+
+	// MaxConcurrentCalls caps the number of calls outstanding over the
+	// connection, synchronous and asynchronous ones alike, there is no cap
+	// when it is 0. MaxConcurrentProcCalls caps them per procedure in the same
+	// way. A call made at the cap waits for an outstanding call to be
+	// answered, or until its context is done. Calls made without a context,
+	// such as AsyncCall and Exec, wait at most their timeout and fail with a
+	// ConnectionTimeout error. With FailAtCallCap set a call at the cap fails
+	// with a CallCapError instead, asynchronous calls deliver it to their
+	// consumer.
+	MaxConcurrentCalls     int
+	MaxConcurrentProcCalls int
+	FailAtCallCap          bool
+}
+
+// DefaultPingInterval is the idle time after which a connection is pinged.
+const DefaultPingInterval = 40 * time.Second
+
+// AuthScheme selects the algorithm the password is hashed with when logging in.
+type AuthScheme int
+
+// The password hashing schemes.
+const (
+	AuthDefault AuthScheme = iota
+	AuthSHA1
+	AuthSHA256
+)
+
+// hashScheme returns the wire hash scheme to log in with protocol version.
+func (s AuthScheme) hashScheme(version int) wire.HashScheme {
+	switch s {
+	case AuthSHA1:
+		return wire.HashSHA1
+	case AuthSHA256:
+		return wire.HashSHA256
+	}
+	return wire.DefaultHashScheme(version)
+}
+
+// ReconnectPolicy controls reconnecting to a server after the connection to it
+// is lost. Requests that are in flight when the connection is lost fail with a
+// ConnectionLost status, new requests wait for the connection to be
+// reestablished.
+//
+// The delay before each attempt grows exponentially from BaseDelay up to
+// MaxDelay. Once MaxRetries attempts have failed, requests meant for that
+// server fail.
+type ReconnectPolicy struct {
+
+	// MaxRetries is the number of attempts to reconnect, a negative value
+	// retries forever and 0 doesn't reconnect at all.
+	MaxRetries int
+
+	// BaseDelay is the delay before the first attempt.
+	BaseDelay time.Duration
+
+	// MaxDelay is the maximum delay between attempts.
+	MaxDelay time.Duration
+}
+
+// DefaultReconnectPolicy retries forever, waiting at most 5 seconds between
+// attempts.
+var DefaultReconnectPolicy = ReconnectPolicy{
+	MaxRetries: -1,
+	BaseDelay:  100 * time.Millisecond,
+	MaxDelay:   5 * time.Second,
+}
+
+// delay returns how long to wait before the given attempt, attempts count from
+// 0.
+func (p ReconnectPolicy) delay(attempt int) time.Duration {
+	return backoff(p.BaseDelay, p.MaxDelay, attempt)
+}
+
+// backoff returns the delay before the given attempt, it doubles from base
+// with each attempt up to max.
+func backoff(base, max time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 0; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+func (opts ConnectOptions) reconnectPolicy() ReconnectPolicy {
+	if opts.ReconnectPolicy == nil {
+		return DefaultReconnectPolicy
+	}
+	return *opts.ReconnectPolicy
+}
+
+// RetryPolicy controls retrying the calls made with CallIdempotent. A call is
+// retried when the connection to the server is lost, whether or not the call
+// was sent, or when the server answers that it is unavailable or the
+// transaction needs to be restarted.
+//
+// The delay before each retry grows exponentially from BaseDelay up to
+// MaxDelay. Once MaxRetries retries have failed, the error of the last attempt
+// is returned.
+type RetryPolicy struct {
+
+	// MaxRetries is the number of times a call is retried, a negative value
+	// retries until the context of the call is done and 0 doesn't retry.
+	MaxRetries int
+
+	// BaseDelay is the delay before the first retry.
+	BaseDelay time.Duration
+
+	// MaxDelay is the maximum delay between retries.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy retries a call 3 times, waiting at most a second between
+// attempts.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	BaseDelay:  100 * time.Millisecond,
+	MaxDelay:   time.Second,
+}
+
+// delay returns how long to wait before the given retry, retries count from 0.
+func (p RetryPolicy) delay(retry int) time.Duration {
+	return backoff(p.BaseDelay, p.MaxDelay, retry)
+}
+
+func (opts ConnectOptions) retryPolicy() RetryPolicy {
+	if opts.RetryPolicy == nil {
+		return DefaultRetryPolicy
+	}
+	return *opts.RetryPolicy
+}
+
+func (opts ConnectOptions) metrics() Metrics {
+	if opts.Metrics == nil {
+		return nopMetrics{}
+	}
+	return opts.Metrics
+}
+
+func (opts ConnectOptions) logger() Logger {
+	if opts.Logger == nil {
+		return nopLogger{}
+	}
+	return opts.Logger
+}
+
+func (opts ConnectOptions) tracer() Tracer {
+	if opts.Tracer == nil {
+		return nopTracer{}
+	}
+	return opts.Tracer
+}
+
+func (opts ConnectOptions) pingInterval() time.Duration {
+	if opts.PingInterval <= 0 {
+		return DefaultPingInterval
+	}
+	return opts.PingInterval
+}
+
+// OpenConnWithOptions returns a new connection to the VoltDB server, the
+// connection string is the same as for OpenConn. The connections to the
+// servers are configured by opts.
+func OpenConnWithOptions(ci string, opts ConnectOptions) (*Conn, error) {
+	cis := strings.Split(ci, ",")
+	return newConn(cis, opts)
+}
+
+// Connect returns a new connection to the VoltDB servers at addr, logging in
+// as user with password pass. addr holds one or more host:port addresses
+// separated by commas, the port defaults to 21212. No credentials are sent
+// when user is empty. The connections are configured by opts, set its
+// DialTimeout and LoginTimeout to fail fast on servers that don't answer.
+//
+// A server that can't be reached fails with a DialError, one that doesn't
+// complete the login with a LoginError and one rejecting the credentials with
+// a wire.AuthError.
+func Connect(addr, user, pass string, opts ConnectOptions) (*Conn, error) {
+	hosts := strings.Split(addr, ",")
+	cis := make([]string, len(hosts))
+	for i, host := range hosts {
+		u := url.URL{Scheme: "voltdb", Host: strings.TrimSpace(host)}
+		if user != "" {
+			u.User = url.UserPassword(user, pass)
+		}
+		cis[i] = u.String()
+	}
+	return newConn(cis, opts)
+}
+
+// ConnectAny connects to the first of hosts that accepts the login of user
+// with password pass, the hosts are tried in the order given, shuffle them to
+// spread clients over a cluster. Each host is a host:port address connected to
+// as with Connect, the returned connection only uses that server.
+//
+// ConnectAny gives up when ctx is done and returns its error. When no host
+// can be connected to the error is a ConnectAnyError holding the reason each
+// host failed.
+func ConnectAny(ctx context.Context, hosts []string, user, pass string, opts ConnectOptions) (*Conn, error) {
+	type result struct {
+		conn *Conn
+		err  error
+	}
+	cerr := ConnectAnyError{Hosts: hosts, Errs: make([]error, 0, len(hosts))}
+	for _, host := range hosts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		resCh := make(chan result, 1)
+		go func(host string) {
+			conn, err := Connect(host, user, pass, opts)
+			resCh <- result{conn, err}
+		}(host)
+		select {
+		case res := <-resCh:
+			if res.err == nil {
+				return res.conn, nil
+			}
+			cerr.Errs = append(cerr.Errs, res.err)
+		case <-ctx.Done():
+			// a connection established after giving up is not used.
+			go func() {
+				if res := <-resCh; res.err == nil {
+					res.conn.Close()
+				}
+			}()
+			return nil, ctx.Err()
+		}
+	}
+	return nil, cerr
+}
+
+// ConnectAnyError is returned by ConnectAny when none of the hosts can be
+// connected to, Errs holds the error of each of Hosts in the same order.
+type ConnectAnyError struct {
+	Hosts []string
+	Errs  []error
+}
+
+func (e ConnectAnyError) Error() string {
+	if len(e.Errs) == 0 {
+		return "voltdbclient: failed to connect, no hosts given"
+	}
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = fmt.Sprintf("%s: %v", e.Hosts[i], err)
+	}
+	return "voltdbclient: failed to connect to any host, " + strings.Join(msgs, "; ")
+}
+
+// tlsConfigFor returns the TLS configuration to use for a connection to host.
+// The server name is set to host unless cfg names a server already.
+func tlsConfigFor(cfg *tls.Config, host string) *tls.Config {
+	c := cfg.Clone()
+	if c.ServerName == "" {
+		c.ServerName = host
+	}
+	return c
+}
@@ -0,0 +1,845 @@
+package voltdbclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VoltDB/voltdb-client-go/wire"
+)
+
+// newTestCert returns a self signed certificate for 127.0.0.1.
+func newTestCert(t *testing.T) (tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "voltdb test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, cert
+}
+
+func TestOpenConnWithOptions_TLS(t *testing.T) {
+	cert, x509Cert := newTestCert(t)
+	s := newTLSStubServer(t, cert, echoHandler)
+	defer s.close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(x509Cert)
+	conn, err := OpenConnWithOptions(s.url(), ConnectOptions{TLSConfig: &tls.Config{RootCAs: roots}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp := <-mustAsyncCall(t, conn, "ECHO")
+	if rsp.Err != nil {
+		t.Error(rsp.Err)
+	}
+	conn.Close()
+
+	// the certificate isn't signed by a trusted root
+	if _, err = OpenConnWithOptions(s.url(), ConnectOptions{TLSConfig: &tls.Config{}}); err == nil {
+		t.Error("expected the certificate verification to fail")
+	}
+
+	conn, err = OpenConnWithOptions(s.url(), ConnectOptions{TLSConfig: &tls.Config{InsecureSkipVerify: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
+
+func TestReconnectPolicy_delay(t *testing.T) {
+	p := ReconnectPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	expected := []time.Duration{10, 20, 40, 50, 50}
+	for i, e := range expected {
+		if d := p.delay(i); d != e*time.Millisecond {
+			t.Errorf("attempt %d: expected %v got %v", i, e*time.Millisecond, d)
+		}
+	}
+}
+
+func TestAuthScheme_hashScheme(t *testing.T) {
+	sample := []struct {
+		scheme  AuthScheme
+		version int
+		exp     wire.HashScheme
+	}{
+		{AuthDefault, 0, wire.HashSHA1},
+		{AuthDefault, 1, wire.HashSHA256},
+		{AuthSHA1, 1, wire.HashSHA1},
+		{AuthSHA256, 0, wire.HashSHA256},
+	}
+	for _, s := range sample {
+		if v := s.scheme.hashScheme(s.version); v != s.exp {
+			t.Errorf("scheme %d version %d: expected %d got %d", s.scheme, s.version, s.exp, v)
+		}
+	}
+}
+
+func TestOpenConn_AuthError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		if _, err := wire.NewDecoder(c).Message(); err != nil {
+			return
+		}
+		e := wire.NewEncoder()
+		e.Byte(0) // version
+		e.Byte(wire.AuthFailure)
+		c.Write(e.Message(e.Bytes()))
+	}()
+	_, err = OpenConn("voltdb://" + ln.Addr().String())
+	aerr, ok := err.(wire.AuthError)
+	if !ok {
+		t.Fatalf("expected AuthError got %v", err)
+	}
+	if aerr.Code != wire.AuthFailure {
+		t.Errorf("expected code %d got %d", wire.AuthFailure, aerr.Code)
+	}
+}
+
+func TestOpenConn_IncompleteLogin(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		if _, err := wire.NewDecoder(c).Message(); err != nil {
+			return
+		}
+		// a successful login cut short after the auth code.
+		e := wire.NewEncoder()
+		e.Byte(0) // version
+		e.Byte(0) // auth code
+		e.Int32(1)
+		b := e.Message(e.Bytes())
+		b[3] = 30
+		c.Write(b)
+	}()
+	_, err = OpenConn("voltdb://" + ln.Addr().String())
+	lerr, ok := err.(LoginError)
+	if !ok {
+		t.Fatalf("expected LoginError got %v", err)
+	}
+	if _, ok := lerr.Err.(wire.IncompleteLoginError); !ok {
+		t.Errorf("expected IncompleteLoginError got %v", lerr.Err)
+	}
+	if !strings.Contains(err.Error(), "incomplete login response from server") {
+		t.Errorf("unexpected message %q", err)
+	}
+}
+
+// capturingLogger records the logged lines prefixed with their level.
+type capturingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *capturingLogger) log(level, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, level+" "+fmt.Sprintf(format, args...))
+}
+
+func (l *capturingLogger) Debugf(format string, args ...interface{}) { l.log("debug", format, args...) }
+func (l *capturingLogger) Infof(format string, args ...interface{})  { l.log("info", format, args...) }
+func (l *capturingLogger) Warnf(format string, args ...interface{})  { l.log("warn", format, args...) }
+func (l *capturingLogger) Errorf(format string, args ...interface{}) { l.log("error", format, args...) }
+
+func (l *capturingLogger) contains(prefix string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range l.lines {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOpenConnWithOptions_Logger(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		if _, err := wire.NewDecoder(c).Message(); err != nil {
+			return
+		}
+		e := wire.NewEncoder()
+		e.Byte(0) // version
+		e.Byte(wire.AuthFailure)
+		c.Write(e.Message(e.Bytes()))
+	}()
+	logger := &capturingLogger{}
+	_, err = OpenConnWithOptions("voltdb://admin:secret@"+ln.Addr().String(), ConnectOptions{Logger: logger})
+	if _, ok := err.(wire.AuthError); !ok {
+		t.Fatalf("expected AuthError got %v", err)
+	}
+	if !logger.contains("error login to server " + ln.Addr().String() + " failed") {
+		t.Errorf("expected the failed login to be logged, got %v", logger.lines)
+	}
+	for _, line := range logger.lines {
+		if strings.Contains(line, "secret") {
+			t.Errorf("expected the password not to be logged, got %q", line)
+		}
+	}
+
+	s := newStubServer(t, echoHandler)
+	defer s.close()
+	logger = &capturingLogger{}
+	conn, err := OpenConnWithOptions(s.url(), ConnectOptions{Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if !logger.contains("info logged in to server " + s.addr()) {
+		t.Errorf("expected the login to be logged, got %v", logger.lines)
+	}
+}
+
+func TestConnect(t *testing.T) {
+	s := newStubServer(t, echoHandler)
+	defer s.close()
+	opts := ConnectOptions{DialTimeout: time.Second, LoginTimeout: 100 * time.Millisecond}
+	conn, err := Connect(s.addr(), "admin", "secret", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// the login deadline doesn't apply to the established connection.
+	time.Sleep(2 * opts.LoginTimeout)
+	if _, err := conn.CallContext(context.Background(), "ECHO", int64(1)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConnect_DialTimeout(t *testing.T) {
+	// a non routable address, connecting to it hangs until the timeout. Some
+	// networks accept the connection on its behalf, then logging in fails.
+	start := time.Now()
+	opts := ConnectOptions{DialTimeout: 200 * time.Millisecond, LoginTimeout: 200 * time.Millisecond}
+	_, err := Connect("10.255.255.1:21212", "", "", opts)
+	switch err.(type) {
+	case DialError, LoginError:
+	default:
+		t.Fatalf("expected DialError or LoginError got %v", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("expected to fail within the timeout, took %v", d)
+	}
+}
+
+func TestConnect_DialContext(t *testing.T) {
+	s := newStubServer(t, echoHandler)
+	defer s.close()
+	var mu sync.Mutex
+	var addrs []string
+	opts := ConnectOptions{
+		DialTimeout: time.Second,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Error("expected the dial timeout to set a deadline")
+			}
+			mu.Lock()
+			addrs = append(addrs, network+" "+addr)
+			mu.Unlock()
+			var d net.Dialer
+			return d.DialContext(ctx, network, s.addr())
+		},
+	}
+	// the address is passed to the dialer without resolving it.
+	conn, err := Connect("voltdb.invalid:21212", "", "", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.CallContext(context.Background(), "ECHO", int64(1)); err != nil {
+		t.Error(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(addrs) != 1 || addrs[0] != "tcp voltdb.invalid:21212" {
+		t.Errorf("expected the dialer to be called with tcp voltdb.invalid:21212 got %v", addrs)
+	}
+
+	dialErr := errors.New("proxy refused")
+	opts.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, dialErr
+	}
+	_, err = Connect("voltdb.invalid:21212", "", "", opts)
+	if e, ok := err.(DialError); !ok || e.Err != dialErr {
+		t.Errorf("expected DialError wrapping %v got %v", dialErr, err)
+	}
+}
+
+// noDelayConn records the TCP_NODELAY setting of the connection it wraps.
+type noDelayConn struct {
+	*net.TCPConn
+	noDelay chan bool
+}
+
+func (c noDelayConn) SetNoDelay(noDelay bool) error {
+	c.noDelay <- noDelay
+	return c.TCPConn.SetNoDelay(noDelay)
+}
+
+func TestConnect_TCPNoDelay(t *testing.T) {
+	s := newStubServer(t, echoHandler)
+	defer s.close()
+	for _, disable := range []bool{false, true} {
+		noDelay := make(chan bool, 1)
+		opts := ConnectOptions{
+			DisableTCPNoDelay: disable,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				var d net.Dialer
+				conn, err := d.DialContext(ctx, network, addr)
+				if err != nil {
+					return nil, err
+				}
+				return noDelayConn{TCPConn: conn.(*net.TCPConn), noDelay: noDelay}, nil
+			},
+		}
+		conn, err := Connect(s.addr(), "", "", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case v := <-noDelay:
+			if v != !disable {
+				t.Errorf("DisableTCPNoDelay %v: expected TCP_NODELAY %v got %v", disable, !disable, v)
+			}
+		default:
+			t.Errorf("DisableTCPNoDelay %v: expected TCP_NODELAY to be set", disable)
+		}
+		if _, err := conn.CallContext(context.Background(), "ECHO", int64(1)); err != nil {
+			t.Error(err)
+		}
+		conn.Close()
+	}
+}
+
+// deadAddr returns the address of a port nothing listens on.
+func deadAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestConnectAny(t *testing.T) {
+	s := newStubServer(t, echoHandler)
+	defer s.close()
+	dead := []string{deadAddr(t), deadAddr(t)}
+	opts := ConnectOptions{DialTimeout: time.Second}
+	conn, err := ConnectAny(context.Background(), append(dead, s.addr()), "", "", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.CallContext(context.Background(), "ECHO", int64(1)); err != nil {
+		t.Error(err)
+	}
+
+	_, err = ConnectAny(context.Background(), dead, "", "", opts)
+	cerr, ok := err.(ConnectAnyError)
+	if !ok {
+		t.Fatalf("expected ConnectAnyError got %v", err)
+	}
+	if len(cerr.Errs) != len(dead) {
+		t.Fatalf("expected %d errors got %v", len(dead), cerr.Errs)
+	}
+	for i, err := range cerr.Errs {
+		if _, ok := err.(DialError); !ok {
+			t.Errorf("%s: expected DialError got %v", dead[i], err)
+		}
+		if !strings.Contains(cerr.Error(), dead[i]) {
+			t.Errorf("expected %q to name %s", cerr.Error(), dead[i])
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ConnectAny(ctx, []string{s.addr()}, "", "", opts); err != context.Canceled {
+		t.Errorf("expected %v got %v", context.Canceled, err)
+	}
+}
+
+func TestConnect_LoginTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// the server accepts the connection but never answers the login.
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		wire.NewDecoder(c).Message()
+		time.Sleep(2 * time.Second)
+	}()
+	start := time.Now()
+	_, err = Connect(ln.Addr().String(), "", "", ConnectOptions{LoginTimeout: 200 * time.Millisecond})
+	if _, ok := err.(LoginError); !ok {
+		t.Fatalf("expected LoginError got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("expected to fail within the timeout, took %v", d)
+	}
+}
+
+func TestOpenConnWithOptions_ReadTimeout(t *testing.T) {
+	hashConfig, err := ioutil.ReadFile("./test_resources/jsonConfigC.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := newHashinatorElastic(JSONFormat, true, hashConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the system procedures are answered, they would be waiting for a
+	// response otherwise.
+	affinity := affinityHandler(hashConfig, h.tp)
+	system := func(inv stubInvocation) []byte {
+		if inv.proc == "@Subscribe" {
+			return stubResponse(inv.handle)
+		}
+		return affinity(inv)
+	}
+	s := newClusterStubServer(t, 0, system, func(inv stubInvocation) []byte {
+		if inv.proc == "HANG" {
+			return nil
+		}
+		return echoHandler(inv)
+	})
+	defer s.close()
+	conn, err := OpenConnWithOptions(s.url(), ConnectOptions{ReadTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// an idle connection isn't timed out.
+	time.Sleep(300 * time.Millisecond)
+	ctx := context.Background()
+	if _, err = conn.CallContext(ctx, "ECHO", int64(1)); err != nil {
+		t.Fatal(err)
+	}
+	if s.connCount() != 1 {
+		t.Fatalf("expected the idle connection to be kept, got %d connections", s.connCount())
+	}
+
+	start := time.Now()
+	_, err = conn.CallContext(ctx, "HANG")
+	verr, ok := err.(VoltError)
+	if !ok || verr.getStatus() != ConnectionTimeout {
+		t.Fatalf("expected a connection timeout error got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("expected to fail after the timeout, took %v", d)
+	}
+	// the connection that timed out is closed before reconnecting.
+	deadline := time.Now().Add(2 * time.Second)
+	for s.closedCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := s.closedCount(); n != 1 {
+		t.Errorf("expected the timed out connection to be closed, %d connections were closed", n)
+	}
+}
+
+func TestOpenConnWithOptions_Reconnect(t *testing.T) {
+	handler := func(inv stubInvocation) []byte {
+		if inv.proc == "SLOW" {
+			return nil
+		}
+		return echoHandler(inv)
+	}
+	s := newStubServer(t, handler)
+	addr := s.addr()
+	policy := &ReconnectPolicy{MaxRetries: -1, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	conn, err := OpenConnWithOptions(s.url(), ConnectOptions{ReconnectPolicy: policy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if rsp := <-mustAsyncCall(t, conn, "ECHO"); rsp.Err != nil {
+		t.Fatal(rsp.Err)
+	}
+
+	inFlight := mustAsyncCall(t, conn, "SLOW")
+	// give the invocation time to be written before the server goes away.
+	time.Sleep(50 * time.Millisecond)
+	s.close()
+	select {
+	case rsp := <-inFlight:
+		verr, ok := rsp.Err.(VoltError)
+		if !ok || verr.getStatus() != ConnectionLost {
+			t.Errorf("expected a connection lost error got %v", rsp.Err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the in flight call didn't fail")
+	}
+
+	s = newStubServerAt(t, addr, handler)
+	defer s.close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err = conn.CallContext(ctx, "ECHO", int64(1)); err != nil {
+		t.Errorf("expected the call to succeed after reconnecting got %v", err)
+	}
+}
+
+func TestOpenConnWithOptions_OnConnEvent(t *testing.T) {
+	s := newStubServer(t, echoHandler)
+	addr := s.addr()
+	events := make(chan ConnEvent, 10)
+	release := make(chan struct{})
+	onEvent := func(ev ConnEvent) {
+		// a blocked callback must not hold up the connection.
+		<-release
+		events <- ev
+	}
+	policy := &ReconnectPolicy{MaxRetries: -1, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	conn, err := OpenConnWithOptions(s.url(), ConnectOptions{ReconnectPolicy: policy, OnConnEvent: onEvent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err = conn.CallContext(ctx, "ECHO", int64(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	s.close()
+	for conn.State() != Reconnecting {
+		if ctx.Err() != nil {
+			t.Fatalf("expected %v got %v", Reconnecting, conn.State())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s = newStubServerAt(t, addr, echoHandler)
+	defer s.close()
+	if _, err = conn.CallContext(ctx, "ECHO", int64(1)); err != nil {
+		t.Fatalf("expected the call to succeed after reconnecting got %v", err)
+	}
+	close(release)
+
+	for _, want := range []ConnEventType{ServerConnected, ServerLost, ServerReconnected} {
+		select {
+		case ev := <-events:
+			if ev.Type != want || ev.Host != addr {
+				t.Errorf("expected %v of %s got %v of %s", want, addr, ev.Type, ev.Host)
+			}
+			if (ev.Err != nil) != (want == ServerLost) {
+				t.Errorf("%v: unexpected error %v", ev.Type, ev.Err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected a %v event", want)
+		}
+	}
+}
+
+func TestNodeConn_ReconnectGiveUp(t *testing.T) {
+	s := newStubServer(t, echoHandler)
+	policy := &ReconnectPolicy{MaxRetries: 2, BaseDelay: 10 * time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	nc := newNodeConn(s.url(), make(chan *procedureInvocation, 1), ConnectOptions{ReconnectPolicy: policy})
+	if err := nc.connect(ProtocolVersion, make(chan *procedureInvocation)); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { <-nc.close() }()
+	s.close()
+
+	responseCh := make(chan voltResponse, 1)
+	nc.submit(newSyncProcedureInvocation(1, true, "ECHO", nil, responseCh, time.Minute))
+	select {
+	case rsp := <-responseCh:
+		if rsp.getStatus() != ConnectionLost {
+			t.Errorf("expected %v got %v", ConnectionLost, rsp.getStatus())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the invocation to fail once reconnecting gave up")
+	}
+}
+
+func TestOpenConnWithOptions_Ping(t *testing.T) {
+	var pings int32
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		if inv.proc == "@Ping" {
+			atomic.AddInt32(&pings, 1)
+			return stubResponse(inv.handle)
+		}
+		return echoHandler(inv)
+	})
+	defer s.close()
+	conn, err := OpenConnWithOptions(s.url(), ConnectOptions{PingInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&pings) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the idle connection to be pinged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	conn.Close()
+
+	// no pings are sent once the connection is closed.
+	n := atomic.LoadInt32(&pings)
+	time.Sleep(100 * time.Millisecond)
+	if m := atomic.LoadInt32(&pings); m != n {
+		t.Errorf("expected no pings after Close got %d", m-n)
+	}
+}
+
+func TestOpenConnWithOptions_PingUnanswered(t *testing.T) {
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		if inv.proc == "@Ping" {
+			return nil
+		}
+		return echoHandler(inv)
+	})
+	defer s.close()
+	policy := &ReconnectPolicy{MaxRetries: -1, BaseDelay: 10 * time.Millisecond, MaxDelay: 20 * time.Millisecond}
+	conn, err := OpenConnWithOptions(s.url(), ConnectOptions{PingInterval: 20 * time.Millisecond, ReconnectPolicy: policy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// the connection is dropped and reestablished once the ping goes
+	// unanswered for three intervals.
+	deadline := time.Now().Add(5 * time.Second)
+	for s.connCount() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the connection to be reestablished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// countingMetrics is a Metrics counting the events it receives, the calls to
+// the system procedures the client invokes on its own are ignored.
+type countingMetrics struct {
+	mu          sync.Mutex
+	started     int
+	finished    int
+	failed      int
+	sent        int
+	received    int
+	reconnected int
+	hosts       []string
+}
+
+func (m *countingMetrics) CallStarted(proc string) {
+	if strings.HasPrefix(proc, "@") {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started++
+}
+
+func (m *countingMetrics) CallFinished(proc string, latency time.Duration, err error) {
+	if strings.HasPrefix(proc, "@") {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.finished++
+	if err != nil {
+		m.failed++
+	}
+}
+
+func (m *countingMetrics) BytesSent(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent += n
+}
+
+func (m *countingMetrics) BytesReceived(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.received += n
+}
+
+func (m *countingMetrics) Reconnected(host string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reconnected++
+	m.hosts = append(m.hosts, host)
+}
+
+func TestOpenConnWithOptions_Metrics(t *testing.T) {
+	s := newStubServer(t, echoHandler)
+	defer s.close()
+	m := &countingMetrics{}
+	opts := ConnectOptions{
+		Metrics:         m,
+		DisablePing:     true,
+		ReconnectPolicy: &ReconnectPolicy{MaxRetries: -1, BaseDelay: 10 * time.Millisecond, MaxDelay: 10 * time.Millisecond},
+	}
+	conn, err := Connect(s.addr(), "user", "secret", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.CallContext(context.Background(), "ECHO", int64(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.CallContext(context.Background(), "MISSING"); err == nil {
+		t.Fatal("expected MISSING to fail")
+	}
+	// a call that can't be encoded is never sent, it's reported as failed.
+	if _, err := conn.CallContext(context.Background(), "ECHO", struct{}{}); err == nil {
+		t.Fatal("expected an unencodable parameter to fail")
+	}
+	m.mu.Lock()
+	if m.started != 2 || m.finished != 3 || m.failed != 2 {
+		t.Errorf("expected 2 started, 3 finished and 2 failed calls got %d, %d and %d", m.started, m.finished, m.failed)
+	}
+	if m.sent == 0 || m.received == 0 {
+		t.Errorf("expected bytes to be sent and received got %d and %d", m.sent, m.received)
+	}
+	m.mu.Unlock()
+
+	// the host of a reconnection is reported without the credentials.
+	s.dropConns()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		m.mu.Lock()
+		if m.reconnected > 0 || time.Now().After(deadline) {
+			break
+		}
+		m.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if m.reconnected != 1 || len(m.hosts) != 1 || m.hosts[0] != s.addr() {
+		t.Errorf("expected 1 reconnection to %s got %d to %v", s.addr(), m.reconnected, m.hosts)
+	}
+	m.mu.Unlock()
+}
+
+// recordingTracer records the calls it traced.
+type recordingTracer struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+type recordingSpan struct {
+	t    *recordingTracer
+	call string
+}
+
+func (t *recordingTracer) StartCall(ctx context.Context, proc string, params int) (context.Context, CallSpan) {
+	return ctx, recordingSpan{t, fmt.Sprintf("%s(%d)", proc, params)}
+}
+
+func (s recordingSpan) End(status ResponseStatus, roundTrip time.Duration, err error) {
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	s.t.calls = append(s.t.calls, fmt.Sprintf("%s %v %v %v", s.call, status, roundTrip, err != nil))
+}
+
+func TestOpenConnWithOptions_Tracer(t *testing.T) {
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		if inv.proc == "FAIL" {
+			return stubErrorResponse(inv.handle, UserAbort, "aborted")
+		}
+		return echoHandler(inv)
+	})
+	defer s.close()
+	tracer := &recordingTracer{}
+	conn, err := OpenConnWithOptions(s.url(), ConnectOptions{Tracer: tracer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := conn.CallContext(ctx, "ECHO", int64(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.CallContext(ctx, "FAIL", int64(1), "two"); err == nil {
+		t.Fatal("expected the call to fail")
+	}
+	// the invocation can't be encoded, the server never answers it.
+	if _, err := conn.CallContext(ctx, "ECHO", struct{ X int }{1}); err == nil {
+		t.Fatal("expected the call to fail")
+	}
+
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	exp := []string{
+		"ECHO(1) SUCCESS 0s false",
+		"FAIL(2) USER ABORT -1ns true",
+		"ECHO(1) RESPONSE UNKNOWN -1ns true",
+	}
+	if len(tracer.calls) != len(exp) {
+		t.Fatalf("expected %v got %v", exp, tracer.calls)
+	}
+	for i := range exp {
+		if tracer.calls[i] != exp[i] {
+			t.Errorf("expected %q got %q", exp[i], tracer.calls[i])
+		}
+	}
+}
@@ -0,0 +1,108 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2017 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"database/sql/driver"
+	"math/big"
+	"time"
+
+	"github.com/VoltDB/voltdb-client-go/wire"
+)
+
+// Params builds the parameters of a call with the VoltDB type of each
+// parameter spelled out, NULLs included:
+//
+//	args := NewParams().Int(5).Str("x").Null(IntegerColumn).Build()
+//	rows, err := conn.CallContext(ctx, "proc", args...)
+//
+// The methods append a parameter and return p, so calls can be chained.
+type Params struct {
+	args []driver.Value
+}
+
+// NewParams returns an empty Params.
+func NewParams() *Params {
+	return &Params{}
+}
+
+// Bool appends a boolean, it is sent as a TINYINT.
+func (p *Params) Bool(v bool) *Params { return p.Value(v) }
+
+// TinyInt appends a TINYINT.
+func (p *Params) TinyInt(v int8) *Params { return p.Value(v) }
+
+// SmallInt appends a SMALLINT.
+func (p *Params) SmallInt(v int16) *Params { return p.Value(v) }
+
+// Int appends an INTEGER.
+func (p *Params) Int(v int32) *Params { return p.Value(v) }
+
+// BigInt appends a BIGINT.
+func (p *Params) BigInt(v int64) *Params { return p.Value(v) }
+
+// Float appends a FLOAT.
+func (p *Params) Float(v float64) *Params { return p.Value(v) }
+
+// Decimal appends a DECIMAL, a nil v is a NULL DECIMAL.
+func (p *Params) Decimal(v *big.Rat) *Params { return p.Value(v) }
+
+// Str appends a VARCHAR.
+func (p *Params) Str(v string) *Params { return p.Value(v) }
+
+// Varbinary appends a VARBINARY, a nil v is a NULL VARBINARY.
+func (p *Params) Varbinary(v []byte) *Params {
+	if v == nil {
+		return p.Null(VarbinaryColumn)
+	}
+	return p.Value(v)
+}
+
+// Timestamp appends a TIMESTAMP.
+func (p *Params) Timestamp(v time.Time) *Params { return p.Value(v) }
+
+// GeographyPoint appends a GEOGRAPHY_POINT.
+func (p *Params) GeographyPoint(v wire.GeographyPoint) *Params { return p.Value(v) }
+
+// Geography appends a GEOGRAPHY.
+func (p *Params) Geography(v wire.GeographyPolygon) *Params { return p.Value(v) }
+
+// Null appends a NULL of column type t.
+func (p *Params) Null(t ColumnType) *Params {
+	return p.Value(wire.NewNullValue(int8(t)))
+}
+
+// Value appends v as it is, it's sent the way CallContext sends it. It is
+// meant for the values the other methods don't cover, such as arrays.
+func (p *Params) Value(v driver.Value) *Params {
+	p.args = append(p.args, v)
+	return p
+}
+
+// Len returns the number of parameters appended.
+func (p *Params) Len() int {
+	return len(p.args)
+}
+
+// Build returns the parameters appended so far. p can be appended to
+// afterwards without changing the returned slice.
+func (p *Params) Build() []driver.Value {
+	args := make([]driver.Value, len(p.args))
+	copy(args, p.args)
+	return args
+}
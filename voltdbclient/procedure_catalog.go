@@ -0,0 +1,358 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2017 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/VoltDB/voltdb-client-go/wire"
+)
+
+// ProcedureParam describes a parameter of a stored procedure.
+type ProcedureParam struct {
+	// Name is the upper cased name of the parameter.
+	Name string
+
+	// Type is the SQL type of the parameter, such as BIGINT or VARCHAR, or
+	// of its elements when it is an array. It's empty when the server didn't
+	// report it.
+	Type string
+
+	// Array is true when the parameter is an array.
+	Array bool
+}
+
+// ProcedureInfo describes how a stored procedure is partitioned, as reported by
+// @SystemCatalog PROCEDURES.
+type ProcedureInfo struct {
+	// SinglePartition is true when the procedure runs in the single partition
+	// its partition parameter hashes to, a multi-partition procedure runs in
+	// every partition and is much more expensive.
+	SinglePartition bool
+
+	// ReadOnly is true when the procedure doesn't modify the database.
+	ReadOnly bool
+
+	// PartitionParameter is the index of the parameter picking the partition
+	// of a single partition procedure, PartitionParameterName its name when
+	// the server reported it and PartitionParameterType its type. The index
+	// is -1 for multi-partition procedures.
+	PartitionParameter     int
+	PartitionParameterName string
+	PartitionParameterType ColumnType
+}
+
+// ProcedureCatalog holds the parameters of the stored procedures of the
+// database as reported by @SystemCatalog PROCEDURECOLUMNS, and their
+// partitioning as reported by @SystemCatalog PROCEDURES.
+type ProcedureCatalog struct {
+	procs map[string][]ProcedureParam
+	infos map[string]ProcedureInfo
+}
+
+// Params returns the parameters of proc in order, ok is false when proc isn't
+// in the catalog.
+func (pc *ProcedureCatalog) Params(proc string) (params []ProcedureParam, ok bool) {
+	params, ok = pc.procs[proc]
+	return params, ok
+}
+
+// Info returns the partitioning of proc, ok is false when proc isn't in the
+// catalog.
+func (pc *ProcedureCatalog) Info(proc string) (info ProcedureInfo, ok bool) {
+	info, ok = pc.infos[proc]
+	return info, ok
+}
+
+// Check returns an error when args can't be the parameters of proc, because
+// their number differs from the number of parameters or an argument has a type
+// the server can't convert to the type of its parameter. NULL arguments are
+// accepted for any parameter.
+func (pc *ProcedureCatalog) Check(proc string, args []driver.Value) error {
+	params, ok := pc.procs[proc]
+	if !ok {
+		return fmt.Errorf("voltdbclient: no parameter metadata for procedure %s", proc)
+	}
+	return checkArgs(proc, params, args)
+}
+
+// checkArgs returns an error when args can't be the parameters params of proc.
+func checkArgs(proc string, params []ProcedureParam, args []driver.Value) error {
+	if len(args) != len(params) {
+		return fmt.Errorf("voltdbclient: procedure %s takes %d parameters, %d were given", proc, len(params), len(args))
+	}
+	for i, p := range params {
+		if !p.accepts(args[i]) {
+			typ := p.Type
+			if p.Array {
+				typ += " array"
+			}
+			return fmt.Errorf("voltdbclient: parameter %s of procedure %s is %s, a %T can't be sent as one", p.Name, proc, typ, args[i])
+		}
+	}
+	return nil
+}
+
+// paramTypes are the column types of the parameter types reported in the
+// TYPE_NAME column of PROCEDURECOLUMNS.
+var paramTypes = map[string]int8{
+	"TINYINT":         wire.TinyIntColumn,
+	"SMALLINT":        wire.ShortColumn,
+	"INTEGER":         wire.IntColumn,
+	"BIGINT":          wire.LongColumn,
+	"FLOAT":           wire.FloatColumn,
+	"DECIMAL":         wire.DecimalColumn,
+	"VARCHAR":         wire.StringColumn,
+	"VARBINARY":       wire.VarBinColumn,
+	"TIMESTAMP":       wire.TimestampColumn,
+	"GEOGRAPHY_POINT": wire.GeographyPointColumn,
+	"GEOGRAPHY":       wire.GeographyColumn,
+}
+
+// paramConversions are the column types the server converts to a parameter
+// type, besides the type itself. Integers are converted when they fit,
+// timestamps may be sent as microseconds and VARBINARY as a hex string.
+var paramConversions = map[int8][]int8{
+	wire.TinyIntColumn:   {wire.ShortColumn, wire.IntColumn, wire.LongColumn},
+	wire.ShortColumn:     {wire.TinyIntColumn, wire.IntColumn, wire.LongColumn},
+	wire.IntColumn:       {wire.TinyIntColumn, wire.ShortColumn, wire.LongColumn},
+	wire.LongColumn:      {wire.TinyIntColumn, wire.ShortColumn, wire.IntColumn},
+	wire.FloatColumn:     {wire.TinyIntColumn, wire.ShortColumn, wire.IntColumn, wire.LongColumn, wire.DecimalColumn},
+	wire.DecimalColumn:   {wire.TinyIntColumn, wire.ShortColumn, wire.IntColumn, wire.LongColumn, wire.FloatColumn},
+	wire.TimestampColumn: {wire.TinyIntColumn, wire.ShortColumn, wire.IntColumn, wire.LongColumn},
+	wire.VarBinColumn:    {wire.StringColumn},
+}
+
+// accepts reports whether v can be sent as the parameter p. Parameters of an
+// unknown type accept any value.
+func (p ProcedureParam) accepts(v driver.Value) bool {
+	want, ok := paramTypes[p.Type]
+	if !ok || v == nil {
+		return true
+	}
+	if _, ok := v.(wire.NullValue); ok {
+		return true
+	}
+	if sv, ok := v.(wire.StreamValue); ok && !p.Array {
+		return convertible(want, sv.ColType())
+	}
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if p.Array {
+		if t.Kind() != reflect.Slice {
+			return false
+		}
+		t = t.Elem()
+	} else if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		return false
+	}
+	got, err := wire.ColumnType(t)
+	if err != nil {
+		return false
+	}
+	return convertible(want, got)
+}
+
+// convertible reports whether a value of column type got can be sent as a
+// parameter of column type want.
+func convertible(want, got int8) bool {
+	if got == want {
+		return true
+	}
+	for _, ct := range paramConversions[want] {
+		if got == ct {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadProcedureCatalog fetches the parameters of the stored procedures with
+// @SystemCatalog PROCEDURECOLUMNS and keeps them for CallNamed and for
+// checking the parameters of calls. The catalog is otherwise loaded the first
+// time it's needed, loading it beforehand saves that call the round trip.
+// Loading it again picks up the procedures created since.
+func (c *Conn) LoadProcedureCatalog(ctx context.Context) (*ProcedureCatalog, error) {
+	c.catalogMu.Lock()
+	defer c.catalogMu.Unlock()
+	return c.loadCatalog(ctx)
+}
+
+// loadCatalog fetches the procedure catalog, catalogMu must be held. The
+// procedures are listed as well, those without parameters have no
+// PROCEDURECOLUMNS rows.
+func (c *Conn) loadCatalog(ctx context.Context) (*ProcedureCatalog, error) {
+	procs, err := c.CallContext(ctx, "@SystemCatalog", "PROCEDURES")
+	if err != nil {
+		return nil, err
+	}
+	columns, err := c.CallContext(ctx, "@SystemCatalog", "PROCEDURECOLUMNS")
+	if err != nil {
+		return nil, err
+	}
+	pc, err := decodeProcedureCatalog(procs.(VoltRows), columns.(VoltRows))
+	if err != nil {
+		return nil, err
+	}
+	c.catalog = pc
+	return pc, nil
+}
+
+// ProcedureInfo returns the partitioning of the stored procedure proc, to tell
+// single partition procedures from the more expensive multi-partition ones.
+// It's read from the procedure catalog, which is loaded when first needed and
+// again when proc isn't in it. LoadProcedureCatalog refreshes the catalog.
+func (c *Conn) ProcedureInfo(ctx context.Context, proc string) (ProcedureInfo, error) {
+	pc, err := c.catalogWith(ctx, proc)
+	if err != nil {
+		return ProcedureInfo{}, err
+	}
+	info, _ := pc.Info(proc)
+	return info, nil
+}
+
+// procParams returns the parameters of proc in order.
+func (c *Conn) procParams(ctx context.Context, proc string) ([]ProcedureParam, error) {
+	pc, err := c.catalogWith(ctx, proc)
+	if err != nil {
+		return nil, err
+	}
+	params, _ := pc.Params(proc)
+	return params, nil
+}
+
+// catalogWith returns the procedure catalog once it holds proc. The catalog is
+// fetched again when proc isn't known, it may have been created since it was
+// last fetched.
+func (c *Conn) catalogWith(ctx context.Context, proc string) (*ProcedureCatalog, error) {
+	c.catalogMu.Lock()
+	defer c.catalogMu.Unlock()
+	if c.catalog != nil {
+		if _, ok := c.catalog.Params(proc); ok {
+			return c.catalog, nil
+		}
+	}
+	pc, err := c.loadCatalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := pc.Params(proc); !ok {
+		return nil, fmt.Errorf("voltdbclient: no parameter metadata for procedure %s", proc)
+	}
+	return pc, nil
+}
+
+// checkParams checks args against the parameters of proc when the connection
+// has StrictParams set. System procedures aren't checked.
+func (c *Conn) checkParams(ctx context.Context, proc string, args []driver.Value) error {
+	if !c.opts.StrictParams || strings.HasPrefix(proc, "@") {
+		return nil
+	}
+	params, err := c.procParams(ctx, proc)
+	if err != nil {
+		return err
+	}
+	return checkArgs(proc, params, args)
+}
+
+// decodeProcedureCatalog reads the procedures from the @SystemCatalog
+// PROCEDURES table and their parameters from the PROCEDURECOLUMNS table. The
+// partitioning of a procedure is a JSON object in the REMARKS of PROCEDURES,
+// procedures without one are taken to be multi-partition.
+func decodeProcedureCatalog(procs, columns VoltRows) (*ProcedureCatalog, error) {
+	type param struct {
+		ProcedureParam
+		position int64
+	}
+	params := make(map[string][]param)
+	partitioning := make(map[string]procedure)
+	for procs.AdvanceRow() {
+		r := sysRow{rows: procs}
+		proc := r.string("PROCEDURE_NAME")
+		remarks := r.string("REMARKS")
+		if r.err != nil {
+			return nil, r.err
+		}
+		if proc == "" {
+			continue
+		}
+		params[proc] = nil
+		var p procedure
+		if remarks != "" {
+			if err := json.Unmarshal([]byte(remarks), &p); err != nil {
+				return nil, fmt.Errorf("voltdbclient: invalid remarks of procedure %s: %v", proc, err)
+			}
+		}
+		partitioning[proc] = p
+	}
+	for columns.AdvanceRow() {
+		r := sysRow{rows: columns}
+		proc := r.string("PROCEDURE_NAME")
+		p := param{
+			ProcedureParam: ProcedureParam{
+				Name:  strings.ToUpper(r.string("COLUMN_NAME")),
+				Type:  r.string("TYPE_NAME"),
+				Array: strings.Contains(r.string("REMARKS"), "ARRAY_PARAMETER"),
+			},
+			position: r.int64("ORDINAL_POSITION"),
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		if proc == "" || p.Name == "" {
+			continue
+		}
+		params[proc] = append(params[proc], p)
+	}
+	pc := &ProcedureCatalog{
+		procs: make(map[string][]ProcedureParam, len(params)),
+		infos: make(map[string]ProcedureInfo, len(params)),
+	}
+	for proc, ps := range params {
+		sort.Slice(ps, func(i, j int) bool { return ps[i].position < ps[j].position })
+		procParams := make([]ProcedureParam, len(ps))
+		for i, p := range ps {
+			procParams[i] = p.ProcedureParam
+		}
+		pc.procs[proc] = procParams
+
+		p := partitioning[proc]
+		info := ProcedureInfo{
+			SinglePartition:    p.SinglePartition,
+			ReadOnly:           p.ReadOnly,
+			PartitionParameter: -1,
+		}
+		if p.SinglePartition {
+			info.PartitionParameter = p.PartitionParameter
+			info.PartitionParameterType = ColumnType(p.PartitionParameterType)
+			if p.PartitionParameter >= 0 && p.PartitionParameter < len(procParams) {
+				info.PartitionParameterName = procParams[p.PartitionParameter].Name
+			}
+		}
+		pc.infos[proc] = info
+	}
+	return pc, nil
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"reflect"
 	"time"
+	"unicode/utf8"
 )
 
 type procedureInvocation struct {
@@ -33,7 +34,12 @@ type procedureInvocation struct {
 	timeout    time.Duration
 	arc        AsyncResponseConsumer
 	async      bool
-	slen       int // length of pi once serialized
+	// stream is set when the rows of the response are read with a RowStream.
+	stream bool
+	// queryTimeout is sent to the server when it's positive, the server
+	// aborts the invocation once it runs longer.
+	queryTimeout time.Duration
+	slen         int // length of pi once serialized
 }
 
 func newSyncProcedureInvocation(handle int64, isQuery bool, query string, params []driver.Value, responseCh chan voltResponse, timeout time.Duration) *procedureInvocation {
@@ -74,59 +80,46 @@ func newProcedureInvocationByHandle(handle int64, isQuery bool, query string, pa
 	}
 }
 
+// getLen returns the length of pi once serialized, without its length prefix.
+// It's known once pi was encoded with EncodePI, -1 before.
 func (pi *procedureInvocation) getLen() int {
-	if pi.slen == -1 {
-		pi.slen = pi.calcLen()
-	}
 	return pi.slen
 }
 
-func (pi *procedureInvocation) calcLen() int {
-	// fixed - 1 for batch timeout type, 4 for str length (proc name),
-	// 8 for handle, 2 for paramCount
-	var slen = 15
-	slen += len(pi.query)
-	for _, param := range pi.params {
-		slen += pi.calcParamLen(param)
+// checkUTF8 returns an error naming the first parameter holding a string that
+// isn't valid UTF-8, strings in arrays and behind pointers included. Binary
+// data has to be sent as []byte instead.
+func checkUTF8(params []driver.Value) error {
+	for i, p := range params {
+		if s, ok := p.(string); ok {
+			if utf8.ValidString(s) {
+				continue
+			}
+		} else if validUTF8(reflect.ValueOf(p)) {
+			continue
+		}
+		return fmt.Errorf("voltdbclient: the string of parameter %d is not valid UTF-8, send binary data as []byte", i)
 	}
-	return slen
+	return nil
 }
 
-func (pi *procedureInvocation) calcParamLen(param interface{}) int {
-	// add one to each because the type itself takes one byte
-	// nil is 1
-	if param == nil {
-		return 1
-	}
-	v := reflect.ValueOf(param)
+func validUTF8(v reflect.Value) bool {
 	switch v.Kind() {
-	case reflect.Bool:
-		return 2
-	case reflect.Int8:
-		return 2
-	case reflect.Int16:
-		return 3
-	case reflect.Int32:
-		return 5
-	case reflect.Int64:
-		return 9
-	case reflect.Float64:
-		return 9
 	case reflect.String:
-		return 5 + v.Len()
+		return utf8.ValidString(v.String())
+	case reflect.Ptr:
+		return v.IsNil() || validUTF8(v.Elem())
 	case reflect.Slice:
-		return 5 + v.Len()
-	case reflect.Struct:
-		if _, ok := v.Interface().(time.Time); ok {
-			return 9
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return true
+		}
+		for i := 0; i < v.Len(); i++ {
+			if !validUTF8(v.Index(i)) {
+				return false
+			}
 		}
-		panic("Can't determine length of struct")
-
-	case reflect.Ptr:
-		panic("Can't marshal a pointer")
-	default:
-		panic(fmt.Sprintf("Can't marshal %v-type parameters", v.Kind()))
 	}
+	return true
 }
 
 func (pi procedureInvocation) getPassedParamCount() int {
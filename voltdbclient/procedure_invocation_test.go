@@ -0,0 +1,185 @@
+package voltdbclient
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VoltDB/voltdb-client-go/wire"
+)
+
+func TestProcedureInvocation_getLen(t *testing.T) {
+	str := "twelve"
+	params := []driver.Value{
+		true, int8(1), int16(2), int32(3), int64(4), uint8(1), uint16(2), uint32(3), uint64(4), float32(4.5), 5.0, "six", []byte("seven"),
+		time.Now(), big.NewRat(8, 1),
+		wire.GeographyPoint{Longitude: 9, Latitude: 10},
+		wire.GeographyPolygon{OuterRing: []wire.GeographyPoint{
+			{Longitude: 0, Latitude: 0}, {Longitude: 1, Latitude: 0},
+			{Longitude: 1, Latitude: 1}, {Longitude: 0, Latitude: 0},
+		}},
+		wire.NewNullValue(wire.IntColumn),
+		wire.NewNullValue(wire.DecimalColumn),
+		(*string)(nil), &str, 11,
+		[]int32{1, 2, 3}, [][]byte{{1}, {2, 3}},
+		wire.FixedVarbinary{Width: 2, Bytes: []byte{1, 2}},
+		map[string]interface{}{"k": "v"},
+	}
+	pi := newProcedureInvocationByHandle(1, true, "proc", params)
+	e := wire.NewEncoder()
+	e.SetMapAsJSON(true)
+	err := EncodePI(e, pi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the length doesn't include the int32 length prefix itself
+	if exp := e.Len() - wire.IntegerSize; pi.getLen() != exp {
+		t.Errorf("expected %d got %d", exp, pi.getLen())
+	}
+}
+
+func TestParams(t *testing.T) {
+	ts := time.Unix(1, 0)
+	pt := wire.GeographyPoint{Longitude: 1, Latitude: 2}
+	params := NewParams().
+		Bool(true).TinyInt(1).SmallInt(2).Int(5).BigInt(4).Float(4.5).
+		Decimal(big.NewRat(8, 1)).Decimal(nil).Str("x").
+		Varbinary([]byte("seven")).Varbinary(nil).
+		Timestamp(ts).GeographyPoint(pt).
+		Null(IntegerColumn).Value([]int32{1, 2})
+	raw := []driver.Value{
+		true, int8(1), int16(2), int32(5), int64(4), 4.5,
+		big.NewRat(8, 1), wire.NullDecimal(), "x",
+		[]byte("seven"), wire.NullVarbinary(),
+		ts, pt,
+		wire.NullInteger(), []int32{1, 2},
+	}
+	if params.Len() != len(raw) {
+		t.Fatalf("expected %d parameters got %d", len(raw), params.Len())
+	}
+	args := params.Build()
+	encode := func(args []driver.Value) []byte {
+		e := wire.NewEncoder()
+		if err := EncodePI(e, newProcedureInvocationByHandle(1, true, "proc", args)); err != nil {
+			t.Fatal(err)
+		}
+		return e.Bytes()
+	}
+	if got, exp := encode(args), encode(raw); !bytes.Equal(got, exp) {
+		t.Errorf("expected %v got %v", exp, got)
+	}
+	// appending after Build doesn't change the built parameters.
+	params.Str("more")
+	if len(args) != len(raw) {
+		t.Errorf("expected %d built parameters got %d", len(raw), len(args))
+	}
+}
+
+func TestEncodePI_Pointers(t *testing.T) {
+	sample := []interface{}{int8(1), int16(2), int32(3), int64(4), 5.5, "volt", []string{"a", "b"}}
+	encode := func(v interface{}) []byte {
+		e := wire.NewEncoder()
+		if err := EncodePI(e, newProcedureInvocationByHandle(1, true, "proc", []driver.Value{v})); err != nil {
+			t.Fatal(err)
+		}
+		return e.Bytes()
+	}
+	for _, v := range sample {
+		// pointers are dereferenced with reflection, the length prefix
+		// matches the encoded invocation.
+		ptr := reflect.New(reflect.TypeOf(v))
+		ptr.Elem().Set(reflect.ValueOf(v))
+		if exp, b := encode(v), encode(ptr.Interface()); !bytes.Equal(b, exp) {
+			t.Errorf("*%T: expected %v got %v", v, exp, b)
+		}
+	}
+}
+
+func TestEncodePI_UnencodableParam(t *testing.T) {
+	params := []driver.Value{int32(1), struct{ X int }{1}}
+	pi := newProcedureInvocationByHandle(1, true, "proc", params)
+	if err := EncodePI(wire.NewEncoder(), pi); err == nil {
+		t.Error("expected encoding an unsupported parameter to fail")
+	}
+}
+
+func TestEncodePI_StreamValue(t *testing.T) {
+	blob := bytes.Repeat([]byte("0123456789abcdef"), 10<<20/16)
+	pi := newProcedureInvocationByHandle(1, true, "proc", []driver.Value{int32(1), blob})
+	exp := wire.NewEncoder()
+	if err := EncodePI(exp, pi); err != nil {
+		t.Fatal(err)
+	}
+
+	stream := wire.StreamVarbinary(bytes.NewReader(blob), len(blob))
+	pi = newProcedureInvocationByHandle(1, true, "proc", []driver.Value{int32(1), stream})
+	e := wire.NewEncoder()
+	if err := EncodePI(e, pi); err != nil {
+		t.Fatal(err)
+	}
+	// the blob is copied from the reader when the invocation is written.
+	if e.Len() > 64 {
+		t.Errorf("expected the blob not to be buffered, %d bytes are", e.Len())
+	}
+	var buf bytes.Buffer
+	n, err := e.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(exp.Len()) || !bytes.Equal(buf.Bytes(), exp.Bytes()) {
+		t.Errorf("expected the framed invocation to match the buffered one, %d and %d bytes", n, exp.Len())
+	}
+	if exp := exp.Len() - wire.IntegerSize; pi.getLen() != exp {
+		t.Errorf("expected %d got %d", exp, pi.getLen())
+	}
+}
+
+func TestCheckUTF8(t *testing.T) {
+	bad := "volt\xffdb"
+	valid := []driver.Value{int64(1), "voltdb", "日本", []byte(bad), []string{"a", "b"}, (*string)(nil)}
+	if err := checkUTF8(valid); err != nil {
+		t.Errorf("expected no error got %v", err)
+	}
+	invalid := [][]driver.Value{
+		{int64(1), bad},
+		{int64(1), []string{"a", bad}},
+		{int64(1), &bad},
+	}
+	for _, params := range invalid {
+		err := checkUTF8(params)
+		if err == nil || !strings.Contains(err.Error(), "parameter 1 ") {
+			t.Errorf("%q: expected an error for parameter 1 got %v", params, err)
+		}
+	}
+}
+
+func TestEncodePI_QueryTimeout(t *testing.T) {
+	pi := newProcedureInvocationByHandle(1, true, "proc", nil)
+	e := wire.NewEncoder()
+	if err := EncodePI(e, pi); err != nil {
+		t.Fatal(err)
+	}
+	// the batch timeout type follows the length.
+	if b := e.Bytes()[wire.IntegerSize]; int8(b) != noBatchTimeout {
+		t.Errorf("expected %d got %d", noBatchTimeout, b)
+	}
+
+	pi = newProcedureInvocationByHandle(1, true, "proc", nil)
+	pi.queryTimeout = 1500 * time.Millisecond
+	e.Reset()
+	if err := EncodePI(e, pi); err != nil {
+		t.Fatal(err)
+	}
+	b := e.Bytes()
+	exp := []byte{byte(batchTimeoutOverride), 0, 0, 0x05, 0xdc}
+	if !bytes.Equal(b[wire.IntegerSize:wire.IntegerSize+len(exp)], exp) {
+		t.Errorf("expected %v got %v", exp, b[wire.IntegerSize:wire.IntegerSize+len(exp)])
+	}
+	if exp := e.Len() - wire.IntegerSize; pi.getLen() != exp {
+		t.Errorf("expected %d got %d", exp, pi.getLen())
+	}
+}
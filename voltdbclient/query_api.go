@@ -18,11 +18,86 @@
 package voltdbclient
 
 import (
+	"context"
+	"database/sql"
 	"database/sql/driver"
 	"errors"
+	"sync"
 	"time"
+
+	"github.com/VoltDB/voltdb-client-go/wire"
 )
 
+var errConnClosed = errors.New("voltdbclient: connection is closed")
+
+// Response is the response to a procedure invoked with AsyncCall. Err is set
+// when the invocation failed, otherwise Rows holds the returned tables.
+type Response struct {
+	Rows driver.Rows
+	Err  error
+}
+
+// Error returns nil when the call succeeded, otherwise the error it failed
+// with. A call rejected by the server fails with a VoltError holding the status
+// of the response.
+func (r *Response) Error() error {
+	return r.Err
+}
+
+// Exception returns the message of the exception the server sent with a
+// failed call, see VoltError.Exception.
+func (r *Response) Exception() (msg string, ok bool) {
+	if verr, ok := r.Err.(VoltError); ok {
+		return verr.Exception()
+	}
+	return "", false
+}
+
+// Raw returns the message of the response the server sent, see VoltRows.Raw.
+func (r *Response) Raw() []byte {
+	switch x := r.Err.(type) {
+	case nil:
+		if vr, ok := r.Rows.(VoltRows); ok {
+			return vr.Raw()
+		}
+	case VoltError:
+		return x.Raw()
+	}
+	return nil
+}
+
+// Caller invokes stored procedures, it is implemented by *Conn. Code that
+// depends on a Caller rather than on *Conn can be tested with a fake Caller in
+// place of a connection to a server.
+type Caller interface {
+	// CallContext invokes the stored procedure proc and returns its rows,
+	// see Conn.CallContext.
+	CallContext(ctx context.Context, proc string, args ...driver.Value) (driver.Rows, error)
+	// AsyncCall invokes the stored procedure proc asynchronously, the
+	// response is delivered on the returned channel, see Conn.AsyncCall.
+	AsyncCall(proc string, args ...driver.Value) (<-chan *Response, error)
+	// Close closes the Caller, see Conn.Close.
+	Close() error
+}
+
+var _ Caller = (*Conn)(nil)
+
+// chanResponseConsumer is an AsyncResponseConsumer that delivers the response
+// on a channel.
+type chanResponseConsumer chan *Response
+
+func (ch chanResponseConsumer) ConsumeError(err error) {
+	ch <- &Response{Err: err}
+}
+
+func (ch chanResponseConsumer) ConsumeResult(res driver.Result) {
+	ch <- &Response{Err: errors.New("voltdbclient: unexpected result for a query")}
+}
+
+func (ch chanResponseConsumer) ConsumeRows(rows driver.Rows) {
+	ch <- &Response{Rows: rows}
+}
+
 // Exec executes a query that doesn't return rows, such as an INSERT or UPDATE.
 // Exec is available on both VoltConn and on VoltStatement.
 // Uses DefaultQueryTimeout.
@@ -34,6 +109,10 @@ func (c *Conn) Exec(query string, args []driver.Value) (driver.Result, error) {
 // UPDATE. ExecTimeout is available on both VoltConn and on VoltStatement.
 // Specifies a duration for timeout.
 func (c *Conn) ExecTimeout(query string, args []driver.Value, timeout time.Duration) (driver.Result, error) {
+	release, err := c.acquireCall(query, timeout)
+	if err != nil {
+		return nil, err
+	}
 	responseCh := make(chan voltResponse, 1)
 	pi := newSyncProcedureInvocation(c.getNextHandle(), false, query, args, responseCh, timeout)
 	c.inPiCh <- pi
@@ -41,6 +120,7 @@ func (c *Conn) ExecTimeout(query string, args []driver.Value, timeout time.Durat
 	defer tm.Stop()
 	select {
 	case resp := <-pi.responseCh:
+		release()
 		switch resp.(type) {
 		case VoltResult:
 			return resp.(VoltResult), nil
@@ -50,6 +130,7 @@ func (c *Conn) ExecTimeout(query string, args []driver.Value, timeout time.Durat
 			panic("unexpected response type")
 		}
 	case <-tm.C:
+		releaseOnResponse(pi, release)
 		return nil, VoltError{voltResponse: voltResponseInfo{status: ConnectionTimeout, clusterRoundTripTime: -1}, error: errors.New("timeout")}
 	}
 }
@@ -65,7 +146,12 @@ func (c *Conn) ExecAsync(resCons AsyncResponseConsumer, query string, args []dri
 // invocation of this method blocks only until a request is sent to the VoltDB
 // server.  Specifies a duration for timeout.
 func (c *Conn) ExecAsyncTimeout(resCons AsyncResponseConsumer, query string, args []driver.Value, timeout time.Duration) {
-	pi := newAsyncProcedureInvocation(c.getNextHandle(), false, query, args, timeout, resCons)
+	release, err := c.acquireCall(query, timeout)
+	if err != nil {
+		resCons.ConsumeError(err)
+		return
+	}
+	pi := newAsyncProcedureInvocation(c.getNextHandle(), false, query, args, timeout, releasingConsumer(resCons, release))
 	c.inPiCh <- pi
 }
 
@@ -87,6 +173,10 @@ func (c *Conn) Query(query string, args []driver.Value) (driver.Rows, error) {
 // are for any placeholder parameters in the query.
 // Specifies a duration for timeout.
 func (c *Conn) QueryTimeout(query string, args []driver.Value, timeout time.Duration) (driver.Rows, error) {
+	release, err := c.acquireCall(query, timeout)
+	if err != nil {
+		return nil, err
+	}
 	responseCh := make(chan voltResponse, 1)
 	pi := newSyncProcedureInvocation(c.getNextHandle(), true, query, args, responseCh, timeout)
 	c.inPiCh <- pi
@@ -94,6 +184,7 @@ func (c *Conn) QueryTimeout(query string, args []driver.Value, timeout time.Dura
 	defer tm.Stop()
 	select {
 	case resp := <-pi.responseCh:
+		release()
 		switch resp.(type) {
 		case VoltRows:
 			return resp.(VoltRows), nil
@@ -103,6 +194,7 @@ func (c *Conn) QueryTimeout(query string, args []driver.Value, timeout time.Dura
 			panic("unexpected response type")
 		}
 	case <-tm.C:
+		releaseOnResponse(pi, release)
 		return nil, VoltError{voltResponse: voltResponseInfo{status: ConnectionTimeout, clusterRoundTripTime: -1}, error: errors.New("timeout")}
 	}
 }
@@ -120,6 +212,296 @@ func (c *Conn) QueryAsync(rowsCons AsyncResponseConsumer, query string, args []d
 // response will be handled by the given AsyncResponseConsumer, this processing
 // happens in the 'response' thread.  Specifies a duration for timeout.
 func (c *Conn) QueryAsyncTimeout(rowsCons AsyncResponseConsumer, query string, args []driver.Value, timeout time.Duration) {
-	pi := newAsyncProcedureInvocation(c.getNextHandle(), true, query, args, timeout, rowsCons)
+	release, err := c.acquireCall(query, timeout)
+	if err != nil {
+		rowsCons.ConsumeError(err)
+		return
+	}
+	pi := newAsyncProcedureInvocation(c.getNextHandle(), true, query, args, timeout, releasingConsumer(rowsCons, release))
 	c.inPiCh <- pi
 }
+
+// acquireCall takes a permit of the call caps for a call of proc made without
+// a context, it waits for one at most timeout. A call that can't get a permit
+// in time fails like a call that times out. The returned function gives the
+// permit back.
+func (c *Conn) acquireCall(proc string, timeout time.Duration) (func(), error) {
+	if c.calls == nil {
+		return func() {}, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	release, err := c.calls.acquire(ctx, proc)
+	if err == context.DeadlineExceeded {
+		return nil, VoltError{voltResponse: voltResponseInfo{status: ConnectionTimeout, clusterRoundTripTime: -1}, error: errors.New("timeout")}
+	}
+	return release, err
+}
+
+// releaseOnResponse gives the permit of pi back once it's answered, after its
+// caller stopped waiting for the response. The call is still outstanding until
+// then, it fails at its timeout at the latest.
+func releaseOnResponse(pi *procedureInvocation, release func()) {
+	go func() {
+		<-pi.responseCh
+		release()
+	}()
+}
+
+// releasingConsumer returns an AsyncResponseConsumer giving the permit of a
+// call back before passing its response on to arc.
+func releasingConsumer(arc AsyncResponseConsumer, release func()) AsyncResponseConsumer {
+	return &releaseConsumer{arc: arc, release: release}
+}
+
+type releaseConsumer struct {
+	arc     AsyncResponseConsumer
+	release func()
+	once    sync.Once
+}
+
+func (rc *releaseConsumer) ConsumeError(err error) {
+	rc.once.Do(rc.release)
+	rc.arc.ConsumeError(err)
+}
+
+func (rc *releaseConsumer) ConsumeResult(res driver.Result) {
+	rc.once.Do(rc.release)
+	rc.arc.ConsumeResult(res)
+}
+
+func (rc *releaseConsumer) ConsumeRows(rows driver.Rows) {
+	rc.once.Do(rc.release)
+	rc.arc.ConsumeRows(rows)
+}
+
+// AsyncCall invokes the stored procedure proc asynchronously and returns a
+// channel on which the response is delivered. The invoking thread blocks only
+// until the request is sent to the server, many calls can be outstanding at
+// the same time and each response is delivered on the channel of its own call.
+// Uses DefaultQueryTimeout.
+func (c *Conn) AsyncCall(proc string, args ...driver.Value) (<-chan *Response, error) {
+	return c.AsyncCallTimeout(proc, DefaultQueryTimeout, args...)
+}
+
+// AsyncCallTimeout is analogous to AsyncCall. Specifies a duration for timeout.
+func (c *Conn) AsyncCallTimeout(proc string, timeout time.Duration, args ...driver.Value) (<-chan *Response, error) {
+	if c.isClosed() {
+		return nil, errConnClosed
+	}
+	ch := make(chanResponseConsumer, 1)
+	c.QueryAsyncTimeout(ch, proc, args, timeout)
+	return ch, nil
+}
+
+// QueryContext executes a query that returns rows, typically a SELECT. Waiting
+// for the response is abandoned when ctx is done, ctx.Err() is returned in
+// that case. The deadline of ctx, if any, is used as the timeout of the query.
+// QueryContext implements database/sql/driver.QueryerContext.
+func (c *Conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	resp, err := c.invokeContext(ctx, true, query, namedValues(args))
+	if err != nil {
+		return nil, err
+	}
+	return resp.(VoltRows), nil
+}
+
+// ExecContext executes a query that doesn't return rows, such as an INSERT or
+// UPDATE. Waiting for the response is abandoned when ctx is done, ctx.Err() is
+// returned in that case. The deadline of ctx, if any, is used as the timeout
+// of the query. ExecContext implements database/sql/driver.ExecerContext.
+func (c *Conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	resp, err := c.invokeContext(ctx, false, query, namedValues(args))
+	if err != nil {
+		return nil, err
+	}
+	return resp.(VoltResult), nil
+}
+
+// Ping invokes @Ping on one of the servers and returns an error when it isn't
+// answered, such as when the connection is closed or lost. The deadline of ctx
+// bounds how long the answer is waited for. Ping implements
+// database/sql/driver.Pinger.
+func (c *Conn) Ping(ctx context.Context) error {
+	if c.isClosed() {
+		return errConnClosed
+	}
+	_, err := c.invokeContext(ctx, false, "@Ping", nil)
+	return err
+}
+
+// CheckNamedValue implements database/sql/driver.NamedValueChecker. NULL
+// arguments built with the wire package, such as wire.NullInteger(), are
+// passed through as they are so that the type of the column isn't lost, as are
+// the database/sql Null types and wire.StreamValue arguments. Other arguments
+// are converted the default way.
+func (c *Conn) CheckNamedValue(nv *driver.NamedValue) error {
+	switch nv.Value.(type) {
+	case wire.NullValue, wire.StreamValue:
+		return nil
+	case sql.NullBool, sql.NullByte, sql.NullInt16, sql.NullInt32, sql.NullInt64,
+		sql.NullFloat64, sql.NullString, sql.NullTime:
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+// CallContext invokes the stored procedure proc and returns its rows, it is
+// analogous to QueryContext.
+func (c *Conn) CallContext(ctx context.Context, proc string, args ...driver.Value) (driver.Rows, error) {
+	resp, err := c.invokeContext(ctx, true, proc, args)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(VoltRows), nil
+}
+
+// CallIdempotent invokes the stored procedure proc like CallContext and
+// retries it following the RetryPolicy of the connection when it fails because
+// the connection to the server was lost, see RetryPolicy. Only procedures that
+// can safely run more than once may be called this way, a call in flight when
+// the connection is lost may have been executed. Calls made with CallContext
+// are never retried.
+func (c *Conn) CallIdempotent(ctx context.Context, proc string, args ...driver.Value) (driver.Rows, error) {
+	policy := c.opts.retryPolicy()
+	for retry := 0; ; retry++ {
+		rows, err := c.CallContext(ctx, proc, args...)
+		if err == nil || !retryable(err) || (policy.MaxRetries >= 0 && retry >= policy.MaxRetries) {
+			return rows, err
+		}
+		t := time.NewTimer(policy.delay(retry))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, err
+		}
+	}
+}
+
+// retryable reports whether a call that failed with err may succeed when it's
+// made again. Calls on a closed connection are not.
+func retryable(err error) bool {
+	verr, ok := err.(VoltError)
+	if !ok || errors.Is(err, errConnClosed) {
+		return false
+	}
+	switch verr.Status() {
+	case ConnectionLost, ConnectionTimeout, ServerUnavailable, TXNRestart:
+		return true
+	}
+	return false
+}
+
+// CallWithTimeout invokes the stored procedure proc like CallContext and asks
+// the server to abort it once it runs longer than timeout. This overrides the
+// query timeout configured on the server for this invocation, a response with
+// the GracefulFailure status is returned when it's aborted.
+func (c *Conn) CallWithTimeout(ctx context.Context, proc string, timeout time.Duration, args ...driver.Value) (driver.Rows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	pi := c.newContextInvocation(ctx, true, proc, args)
+	pi.queryTimeout = timeout
+	resp, err := c.submitContext(ctx, pi)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(VoltRows), nil
+}
+
+// CallStream invokes the stored procedure proc and returns a RowStream reading
+// the rows of the response one at a time, it is analogous to CallContext.
+// The rows are decoded as they are read rather than all at once, but the
+// message of the response is still read from the connection as a whole first,
+// so peak memory still includes the full encoded response.
+func (c *Conn) CallStream(ctx context.Context, proc string, args ...driver.Value) (*RowStream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	pi := c.newContextInvocation(ctx, true, proc, args)
+	pi.stream = true
+	resp, err := c.submitContext(ctx, pi)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*RowStream), nil
+}
+
+func (c *Conn) invokeContext(ctx context.Context, isQuery bool, query string, args []driver.Value) (voltResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.submitContext(ctx, c.newContextInvocation(ctx, isQuery, query, args))
+}
+
+// newContextInvocation returns a synchronous invocation timing out at the
+// deadline of ctx, if any.
+func (c *Conn) newContextInvocation(ctx context.Context, isQuery bool, query string, args []driver.Value) *procedureInvocation {
+	timeout := DefaultQueryTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	// the channel is buffered so that a response arriving after ctx is done
+	// doesn't block the connection.
+	responseCh := make(chan voltResponse, 1)
+	return newSyncProcedureInvocation(c.getNextHandle(), isQuery, query, args, responseCh, timeout)
+}
+
+// submitContext submits pi and waits for its response or for ctx to be done,
+// the call is traced by the Tracer of the connection.
+func (c *Conn) submitContext(ctx context.Context, pi *procedureInvocation) (voltResponse, error) {
+	if err := c.checkParams(ctx, pi.query, pi.params); err != nil {
+		return nil, err
+	}
+	ctx, span := c.opts.tracer().StartCall(ctx, pi.query, len(pi.params))
+	release, err := c.calls.acquire(ctx, pi.query)
+	if err != nil {
+		endSpan(span, nil, err)
+		return nil, err
+	}
+	resp, err := c.waitContext(ctx, pi, release)
+	endSpan(span, resp, err)
+	return resp, err
+}
+
+// waitContext submits pi and waits for its response. release, when not nil,
+// is called once pi is answered, even if that's after ctx is done.
+func (c *Conn) waitContext(ctx context.Context, pi *procedureInvocation, release func()) (voltResponse, error) {
+	select {
+	case c.inPiCh <- pi:
+	case <-ctx.Done():
+		if release != nil {
+			release()
+		}
+		return nil, ctx.Err()
+	}
+	select {
+	case resp := <-pi.responseCh:
+		if release != nil {
+			release()
+		}
+		if verr, ok := resp.(VoltError); ok {
+			return nil, verr
+		}
+		return resp, nil
+	case <-ctx.Done():
+		if release != nil {
+			// the call is still outstanding, it fails at its timeout at
+			// the latest.
+			go func() {
+				<-pi.responseCh
+				release()
+			}()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
@@ -0,0 +1,1714 @@
+package voltdbclient
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VoltDB/voltdb-client-go/wire"
+)
+
+// echoHandler answers ECHO invocations with a table holding the BIGINT passed
+// as the single parameter.
+func echoHandler(inv stubInvocation) []byte {
+	if inv.proc != "ECHO" {
+		return stubErrorResponse(inv.handle, GracefulFailure, "unknown procedure")
+	}
+	d := wire.NewDecoder(bytes.NewReader(inv.params))
+	d.Int16() // parameter count
+	d.Byte()  // parameter type
+	v, _ := d.Int64()
+	return stubResponse(inv.handle, stubTable([]int8{wire.LongColumn}, []string{"V"}, []interface{}{v}))
+}
+
+func TestConn_AsyncCall(t *testing.T) {
+	s := newStubServer(t, echoHandler)
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	chs := make([]<-chan *Response, 100)
+	for i := range chs {
+		if chs[i], err = conn.AsyncCall("ECHO", int64(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, ch := range chs {
+		rsp := <-ch
+		if rsp.Err != nil {
+			t.Fatal(rsp.Err)
+		}
+		rows := rsp.Rows.(VoltRows)
+		if !rows.AdvanceRow() {
+			t.Fatal("expected a row")
+		}
+		v, err := rows.GetBigInt(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != int64(i) {
+			t.Errorf("expected %d got %v", i, v)
+		}
+	}
+
+	rsp := <-mustAsyncCall(t, conn, "NOSUCHPROC")
+	if rsp.Err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func mustAsyncCall(t *testing.T, conn *Conn, proc string) <-chan *Response {
+	ch, err := conn.AsyncCall(proc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ch
+}
+
+func TestConn_CallContext(t *testing.T) {
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		if inv.proc == "SLOW" {
+			// never respond
+			return nil
+		}
+		return echoHandler(inv)
+	})
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = conn.CallContext(ctx, "SLOW")
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected %v got %v", context.DeadlineExceeded, err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("expected the call to return promptly, took %v", d)
+	}
+
+	rows, err := conn.CallContext(context.Background(), "ECHO", int64(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vr := rows.(VoltRows)
+	vr.AdvanceRow()
+	if v, _ := vr.GetBigInt(0); v != int64(7) {
+		t.Errorf("expected 7 got %v", v)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err = conn.CallContext(ctx, "ECHO", int64(7)); err != context.Canceled {
+		t.Errorf("expected %v got %v", context.Canceled, err)
+	}
+}
+
+func TestConn_ConcurrentCalls(t *testing.T) {
+	s := newStubServer(t, echoHandler)
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := int64(0); i < 50; i++ {
+		wg.Add(1)
+		go func(i int64) {
+			defer wg.Done()
+			rows, err := conn.CallContext(context.Background(), "ECHO", i)
+			if err != nil {
+				errs <- err
+				return
+			}
+			vr := rows.(VoltRows)
+			vr.AdvanceRow()
+			v, err := vr.GetBigInt(0)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if v.(int64) != i {
+				errs <- fmt.Errorf("call %d got the response %d", i, v)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestConn_OutOfOrderResponses(t *testing.T) {
+	// the response to the first invocation is held back and sent after the
+	// response to the second one.
+	var held []byte
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		rsp := echoHandler(inv)
+		if held == nil {
+			held = rsp
+			return nil
+		}
+		return append(rsp, held...)
+	})
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	first, err := conn.AsyncCall("ECHO", int64(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// make sure the first invocation is received first.
+	time.Sleep(50 * time.Millisecond)
+	second, err := conn.AsyncCall("ECHO", int64(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, ch := range []<-chan *Response{first, second} {
+		rsp := <-ch
+		if rsp.Err != nil {
+			t.Fatal(rsp.Err)
+		}
+		vr := rsp.Rows.(VoltRows)
+		vr.AdvanceRow()
+		v, err := vr.GetBigInt(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.(int64) != int64(i+1) {
+			t.Errorf("call %d got the response %d", i+1, v)
+		}
+	}
+}
+
+func TestConn_CallWithTimeout(t *testing.T) {
+	timeouts := make(chan time.Duration, 2)
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		timeouts <- inv.queryTimeout
+		return echoHandler(inv)
+	})
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := conn.CallWithTimeout(ctx, "ECHO", 250*time.Millisecond, int64(1)); err != nil {
+		t.Fatal(err)
+	}
+	if d := <-timeouts; d != 250*time.Millisecond {
+		t.Errorf("expected a query timeout of 250ms got %v", d)
+	}
+	if _, err := conn.CallContext(ctx, "ECHO", int64(1)); err != nil {
+		t.Fatal(err)
+	}
+	if d := <-timeouts; d != 0 {
+		t.Errorf("expected no query timeout got %v", d)
+	}
+}
+
+func TestConn_PingAndState(t *testing.T) {
+	var answer int32 = 1
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		if inv.proc == "@Ping" && atomic.LoadInt32(&answer) == 1 {
+			return stubResponse(inv.handle)
+		}
+		return nil
+	})
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := conn.State(); st != Connected {
+		t.Errorf("expected %v got %v", Connected, st)
+	}
+	if err := conn.Ping(context.Background()); err != nil {
+		t.Errorf("expected a healthy ping got %v", err)
+	}
+
+	// an unanswered ping fails at the deadline of the context.
+	atomic.StoreInt32(&answer, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := conn.Ping(ctx); err == nil {
+		t.Error("expected an unanswered ping to fail")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("expected the ping to fail at the deadline, took %v", d)
+	}
+
+	s.close()
+	deadline := time.Now().Add(5 * time.Second)
+	for conn.State() != Reconnecting {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %v got %v", Reconnecting, conn.State())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	conn.Close()
+	if st := conn.State(); st != Disconnected {
+		t.Errorf("expected %v got %v", Disconnected, st)
+	}
+	if err := conn.Ping(context.Background()); err != errConnClosed {
+		t.Errorf("expected %v got %v", errConnClosed, err)
+	}
+}
+
+func TestConn_CallStream(t *testing.T) {
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		var first, second [][]interface{}
+		for i := 0; i < 5; i++ {
+			first = append(first, []interface{}{int64(i), "row"})
+			second = append(second, []interface{}{int32(i * 10)})
+		}
+		return stubResponse(inv.handle,
+			stubTable([]int8{wire.LongColumn, wire.StringColumn}, []string{"ID", "S"}, first...),
+			stubTable([]int8{wire.IntColumn}, []string{"I"}, second...),
+			stubTable([]int8{wire.IntColumn}, []string{"I"}, second...))
+	})
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	rs, err := conn.CallStream(context.Background(), "ROWS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	for ; rs.Next(); n++ {
+		var id int64
+		var str string
+		if err := rs.Scan(&id, &str); err != nil {
+			t.Fatal(err)
+		}
+		if id != n || str != "row" {
+			t.Errorf("unexpected row %v %v", id, str)
+		}
+	}
+	if n != 5 {
+		t.Errorf("expected 5 rows got %d", n)
+	}
+
+	// the rest of the second table is skipped.
+	if !rs.NextTable() || !rs.Next() {
+		t.Fatal("expected the second table")
+	}
+	if v, err := rs.Row().GetInteger(0); err != nil || v != int32(0) {
+		t.Errorf("expected 0 got %v %v", v, err)
+	}
+	if !rs.NextTable() {
+		t.Fatal("expected the third table")
+	}
+	n = 0
+	for ; rs.Next(); n++ {
+	}
+	if n != 5 {
+		t.Errorf("expected 5 rows got %d", n)
+	}
+	if rs.NextTable() {
+		t.Error("expected no more tables")
+	}
+	if err := rs.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConn_StatusErrors(t *testing.T) {
+	statuses := []ResponseStatus{UserAbort, GracefulFailure, UnexpectedFailure, ConnectionLost,
+		ServerUnavailable, ConnectionTimeout, ResponseUnknown, TXNRestart, OperationalFailure}
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		d := wire.NewDecoder(bytes.NewReader(inv.params))
+		d.Int16() // parameter count
+		d.Byte()  // parameter type
+		v, _ := d.Byte()
+		if inv.proc == "APP" {
+			return stubStatusResponse(inv.handle, Success, "", ResponseStatus(v), "app failed")
+		}
+		return stubErrorResponse(inv.handle, ResponseStatus(v), ResponseStatus(v).String())
+	})
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, status := range statuses {
+		ch, err := conn.AsyncCall("FAIL", int8(status))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp := <-ch
+		verr, ok := rsp.Error().(VoltError)
+		if !ok {
+			t.Fatalf("status %v: expected a VoltError got %v", status, rsp.Error())
+		}
+		if verr.Status() != status || verr.StatusString() != status.String() {
+			t.Errorf("status %v: unexpected error %v %v", status, verr.Status(), verr.StatusString())
+		}
+	}
+
+	_, err = conn.CallContext(context.Background(), "APP", int8(3))
+	verr, ok := err.(VoltError)
+	if !ok {
+		t.Fatalf("expected a VoltError got %v", err)
+	}
+	if verr.Status() != Success || verr.AppStatus() != 3 || verr.AppStatusString() != "app failed" {
+		t.Errorf("unexpected error %v %v %v", verr.Status(), verr.AppStatus(), verr.AppStatusString())
+	}
+
+	ch, err := conn.AsyncCall("APP", int8(math.MinInt8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp := <-ch; rsp.Error() != nil {
+		t.Errorf("expected no error got %v", rsp.Error())
+	}
+}
+
+func TestConn_NullStatusStrings(t *testing.T) {
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		status := Success
+		if inv.proc == "FAIL" {
+			status = GracefulFailure
+		}
+		e := wire.NewEncoder()
+		e.Byte(0) // version
+		e.Int64(inv.handle)
+		var fieldsPresent uint8 = 1<<5 | 1<<7 // both status strings present
+		e.Byte(int8(fieldsPresent))
+		e.Byte(int8(status))
+		e.Int32(-1) // null status string
+		e.Byte(math.MinInt8)
+		e.Int32(-1) // null app status string
+		e.Int32(0)  // cluster round trip time
+		e.Int16(0)
+		return stubMessage(e.Bytes())
+	})
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = conn.CallContext(context.Background(), "FAIL")
+	verr, ok := err.(VoltError)
+	if !ok {
+		t.Fatalf("expected a VoltError got %v", err)
+	}
+	if verr.Status() != GracefulFailure || verr.StatusString() != "" || verr.AppStatusString() != "" {
+		t.Errorf("unexpected error %v %q %q", verr.Status(), verr.StatusString(), verr.AppStatusString())
+	}
+	if _, err := conn.CallContext(context.Background(), "OK"); err != nil {
+		t.Errorf("expected the call to succeed got %v", err)
+	}
+}
+
+func TestConn_Exception(t *testing.T) {
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		if inv.proc != "THROW" {
+			return stubErrorResponse(inv.handle, GracefulFailure, "no exception")
+		}
+		exc := wire.NewEncoder()
+		exc.Byte(2) // the exception class
+		exc.String("VOLTDB ERROR: division by zero")
+		exc.Int32(7) // a field of the class
+		e := wire.NewEncoder()
+		e.Byte(0) // version
+		e.Int64(inv.handle)
+		var fieldsPresent uint8 = 1<<5 | 1<<6 // status string and exception present
+		e.Byte(int8(fieldsPresent))
+		e.Byte(int8(UnexpectedFailure))
+		e.String("procedure threw")
+		e.Byte(math.MinInt8)
+		e.Int32(0) // cluster round trip time
+		e.Int32(int32(exc.Len()))
+		e.Write(exc.Bytes())
+		e.Int16(0)
+		return stubMessage(e.Bytes())
+	})
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ch, err := conn.AsyncCall("THROW")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp := <-ch
+	msg, ok := rsp.Exception()
+	if !ok || msg != "VOLTDB ERROR: division by zero" {
+		t.Errorf("expected the exception message got %q %v", msg, ok)
+	}
+	verr, isVoltErr := rsp.Error().(VoltError)
+	if !isVoltErr || verr.Status() != UnexpectedFailure || verr.StatusString() != "procedure threw" {
+		t.Errorf("unexpected error %v", rsp.Error())
+	}
+
+	_, err = conn.CallContext(context.Background(), "FAIL")
+	verr, isVoltErr = err.(VoltError)
+	if !isVoltErr {
+		t.Fatalf("expected a VoltError got %v", err)
+	}
+	if msg, ok := verr.Exception(); ok {
+		t.Errorf("expected no exception got %q", msg)
+	}
+}
+
+func TestConn_CallNamed(t *testing.T) {
+	params := make(chan []byte, 2)
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		if inv.proc == "@SystemCatalog" {
+			types := []int8{wire.StringColumn, wire.StringColumn, wire.IntColumn}
+			names := []string{"PROCEDURE_NAME", "COLUMN_NAME", "ORDINAL_POSITION"}
+			return stubResponse(inv.handle, stubTable(types, names,
+				[]interface{}{"ADD", "name", int32(2)},
+				[]interface{}{"ADD", "id", int32(1)},
+				[]interface{}{"ADD", "score", int32(3)}))
+		}
+		params <- inv.params
+		return stubResponse(inv.handle, stubResult(1))
+	})
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := conn.CallContext(ctx, "ADD", int64(7), "volt", 1.5); err != nil {
+		t.Fatal(err)
+	}
+	positional := <-params
+	named := map[string]interface{}{"Score": 1.5, "ID": int64(7), "name": "volt"}
+	if _, err := conn.CallNamed(ctx, "ADD", named); err != nil {
+		t.Fatal(err)
+	}
+	if p := <-params; !bytes.Equal(p, positional) {
+		t.Errorf("expected parameters %v got %v", positional, p)
+	}
+
+	if _, err := conn.CallNamed(ctx, "ADD", map[string]interface{}{"id": int64(7)}); err == nil {
+		t.Error("expected an error for missing parameters")
+	}
+	if _, err := conn.CallNamed(ctx, "UNKNOWN", nil); err == nil {
+		t.Error("expected an error for a procedure without metadata")
+	}
+}
+
+func TestConn_StrictParams(t *testing.T) {
+	var catalogCalls, calls int32
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		if inv.proc != "@SystemCatalog" {
+			atomic.AddInt32(&calls, 1)
+			return stubResponse(inv.handle, stubResult(1))
+		}
+		atomic.AddInt32(&catalogCalls, 1)
+		d := wire.NewDecoder(bytes.NewReader(inv.params))
+		d.Int16() // parameter count
+		d.Byte()  // parameter type
+		selector, _ := d.String()
+		if selector == "PROCEDURES" {
+			return stubResponse(inv.handle, stubTable([]int8{wire.StringColumn}, []string{"PROCEDURE_NAME"},
+				[]interface{}{"ADD"}, []interface{}{"NOARGS"}))
+		}
+		types := []int8{wire.StringColumn, wire.StringColumn, wire.StringColumn, wire.IntColumn, wire.StringColumn}
+		names := []string{"PROCEDURE_NAME", "COLUMN_NAME", "TYPE_NAME", "ORDINAL_POSITION", "REMARKS"}
+		return stubResponse(inv.handle, stubTable(types, names,
+			[]interface{}{"ADD", "scores", "FLOAT", int32(3), "ARRAY_PARAMETER"},
+			[]interface{}{"ADD", "id", "BIGINT", int32(1), "PARTITION_PARAMETER"},
+			[]interface{}{"ADD", "name", "VARCHAR", int32(2), wire.NullString()}))
+	})
+	defer s.close()
+	conn, err := OpenConnWithOptions(s.url(), ConnectOptions{StrictParams: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	valid := [][]driver.Value{
+		{int64(7), "volt", []float64{1.5}},
+		{int32(7), "volt", []int64{1}},
+		{int64(7), wire.StreamString(strings.NewReader("volt"), 4), []float64{1.5}},
+		{(*int64)(nil), wire.NullString(), []float64(nil)},
+	}
+	for _, args := range valid {
+		if _, err := conn.CallContext(ctx, "ADD", args...); err != nil {
+			t.Errorf("%v: expected no error got %v", args, err)
+		}
+	}
+	if _, err := conn.CallContext(ctx, "NOARGS"); err != nil {
+		t.Errorf("expected no error got %v", err)
+	}
+
+	invalid := map[string][]driver.Value{
+		"takes 3 parameters, 1 were given":  {int64(7)},
+		"parameter ID of procedure ADD":     {"7", "volt", []float64{1.5}},
+		"parameter SCORES of procedure ADD": {int64(7), "volt", 1.5},
+		"parameter NAME of procedure ADD":   {int64(7), []int64{1}, []float64{1.5}},
+	}
+	for msg, args := range invalid {
+		_, err := conn.CallContext(ctx, "ADD", args...)
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%v: expected an error containing %q got %v", args, msg, err)
+		}
+	}
+	if _, err := conn.CallContext(ctx, "NOARGS", int64(1)); err == nil {
+		t.Error("expected an error for a procedure without parameters")
+	}
+	if n := atomic.LoadInt32(&calls); n != 5 {
+		t.Errorf("expected the invalid calls not to be sent, the server got %d calls", n)
+	}
+	if n := atomic.LoadInt32(&catalogCalls); n != 2 {
+		t.Errorf("expected the catalog to be loaded once, got %d calls", n)
+	}
+}
+
+func TestConn_ProcedureInfo(t *testing.T) {
+	var catalogCalls int32
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		d := wire.NewDecoder(bytes.NewReader(inv.params))
+		d.Int16() // parameter count
+		d.Byte()  // parameter type
+		selector, _ := d.String()
+		if selector == "PROCEDURES" {
+			if inv.handle > 0 {
+				atomic.AddInt32(&catalogCalls, 1)
+			}
+			types := []int8{wire.StringColumn, wire.StringColumn}
+			names := []string{"PROCEDURE_NAME", "REMARKS"}
+			return stubResponse(inv.handle, stubTable(types, names,
+				[]interface{}{"ADD", `{"singlePartition":true,"readOnly":false,"partitionParameter":1,"partitionParameterType":6}`},
+				[]interface{}{"GET", `{"singlePartition":false,"readOnly":true}`},
+				[]interface{}{"OLD", wire.NullString()}))
+		}
+		types := []int8{wire.StringColumn, wire.StringColumn, wire.StringColumn, wire.IntColumn, wire.StringColumn}
+		names := []string{"PROCEDURE_NAME", "COLUMN_NAME", "TYPE_NAME", "ORDINAL_POSITION", "REMARKS"}
+		return stubResponse(inv.handle, stubTable(types, names,
+			[]interface{}{"ADD", "name", "VARCHAR", int32(1), wire.NullString()},
+			[]interface{}{"ADD", "id", "BIGINT", int32(2), "PARTITION_PARAMETER"}))
+	})
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	infos := map[string]ProcedureInfo{
+		"ADD": {SinglePartition: true, PartitionParameter: 1, PartitionParameterName: "ID", PartitionParameterType: BigIntColumn},
+		"GET": {ReadOnly: true, PartitionParameter: -1},
+		"OLD": {PartitionParameter: -1},
+	}
+	for proc, want := range infos {
+		info, err := conn.ProcedureInfo(ctx, proc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info != want {
+			t.Errorf("%s: expected %+v got %+v", proc, want, info)
+		}
+	}
+	if n := atomic.LoadInt32(&catalogCalls); n != 1 {
+		t.Errorf("expected the catalog to be loaded once got %d", n)
+	}
+	if _, err := conn.ProcedureInfo(ctx, "MISSING"); err == nil {
+		t.Error("expected an error for an unknown procedure")
+	}
+	pc, err := conn.LoadProcedureCatalog(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, ok := pc.Info("ADD"); !ok || !info.SinglePartition {
+		t.Errorf("expected ADD to be single partition got %+v %v", info, ok)
+	}
+	if n := atomic.LoadInt32(&catalogCalls); n != 3 {
+		t.Errorf("expected the catalog to be reloaded got %d loads", n)
+	}
+}
+func TestBatch_Exec(t *testing.T) {
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		d := wire.NewDecoder(bytes.NewReader(inv.params))
+		d.Int16() // parameter count
+		d.Byte()  // parameter type
+		sql, _ := d.String()
+		stmts := strings.Split(strings.TrimSuffix(sql, ";"), ";")
+		var tables [][]byte
+		for i, stmt := range stmts {
+			if strings.Contains(stmt, "BAD") {
+				return stubErrorResponse(inv.handle, GracefulFailure, "Error compiling statement: "+strings.TrimSpace(stmt))
+			}
+			tables = append(tables, stubResult(int64(i+1)))
+		}
+		return stubResponse(inv.handle, tables...)
+	})
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	b := conn.NewBatch()
+	b.Add("INSERT INTO T VALUES (1)")
+	b.Add("INSERT INTO T VALUES (2);")
+	b.Add("INSERT INTO T VALUES (3)")
+	rsps, err := b.Exec(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rsps) != 3 {
+		t.Fatalf("expected 3 responses got %d", len(rsps))
+	}
+	for i, rsp := range rsps {
+		rows := rsp.Rows.(VoltRows)
+		if !rows.AdvanceRow() {
+			t.Fatalf("statement %d: expected a row", i)
+		}
+		if v, err := rows.GetBigInt(0); err != nil || v != int64(i+1) {
+			t.Errorf("statement %d: expected %d got %v %v", i, i+1, v, err)
+		}
+	}
+
+	b = conn.NewBatch()
+	b.Add("INSERT INTO T VALUES (1)")
+	b.Add("INSERT INTO BAD VALUES (2)")
+	_, err = b.Exec(context.Background())
+	berr, ok := err.(BatchError)
+	if !ok {
+		t.Fatalf("expected a BatchError got %v", err)
+	}
+	if berr.Index != 1 {
+		t.Errorf("expected statement 1 to fail got %d", berr.Index)
+	}
+}
+
+func TestConn_Stats(t *testing.T) {
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		if inv.proc != "ECHO" {
+			return stubErrorResponse(inv.handle, GracefulFailure, "unknown procedure")
+		}
+		rsp := echoHandler(inv)
+		// patch the cluster round trip time that follows the message length,
+		// version, handle, fields present, status and app status.
+		binary.BigEndian.PutUint32(rsp[4+1+8+1+1+1:], 17)
+		return rsp
+	})
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for i := int64(0); i < 3; i++ {
+		rsp, err := conn.CallContext(context.Background(), "ECHO", i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows := rsp.(VoltRows)
+		if rtt := rows.ClusterRoundTripTime(); rtt != 17*time.Millisecond {
+			t.Errorf("expected a round trip time of 17ms got %v", rtt)
+		}
+		if rows.Latency() <= 0 {
+			t.Errorf("expected a positive latency got %v", rows.Latency())
+		}
+	}
+	if _, err := conn.CallContext(context.Background(), "MISSING"); err == nil {
+		t.Fatal("expected MISSING to fail")
+	}
+
+	stats := conn.Stats()
+	echo := stats["ECHO"]
+	if echo.Invocations != 3 || echo.Errors != 0 {
+		t.Errorf("expected 3 invocations without errors got %d and %d", echo.Invocations, echo.Errors)
+	}
+	var n int64
+	for _, c := range echo.Histogram {
+		n += c
+	}
+	if n != 3 {
+		t.Errorf("expected 3 latencies in the histogram got %d", n)
+	}
+	if echo.MinLatency <= 0 || echo.MinLatency > echo.MeanLatency() || echo.MeanLatency() > echo.MaxLatency {
+		t.Errorf("inconsistent latencies %v %v %v", echo.MinLatency, echo.MeanLatency(), echo.MaxLatency)
+	}
+	if missing := stats["MISSING"]; missing.Invocations != 1 || missing.Errors != 1 {
+		t.Errorf("expected 1 failed invocation got %d and %d", missing.Invocations, missing.Errors)
+	}
+}
+
+func TestConn_ConnStats(t *testing.T) {
+	var rspLen int64
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		rsp := echoHandler(inv)
+		if inv.proc == "ECHO" {
+			atomic.StoreInt64(&rspLen, int64(len(rsp)))
+		}
+		return rsp
+	})
+	defer s.close()
+	conn, err := Connect(s.addr(), "user", "secret", ConnectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	before := conn.ConnStats()
+	if len(before) != 1 || before[0].Address != s.addr() {
+		t.Fatalf("expected the stats of %s got %v", s.addr(), before)
+	}
+	if _, err := conn.CallContext(context.Background(), "ECHO", int64(1)); err != nil {
+		t.Fatal(err)
+	}
+	after := conn.ConnStats()[0]
+
+	e := wire.NewEncoder()
+	if err := EncodePI(e, newSyncProcedureInvocation(1, true, "ECHO", []driver.Value{int64(1)}, nil, time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if d := after.BytesSent - before[0].BytesSent; d < int64(e.Len()) {
+		t.Errorf("expected at least %d bytes sent got %d", e.Len(), d)
+	}
+	if d := after.BytesReceived - before[0].BytesReceived; d < atomic.LoadInt64(&rspLen) {
+		t.Errorf("expected at least %d bytes received got %d", atomic.LoadInt64(&rspLen), d)
+	}
+	if d := after.CallsSent - before[0].CallsSent; d < 1 {
+		t.Errorf("expected a call sent got %d", d)
+	}
+	if d := after.ResponsesReceived - before[0].ResponsesReceived; d < 1 {
+		t.Errorf("expected a response received got %d", d)
+	}
+}
+func TestConn_SystemProcedures(t *testing.T) {
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		switch inv.proc {
+		case "@Statistics":
+			types := []int8{wire.LongColumn, wire.IntColumn, wire.StringColumn, wire.IntColumn, wire.LongColumn,
+				wire.StringColumn, wire.StringColumn, wire.LongColumn, wire.LongColumn, wire.LongColumn,
+				wire.LongColumn, wire.IntColumn, wire.IntColumn}
+			names := []string{"TIMESTAMP", "HOST_ID", "HOSTNAME", "SITE_ID", "PARTITION_ID",
+				"TABLE_NAME", "TABLE_TYPE", "TUPLE_COUNT", "TUPLE_ALLOCATED_MEMORY", "TUPLE_DATA_MEMORY",
+				"STRING_DATA_MEMORY", "TUPLE_LIMIT", "PERCENT_FULL"}
+			return stubResponse(inv.handle, stubTable(types, names,
+				[]interface{}{int64(1500000000000), int32(0), "h0", int32(0), int64(0), "USERS", "PersistentTable",
+					int64(42), int64(2048), int64(12), int64(3), int32(math.MinInt32), int32(0)},
+				[]interface{}{int64(1500000000000), int32(1), "h1", int32(0), int64(1), "USERS", "PersistentTable",
+					int64(7), int64(1024), int64(2), int64(0), int32(100), int32(7)}))
+		case "@SystemInformation":
+			types := []int8{wire.IntColumn, wire.StringColumn, wire.StringColumn}
+			names := []string{"HOST_ID", "KEY", "VALUE"}
+			return stubResponse(inv.handle, stubTable(types, names,
+				[]interface{}{int32(1), "HOSTNAME", "h1"},
+				[]interface{}{int32(0), "HOSTNAME", "h0"},
+				[]interface{}{int32(0), "VERSION", "7.5"},
+				[]interface{}{int32(0), "STARTTIME", "1500000000000"},
+				[]interface{}{int32(0), "LICENSE", "community"}))
+		}
+		return stubErrorResponse(inv.handle, GracefulFailure, "unknown procedure")
+	})
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	stats, err := conn.TableStatistics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 rows got %d", len(stats))
+	}
+	expected := TableStats{
+		Timestamp: time.Unix(1500000000, 0), HostID: 0, HostName: "h0", PartitionID: 0,
+		TableName: "USERS", TableType: "PersistentTable", TupleCount: 42,
+		TupleAllocatedMemory: 2048, TupleDataMemory: 12, StringDataMemory: 3,
+	}
+	if stats[0] != expected {
+		t.Errorf("expected %+v got %+v", expected, stats[0])
+	}
+	if stats[1].TupleLimit != 100 || stats[1].PercentFull != 7 || stats[1].PartitionID != 1 {
+		t.Errorf("unexpected second row %+v", stats[1])
+	}
+
+	info, err := conn.SystemInformation(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Hosts) != 2 {
+		t.Fatalf("expected 2 hosts got %d", len(info.Hosts))
+	}
+	h := info.Hosts[0]
+	if h.HostID != 0 || h.HostName != "h0" || h.Version != "7.5" || !h.StartTime.Equal(time.Unix(1500000000, 0)) {
+		t.Errorf("unexpected host %+v", h)
+	}
+	if h.Properties["LICENSE"] != "community" {
+		t.Errorf("expected the LICENSE property got %v", h.Properties)
+	}
+	if info.Hosts[1].HostName != "h1" {
+		t.Errorf("unexpected host %+v", info.Hosts[1])
+	}
+}
+
+func TestBulkLoader(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[int64]bool)
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		d := wire.NewDecoder(bytes.NewReader(inv.params))
+		d.Int16() // parameter count
+		d.Byte()  // parameter type
+		v, _ := d.Int64()
+		if v%1000 == 999 {
+			return stubErrorResponse(inv.handle, GracefulFailure, "constraint violation")
+		}
+		mu.Lock()
+		received[v] = true
+		mu.Unlock()
+		return stubResponse(inv.handle, stubResult(1))
+	})
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var failedMu sync.Mutex
+	var failed []int64
+	bl := conn.NewBulkLoader("USERS.insert", 500, func(row []driver.Value, err error) {
+		failedMu.Lock()
+		defer failedMu.Unlock()
+		failed = append(failed, row[0].(int64))
+	})
+	const n = 10000
+	for i := int64(0); i < n; i++ {
+		if err := bl.Insert(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bl.Drain(); err != nil {
+		t.Fatal(err)
+	}
+	if bl.Loaded() != n-10 || bl.Failed() != 10 {
+		t.Errorf("expected %d loaded and 10 failed rows got %d and %d", n-10, bl.Loaded(), bl.Failed())
+	}
+	if len(failed) != 10 {
+		t.Errorf("expected 10 failed rows got %v", failed)
+	}
+	for _, v := range failed {
+		if v%1000 != 999 {
+			t.Errorf("unexpected failed row %d", v)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != n-10 {
+		t.Errorf("expected %d rows to arrive got %d", n-10, len(received))
+	}
+}
+
+func TestConn_EncodeError(t *testing.T) {
+	s := newStubServer(t, echoHandler)
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	key := wire.FixedVarbinary{Width: 16, Bytes: []byte{1, 2, 3}}
+	if _, err := conn.CallContext(ctx, "ECHO", key); err == nil {
+		t.Fatal("expected the call with a parameter of the wrong width to fail")
+	}
+	if _, err := conn.CallContext(ctx, "ECHO", struct{ X int }{1}); err == nil {
+		t.Fatal("expected the call with an unsupported parameter to fail")
+	}
+	// nothing was sent, the connection is still usable.
+	if _, err := conn.CallContext(ctx, "ECHO", int64(1)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConn_MaxStringLength(t *testing.T) {
+	s := newStubServer(t, echoHandler)
+	defer s.close()
+	conn, err := OpenConnWithOptions(s.url(), ConnectOptions{MaxStringLength: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	_, err = conn.CallContext(ctx, "ECHO", "voltdb")
+	verr, ok := err.(VoltError)
+	if !ok {
+		t.Fatalf("expected VoltError got %v", err)
+	}
+	if exp := (wire.StringLengthError{Len: 6, Max: 4}); verr.error != exp {
+		t.Errorf("expected %v got %v", exp, verr.error)
+	}
+	if _, err := conn.CallContext(ctx, "ECHO", int64(1)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConn_MapAsJSON(t *testing.T) {
+	var payload string
+	var mu sync.Mutex
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		d := wire.NewDecoder(bytes.NewReader(inv.params))
+		d.Int16() // parameter count
+		d.Byte()  // parameter type
+		v, _ := d.String()
+		mu.Lock()
+		payload = v
+		mu.Unlock()
+		return stubResponse(inv.handle)
+	})
+	defer s.close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	m := map[string]interface{}{"k": "v"}
+
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.CallContext(ctx, "PROC", m); err == nil || !strings.Contains(err.Error(), "MapAsJSON") {
+		t.Errorf("expected the map to be rejected got %v", err)
+	}
+
+	conn, err = OpenConnWithOptions(s.url(), ConnectOptions{MapAsJSON: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.CallContext(ctx, "PROC", m); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if payload != `{"k":"v"}` {
+		t.Errorf("expected the JSON of the map got %q", payload)
+	}
+}
+
+func TestConn_UnlimitedStringLength(t *testing.T) {
+	// ARRAY answers with the length of the single string of the array passed
+	// as the single parameter.
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		if inv.proc != "ARRAY" {
+			return echoHandler(inv)
+		}
+		d := wire.NewDecoder(bytes.NewReader(inv.params))
+		d.Int16() // parameter count
+		d.Byte()  // array type
+		d.Byte()  // element type
+		d.Int16() // element count
+		n, err := d.Int32()
+		if err != nil {
+			return stubErrorResponse(inv.handle, GracefulFailure, err.Error())
+		}
+		return stubResponse(inv.handle, stubTable([]int8{wire.LongColumn}, []string{"N"}, []interface{}{int64(n)}))
+	})
+	defer s.close()
+	conn, err := OpenConnWithOptions(s.url(), ConnectOptions{MaxStringLength: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	big := strings.Repeat("x", 2<<20)
+	rows, err := conn.CallContext(ctx, "ARRAY", []string{big})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vr := rows.(VoltRows)
+	vr.AdvanceRow()
+	if n, err := vr.GetBigInt(0); err != nil || n.(int64) != int64(len(big)) {
+		t.Errorf("expected %d got %v %v", len(big), n, err)
+	}
+	// the framing of the connection is intact.
+	if _, err := conn.CallContext(ctx, "ECHO", int64(1)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConn_ValidateUTF8(t *testing.T) {
+	var calls int32
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		atomic.AddInt32(&calls, 1)
+		return stubResponse(inv.handle, stubResult(1))
+	})
+	defer s.close()
+	conn, err := OpenConnWithOptions(s.url(), ConnectOptions{ValidateUTF8: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	_, err = conn.CallContext(ctx, "PUT", int64(1), "key", "\xc3\x28")
+	if err == nil || !strings.Contains(err.Error(), "parameter 2 is not valid UTF-8") {
+		t.Errorf("expected an error for parameter 2 got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Errorf("expected the call not to be sent, the server got %d calls", n)
+	}
+	if _, err := conn.CallContext(ctx, "PUT", int64(1), "key", []byte("\xc3\x28")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStructParams(t *testing.T) {
+	type row struct {
+		ID     int64
+		hidden string
+		Name   string
+		Notes  string  `volt:"-"`
+		Score  float64 `volt:"score,pos=0"`
+	}
+	args, err := structParams(&row{ID: 7, hidden: "x", Name: "volt", Notes: "n", Score: 1.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []driver.Value{1.5, int64(7), "volt"}
+	if len(args) != len(exp) {
+		t.Fatalf("expected %v got %v", exp, args)
+	}
+	for i := range exp {
+		if args[i] != exp[i] {
+			t.Errorf("%d: expected %v got %v", i, exp[i], args[i])
+		}
+	}
+
+	bad := []interface{}{
+		int64(1),
+		time.Now(),
+		wire.NullInteger(),
+		struct {
+			A int64 `volt:",pos=1"`
+		}{},
+		struct {
+			A int64 `volt:",pos=0"`
+			B int64 `volt:",pos=0"`
+		}{},
+		struct {
+			A int64 `volt:",size=3"`
+		}{},
+	}
+	for _, v := range bad {
+		if _, err := structParams(v); err == nil {
+			t.Errorf("%T: expected an error", v)
+		}
+	}
+}
+
+func TestConn_CallStruct(t *testing.T) {
+	params := make(chan []byte, 2)
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		params <- inv.params
+		return stubResponse(inv.handle, stubResult(1))
+	})
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := conn.CallContext(ctx, "ADD", int64(7), "volt", 1.5); err != nil {
+		t.Fatal(err)
+	}
+	positional := <-params
+	type add struct {
+		Name  string `volt:",pos=1"`
+		ID    int64
+		Score float64
+	}
+	if _, err := conn.CallStruct(ctx, "ADD", add{Name: "volt", ID: 7, Score: 1.5}); err != nil {
+		t.Fatal(err)
+	}
+	if p := <-params; !bytes.Equal(p, positional) {
+		t.Errorf("expected parameters %v got %v", positional, p)
+	}
+}
+
+func TestConn_MultipleTables(t *testing.T) {
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		types := []int8{wire.IntColumn}
+		names := []string{"N"}
+		first := stubTable(types, names, []interface{}{int32(1)}, []interface{}{int32(2)})
+		second := stubTable(types, names)
+		// the status code follows the table and metadata lengths.
+		second[2*wire.IntegerSize] = 5
+		third := stubTable(types, names, []interface{}{int32(3)}, []interface{}{int32(4)}, []interface{}{int32(5)})
+		return stubResponse(inv.handle, first, second, third)
+	})
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	rows, err := conn.CallContext(context.Background(), "MULTI")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vr := rows.(VoltRows)
+	sample := []struct {
+		rows   int
+		status int8
+	}{
+		{2, math.MinInt8},
+		{0, 5},
+		{3, math.MinInt8},
+	}
+	for i, exp := range sample {
+		table, err := vr.Table(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if table.RowCount() != exp.rows {
+			t.Errorf("table %d: expected %d rows got %d", i, exp.rows, table.RowCount())
+		}
+		if table.TableStatusCode() != exp.status {
+			t.Errorf("table %d: expected status code %d got %d", i, exp.status, table.TableStatusCode())
+		}
+	}
+	if vr.RowCount() != 2 {
+		t.Errorf("expected the current table to stay the first got %d rows", vr.RowCount())
+	}
+	for _, i := range []int{-1, 3} {
+		if _, err := vr.Table(i); err == nil {
+			t.Errorf("expected an error for table %d", i)
+		}
+	}
+}
+
+func TestConn_TableHelpers(t *testing.T) {
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		n := int(inv.params[len(inv.params)-1])
+		tables := make([][]byte, n)
+		for i := range tables {
+			tables[i] = stubTable([]int8{wire.IntColumn}, []string{"N"}, []interface{}{int32(i)})
+		}
+		return stubResponse(inv.handle, tables...)
+	})
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, n := range []int8{0, 1, 3} {
+		rows, err := conn.CallContext(context.Background(), "TABLES", n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vr := rows.(VoltRows)
+		if vr.TableCount() != int(n) {
+			t.Errorf("expected %d tables got %d", n, vr.TableCount())
+		}
+		tables := vr.Tables()
+		if len(tables) != int(n) {
+			t.Fatalf("expected %d tables got %d", n, len(tables))
+		}
+		for i, table := range tables {
+			if !table.AdvanceRow() {
+				t.Fatalf("table %d: expected a row", i)
+			}
+			v, err := table.GetInteger(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.(int32) != int32(i) {
+				t.Errorf("table %d: expected %d got %v", i, i, v)
+			}
+		}
+		single, err := vr.SingleTable()
+		if n != 1 {
+			if err == nil {
+				t.Errorf("expected an error getting the single table of %d", n)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if single.RowCount() != 1 {
+			t.Errorf("expected 1 row got %d", single.RowCount())
+		}
+	}
+}
+
+func TestConn_MaxConcurrentCalls(t *testing.T) {
+	var echoes int32
+	entered := make(chan struct{}, 1)
+	proceed := make(chan struct{})
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		switch inv.proc {
+		case "SLOW":
+			entered <- struct{}{}
+			<-proceed
+		case "ECHO":
+			atomic.AddInt32(&echoes, 1)
+		}
+		return stubResponse(inv.handle)
+	})
+	defer s.close()
+	conn, err := OpenConnWithOptions(s.url(), ConnectOptions{MaxConcurrentCalls: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	slow := make(chan error, 1)
+	go func() {
+		_, err := conn.CallContext(ctx, "SLOW")
+		slow <- err
+	}()
+	<-entered
+
+	// at the cap a call waits until its context is done without being sent.
+	short, cancelShort := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancelShort()
+	if _, err := conn.CallContext(short, "ECHO"); err != context.DeadlineExceeded {
+		t.Errorf("expected %v got %v", context.DeadlineExceeded, err)
+	}
+	if n := atomic.LoadInt32(&echoes); n != 0 {
+		t.Errorf("expected the call not to be sent, the server got %d", n)
+	}
+
+	// a waiting call is sent once the outstanding call is answered.
+	echo := make(chan error, 1)
+	go func() {
+		_, err := conn.CallContext(ctx, "ECHO")
+		echo <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(proceed)
+	if err := <-slow; err != nil {
+		t.Error(err)
+	}
+	if err := <-echo; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConn_FailAtCallCap(t *testing.T) {
+	entered := make(chan struct{}, 1)
+	proceed := make(chan struct{})
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		if inv.proc == "SLOW" {
+			entered <- struct{}{}
+			<-proceed
+		}
+		return stubResponse(inv.handle)
+	})
+	defer s.close()
+	conn, err := OpenConnWithOptions(s.url(), ConnectOptions{MaxConcurrentProcCalls: 1, FailAtCallCap: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	slow := make(chan error, 1)
+	go func() {
+		_, err := conn.CallContext(ctx, "SLOW")
+		slow <- err
+	}()
+	<-entered
+
+	want := CallCapError{Proc: "SLOW", Max: 1}
+	if _, err := conn.CallContext(ctx, "SLOW"); err != want {
+		t.Errorf("expected %v got %v", want, err)
+	}
+	// the cap is per procedure, other procedures can still be called.
+	echo := make(chan error, 1)
+	go func() {
+		_, err := conn.CallContext(ctx, "ECHO")
+		echo <- err
+	}()
+	close(proceed)
+	if err := <-slow; err != nil {
+		t.Error(err)
+	}
+	if err := <-echo; err != nil {
+		t.Error(err)
+	}
+	if _, err := conn.CallContext(ctx, "SLOW"); err != nil {
+		t.Errorf("expected the call to succeed once under the cap got %v", err)
+	}
+}
+
+func TestConn_AsyncCallAtCap(t *testing.T) {
+	entered := make(chan struct{}, 1)
+	proceed := make(chan struct{})
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		if inv.proc == "SLOW" {
+			entered <- struct{}{}
+			<-proceed
+		}
+		return stubResponse(inv.handle)
+	})
+	defer s.close()
+	for _, fail := range []bool{false, true} {
+		conn, err := OpenConnWithOptions(s.url(), ConnectOptions{MaxConcurrentCalls: 1, FailAtCallCap: fail})
+		if err != nil {
+			t.Fatal(err)
+		}
+		slow, err := conn.AsyncCall("SLOW")
+		if err != nil {
+			t.Fatal(err)
+		}
+		<-entered
+
+		if fail {
+			// at the cap an asynchronous call fails on its channel.
+			ch, err := conn.AsyncCall("ECHO")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := CallCapError{Max: 1}
+			if resp := <-ch; resp.Err != want {
+				t.Errorf("expected %v got %v", want, resp.Err)
+			}
+		} else {
+			// at the cap a call without a context waits at most its timeout.
+			_, err := conn.QueryTimeout("ECHO", nil, 50*time.Millisecond)
+			if verr, ok := err.(VoltError); !ok || verr.Status() != ConnectionTimeout {
+				t.Errorf("expected a connection timeout got %v", err)
+			}
+		}
+		proceed <- struct{}{}
+		if resp := <-slow; resp.Err != nil {
+			t.Error(resp.Err)
+		}
+
+		// the consumer of the answered call gave its permit back.
+		ch, err := conn.AsyncCall("ECHO")
+		if err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case resp := <-ch:
+			if resp.Err != nil {
+				t.Errorf("expected the call to succeed once under the cap got %v", resp.Err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the response")
+		}
+		conn.Close()
+	}
+}
+
+func TestConn_CallIdempotent(t *testing.T) {
+	var calls int32
+	var s *stubServer
+	s = newStubServer(t, func(inv stubInvocation) []byte {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// the connection is lost halfway through the first response.
+			go s.dropConns()
+			return stubResponse(inv.handle)[:10]
+		}
+		return stubResponse(inv.handle)
+	})
+	defer s.close()
+	opts := ConnectOptions{
+		ReconnectPolicy: &ReconnectPolicy{MaxRetries: -1, BaseDelay: 10 * time.Millisecond, MaxDelay: 10 * time.Millisecond},
+		RetryPolicy:     &RetryPolicy{MaxRetries: 2, BaseDelay: 10 * time.Millisecond, MaxDelay: 10 * time.Millisecond},
+	}
+	conn, err := OpenConnWithOptions(s.url(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := conn.CallIdempotent(ctx, "PROC"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("expected 2 attempts got %d", n)
+	}
+
+	// calls that aren't idempotent are never retried.
+	atomic.StoreInt32(&calls, 0)
+	_, err = conn.CallContext(ctx, "PROC")
+	if verr, ok := err.(VoltError); !ok || verr.Status() != ConnectionLost {
+		t.Errorf("expected ConnectionLost got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected 1 attempt got %d", n)
+	}
+}
+
+func TestConn_CallIdempotentNotRetried(t *testing.T) {
+	var calls int32
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		atomic.AddInt32(&calls, 1)
+		return stubErrorResponse(inv.handle, GracefulFailure, "constraint violation")
+	})
+	defer s.close()
+	opts := ConnectOptions{RetryPolicy: &RetryPolicy{MaxRetries: 3}}
+	conn, err := OpenConnWithOptions(s.url(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// failures of the procedure itself are returned as they are.
+	_, err = conn.CallIdempotent(context.Background(), "PROC")
+	if verr, ok := err.(VoltError); !ok || verr.Status() != GracefulFailure {
+		t.Errorf("expected GracefulFailure got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected 1 attempt got %d", n)
+	}
+}
+
+func TestConn_KeepRawResponses(t *testing.T) {
+	sent := make(chan []byte, 1)
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		rsp := stubResponse(inv.handle, stubResult(3))
+		if inv.proc == "FAIL" {
+			rsp = stubErrorResponse(inv.handle, GracefulFailure, "failed")
+		}
+		sent <- rsp
+		return rsp
+	})
+	defer s.close()
+	conn, err := OpenConnWithOptions(s.url(), ConnectOptions{KeepRawResponses: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx := context.Background()
+
+	rows, err := conn.CallContext(ctx, "PROC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the message without its length prefix.
+	if exp, raw := (<-sent)[4:], rows.(VoltRows).Raw(); !bytes.Equal(raw, exp) {
+		t.Errorf("expected %v got %v", exp, raw)
+	}
+
+	_, err = conn.CallContext(ctx, "FAIL")
+	verr, ok := err.(VoltError)
+	if !ok {
+		t.Fatalf("expected VoltError got %v", err)
+	}
+	if exp := (<-sent)[4:]; !bytes.Equal(verr.Raw(), exp) {
+		t.Errorf("expected %v got %v", exp, verr.Raw())
+	}
+
+	ch, err := conn.AsyncCall("PROC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, raw := (<-sent)[4:], (<-ch).Raw(); !bytes.Equal(raw, exp) {
+		t.Errorf("expected %v got %v", exp, raw)
+	}
+
+	// the responses are not kept by default.
+	conn2, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn2.Close()
+	rows, err = conn2.CallContext(ctx, "PROC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-sent
+	if raw := rows.(VoltRows).Raw(); raw != nil {
+		t.Errorf("expected no raw response got %v", raw)
+	}
+}
+
+func TestConn_CallAllPartitions(t *testing.T) {
+	keys := stubTable([]int8{wire.IntColumn, wire.IntColumn}, []string{"PARTITION_ID", "PARTITION_KEY"},
+		[]interface{}{int32(0), int32(1)},
+		[]interface{}{int32(1), int32(5)},
+		[]interface{}{int32(2), int32(9)},
+	)
+	s := newStubServer(t, func(inv stubInvocation) []byte {
+		if inv.proc == "@GetPartitionKeys" {
+			return stubResponse(inv.handle, keys)
+		}
+		// the parameter count, then the partition key and the parameter.
+		key := int32(binary.BigEndian.Uint32(inv.params[3:]))
+		if key == 5 {
+			return stubErrorResponse(inv.handle, GracefulFailure, "partition failed")
+		}
+		return stubResponse(inv.handle,
+			stubTable([]int8{wire.IntColumn}, []string{"KEY"}, []interface{}{key}),
+			stubTable([]int8{wire.LongColumn}, []string{"ARG"}, []interface{}{int64(len(inv.params))}),
+		)
+	})
+	defer s.close()
+	conn, err := OpenConn(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	resps, err := conn.CallAllPartitions(context.Background(), "PROC", int64(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resps) != 3 {
+		t.Fatalf("expected 3 partitions got %d", len(resps))
+	}
+	for i, r := range resps {
+		if r.PartitionID != i {
+			t.Errorf("expected partition %d got %d", i, r.PartitionID)
+		}
+		if r.Key == 5 {
+			if verr, ok := r.Err.(VoltError); !ok || verr.Status() != GracefulFailure {
+				t.Errorf("partition %d: expected GracefulFailure got %v", i, r.Err)
+			}
+			continue
+		}
+		if r.Err != nil {
+			t.Fatalf("partition %d: %v", i, r.Err)
+		}
+		rows := r.Rows.(VoltRows)
+		if !rows.AdvanceRow() {
+			t.Fatalf("partition %d: expected a row", i)
+		}
+		if key, err := rows.GetInteger(0); err != nil || key != r.Key {
+			t.Errorf("partition %d: expected key %d got %v %v", i, r.Key, key, err)
+		}
+		// the key and the BIGINT parameter.
+		if !rows.AdvanceTable() || !rows.AdvanceRow() {
+			t.Fatalf("partition %d: expected a second table", i)
+		}
+		if n, err := rows.GetBigInt(0); err != nil || n != int64(2+5+9) {
+			t.Errorf("partition %d: expected %d parameter bytes got %v %v", i, 2+5+9, n, err)
+		}
+	}
+}
+
+// fakeCaller is a Caller answering every call with rows holding the names of
+// the procedure and its parameters, in place of a server.
+type fakeCaller struct {
+	closed bool
+}
+
+func (f *fakeCaller) CallContext(ctx context.Context, proc string, args ...driver.Value) (driver.Rows, error) {
+	if f.closed {
+		return nil, errConnClosed
+	}
+	values := []driver.Value{proc}
+	values = append(values, args...)
+	return &fakeRows{values: values}, nil
+}
+
+func (f *fakeCaller) AsyncCall(proc string, args ...driver.Value) (<-chan *Response, error) {
+	ch := make(chan *Response, 1)
+	rows, err := f.CallContext(context.Background(), proc, args...)
+	ch <- &Response{Rows: rows, Err: err}
+	return ch, nil
+}
+
+func (f *fakeCaller) Close() error {
+	f.closed = true
+	return nil
+}
+
+// fakeRows are rows of a single VARCHAR column.
+type fakeRows struct {
+	values []driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return []string{"VALUE"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
+
+// printRows is code under test that depends on a Caller rather than on *Conn.
+func printRows(ctx context.Context, c Caller, proc string, args ...driver.Value) error {
+	rows, err := c.CallContext(ctx, proc, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	dest := make([]driver.Value, len(rows.Columns()))
+	for rows.Next(dest) == nil {
+		fmt.Println(dest[0])
+	}
+	return nil
+}
+
+func ExampleCaller() {
+	var c Caller = &fakeCaller{}
+	if err := printRows(context.Background(), c, "ECHO", "a", "b"); err != nil {
+		fmt.Println(err)
+	}
+	c.Close()
+	if err := printRows(context.Background(), c, "ECHO"); err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// ECHO
+	// a
+	// b
+	// voltdbclient: connection is closed
+}
@@ -18,9 +18,12 @@
 package voltdbclient
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"math"
+	"time"
 
 	"github.com/VoltDB/voltdb-client-go/wire"
 )
@@ -33,14 +36,78 @@ type voltResponse interface {
 	getNumTables() int16
 	getStatus() ResponseStatus
 	getStatusString() string
+	getLatency() time.Duration
+	getException() (string, bool)
+	getRaw() []byte
 }
 
-// VoltError type
+// VoltError is the error of a failed request. When the server rejected the
+// request its status, application status and their descriptions are available
+// from the accessors.
 type VoltError struct {
 	voltResponse
 	error
 }
 
+// Unwrap returns the error the request failed with, such as a
+// ConnectionLostDuringCallError.
+func (e VoltError) Unwrap() error {
+	return e.error
+}
+
+// Status returns the status of the response, Success when the request failed
+// in the client.
+func (e VoltError) Status() ResponseStatus {
+	if e.voltResponse == nil {
+		return Success
+	}
+	return e.getStatus()
+}
+
+// StatusString returns the description of the status sent by the server.
+func (e VoltError) StatusString() string {
+	if e.voltResponse == nil {
+		return ""
+	}
+	return e.getStatusString()
+}
+
+// AppStatus returns the status set by the stored procedure.
+func (e VoltError) AppStatus() ResponseStatus {
+	if e.voltResponse == nil {
+		return UninitializedAppStatusCode
+	}
+	return e.getAppStatus()
+}
+
+// AppStatusString returns the description of the status set by the stored
+// procedure.
+func (e VoltError) AppStatusString() string {
+	if e.voltResponse == nil {
+		return ""
+	}
+	return e.getAppStatusString()
+}
+
+// Exception returns the message of the exception the server serialized in the
+// response, such as the exception a stored procedure threw. ok is false when
+// the response carries no exception.
+func (e VoltError) Exception() (msg string, ok bool) {
+	if e.voltResponse == nil {
+		return "", false
+	}
+	return e.getException()
+}
+
+// Raw returns the message of the response the server sent, see VoltRows.Raw.
+// It is nil when the request failed in the client.
+func (e VoltError) Raw() []byte {
+	if e.voltResponse == nil {
+		return nil
+	}
+	return e.getRaw()
+}
+
 // helds a processed response, either a VoltResult or a VoltRows
 type voltResponseInfo struct {
 	handle               int64
@@ -50,6 +117,13 @@ type voltResponseInfo struct {
 	appStatusString      string
 	clusterRoundTripTime int32
 	numTables            int16
+	// the time from sending the request to receiving the response.
+	latency time.Duration
+	// the message of the serialized exception, if any.
+	exception    string
+	hasException bool
+	// the message the response was decoded from, when it is kept.
+	raw []byte
 }
 
 func newVoltResponseInfo(handle int64, status ResponseStatus, statusString string, appStatus ResponseStatus, appStatusString string, clusterRoundTripTime int32, numTables int16) *voltResponseInfo {
@@ -104,10 +178,22 @@ func (vrsp voltResponseInfo) getStatus() ResponseStatus {
 	return vrsp.status
 }
 
+func (vrsp voltResponseInfo) getLatency() time.Duration {
+	return vrsp.latency
+}
+
 func (vrsp voltResponseInfo) getStatusString() string {
 	return vrsp.statusString
 }
 
+func (vrsp voltResponseInfo) getException() (string, bool) {
+	return vrsp.exception, vrsp.hasException
+}
+
+func (vrsp voltResponseInfo) getRaw() []byte {
+	return vrsp.raw
+}
+
 // ResponseStatus handles the Status codes returned by the VoltDB server.
 // Each response to a client Query or Exec has an associated status code.
 type ResponseStatus int8
@@ -160,6 +246,7 @@ func decodeResponse(d *wire.Decoder, handle int64) (rsp voltResponse, volterr er
 	// Some fields are optionally included in the response.  Which of these optional
 	// fields are included is indicated by this byte, 'fieldsPresent'.  The set
 	// of optional fields includes 'statusString', 'appStatusString', and 'exceptionLength'.
+	// The exception follows the cluster round trip time.
 	u, err := d.Byte()
 	if err != nil {
 		return nil, VoltError{voltResponse: emptyVoltResponseInfo(), error: err}
@@ -172,15 +259,11 @@ func decodeResponse(d *wire.Decoder, handle int64) (rsp voltResponse, volterr er
 		return nil, VoltError{voltResponse: emptyVoltResponseInfo(), error: err}
 	}
 	var statusString string
-	if status != Success {
-		if fieldsPresent&(1<<5) != 0 {
-			statusString, err = d.String()
-			if err != nil {
-				return nil, VoltError{voltResponse: emptyVoltResponseInfo(), error: err}
-			}
+	if fieldsPresent&(1<<5) != 0 {
+		statusString, err = d.String()
+		if err != nil {
+			return nil, VoltError{voltResponse: emptyVoltResponseInfo(), error: err}
 		}
-		errString := fmt.Sprintf("Bad status %s %s\n", ResponseStatus(status).String(), statusString)
-		return nil, VoltError{voltResponse: emptyVoltResponseInfo(), error: errors.New(errString)}
 	}
 
 	b, err = d.Byte()
@@ -189,20 +272,44 @@ func decodeResponse(d *wire.Decoder, handle int64) (rsp voltResponse, volterr er
 		return nil, VoltError{voltResponse: emptyVoltResponseInfo(), error: err}
 	}
 	var appStatusString string
-	if appStatus != 0 && appStatus != math.MinInt8 {
-		if fieldsPresent&(1<<7) != 0 {
-			appStatusString, err = d.String()
-			if err != nil {
-				return nil, VoltError{voltResponse: emptyVoltResponseInfo(), error: err}
-			}
+	if fieldsPresent&(1<<7) != 0 {
+		appStatusString, err = d.String()
+		if err != nil {
+			return nil, VoltError{voltResponse: emptyVoltResponseInfo(), error: err}
 		}
+	}
+
+	clusterRoundTripTime, rttErr := d.Int32()
+	var (
+		exception    string
+		hasException bool
+		exceptionErr error
+	)
+	if rttErr == nil && fieldsPresent&(1<<6) != 0 {
+		exception, hasException, exceptionErr = decodeException(d)
+	}
+
+	// a failure is reported even if the rest of the response can't be read.
+	if status != Success {
+		info := voltResponseInfo{handle: handle, status: status, statusString: statusString,
+			appStatus: appStatus, appStatusString: appStatusString, clusterRoundTripTime: -1,
+			exception: exception, hasException: hasException}
+		errString := fmt.Sprintf("Bad status %s %s\n", status.String(), statusString)
+		return nil, VoltError{voltResponse: info, error: errors.New(errString)}
+	}
+	if appStatus != 0 && appStatus != math.MinInt8 {
+		info := voltResponseInfo{handle: handle, status: status, statusString: statusString,
+			appStatus: appStatus, appStatusString: appStatusString, clusterRoundTripTime: -1,
+			exception: exception, hasException: hasException}
 		errString := fmt.Sprintf("Bad app status %d %s\n", appStatus, appStatusString)
-		return nil, VoltError{voltResponse: emptyVoltResponseInfo(), error: errors.New(errString)}
+		return nil, VoltError{voltResponse: info, error: errors.New(errString)}
 	}
 
-	clusterRoundTripTime, err := d.Int32()
-	if err != nil {
-		return nil, VoltError{voltResponse: emptyVoltResponseInfo(), error: err}
+	if rttErr != nil {
+		return nil, VoltError{voltResponse: emptyVoltResponseInfo(), error: rttErr}
+	}
+	if exceptionErr != nil {
+		return nil, VoltError{voltResponse: emptyVoltResponseInfo(), error: exceptionErr}
 	}
 
 	numTables, err := d.Int16()
@@ -218,6 +325,31 @@ func decodeResponse(d *wire.Decoder, handle int64) (rsp voltResponse, volterr er
 	return *(newVoltResponseInfo(handle, status, statusString, appStatus, appStatusString, clusterRoundTripTime, numTables)), nil
 }
 
+// decodeException reads the exception serialized in a response. It's prefixed
+// with its length, 0 when there is no exception, and holds the ordinal of the
+// exception class and the message followed by the fields of the class.
+func decodeException(d *wire.Decoder) (msg string, ok bool, err error) {
+	size, err := d.Int32()
+	if err != nil {
+		return "", false, err
+	}
+	if size <= 0 {
+		return "", false, nil
+	}
+	b := make([]byte, size)
+	if _, err = io.ReadFull(d, b); err != nil {
+		return "", false, err
+	}
+	e := wire.NewDecoder(bytes.NewReader(b))
+	if _, err = e.Byte(); err != nil { // the exception class
+		return "", false, err
+	}
+	if msg, err = e.String(); err != nil {
+		return "", false, err
+	}
+	return msg, true, nil
+}
+
 func decodeResult(d *wire.Decoder, rsp voltResponse) (VoltResult, error) {
 	numTables := rsp.getNumTables()
 	ras := make([]int64, numTables)
@@ -246,38 +378,36 @@ func decodeRows(d *wire.Decoder, rsp voltResponse) (VoltRows, error) {
 	return *vr, nil
 }
 
-func decodeTableCommon(d *wire.Decoder) (colCount int16, err error) {
+// decodeTableCommon decodes the column count and the status code of a table.
+// The status code is set by the procedure that returned the table, it is
+// math.MinInt8 when the procedure didn't set one.
+func decodeTableCommon(d *wire.Decoder) (colCount int16, statusCode int8, err error) {
 	_, err = d.Int32() // ttlLength
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 	_, err = d.Int32() // metaLength
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
-	statusCode, err := d.Byte()
+	statusCode, err = d.Byte()
 	if err != nil {
-		return 0, err
-	}
-
-	// Todo: not sure about this, sometimes see 0 sometimes -128
-	if statusCode != 0 && statusCode != math.MinInt8 {
-		return 0, fmt.Errorf("Bad return status on table %d", statusCode)
+		return 0, 0, err
 	}
 
 	colCount, err = d.Int16()
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
-	return colCount, nil
+	return colCount, statusCode, nil
 }
 
 // for a result, care only about the number of rows.
 func decodeTableForResult(d *wire.Decoder) (rowsAff int64, err error) {
 
 	var colCount int16
-	colCount, err = decodeTableCommon(d)
+	colCount, _, err = decodeTableCommon(d)
 	if err != nil {
 		return 0, err
 	}
@@ -321,47 +451,73 @@ func decodeTableForResult(d *wire.Decoder) (rowsAff int64, err error) {
 }
 
 func decodeTableForRows(d *wire.Decoder) (*voltTable, error) {
-
-	var colCount int16
-	colCount, err := decodeTableCommon(d)
+	colCount, statusCode, columnTypes, columnNames, rowCount, err := decodeTableHeader(d)
 	if err != nil {
 		return nil, err
 	}
 
+	rows := make([][]byte, rowCount)
+	var rowI int32
+	for rowI = 0; rowI < rowCount; rowI++ {
+		if rows[rowI], err = decodeRow(d); err != nil {
+			return nil, err
+		}
+	}
+
+	vt := newVoltTable(colCount, columnTypes, columnNames, rowCount, rows)
+	vt.statusCode = statusCode
+	return vt, nil
+}
+
+// decodeTableHeader decodes the columns and the row count of a table, the rows
+// follow it.
+func decodeTableHeader(d *wire.Decoder) (colCount int16, statusCode int8, columnTypes []int8, columnNames []string, rowCount int32, err error) {
+	colCount, statusCode, err = decodeTableCommon(d)
+	if err != nil {
+		return 0, 0, nil, nil, 0, err
+	}
+
 	// column type "array" and column name "array" are not
 	// length prefixed arrays. they are really just columnCount
 	// len sequences of bytes (types) and strings (names).
 	var i int16
-	columnTypes := make([]int8, colCount)
+	columnTypes = make([]int8, colCount)
 	for i = 0; i < colCount; i++ {
 		ct, err := d.Byte()
 		if err != nil {
-			return nil, err
+			return 0, 0, nil, nil, 0, err
 		}
 		columnTypes[i] = ct
 	}
 
-	columnNames := make([]string, colCount)
+	columnNames = make([]string, colCount)
 	for i = 0; i < colCount; i++ {
 		cn, err := d.String()
 		if err != nil {
-			return nil, err
+			return 0, 0, nil, nil, 0, err
 		}
 		columnNames[i] = cn
 	}
 
-	rowCount, err := d.Int32()
+	rowCount, err = d.Int32()
 	if err != nil {
-		return nil, err
+		return 0, 0, nil, nil, 0, err
 	}
+	return colCount, statusCode, columnTypes, columnNames, rowCount, nil
+}
 
-	rows := make([][]byte, rowCount)
-	var rowI int32
-	for rowI = 0; rowI < rowCount; rowI++ {
-		rowLen, _ := d.Int32()
-		rows[rowI] = make([]byte, rowLen)
-		_, _ = d.Read(rows[rowI])
+// decodeRow reads the serialized bytes of the next row.
+func decodeRow(d *wire.Decoder) ([]byte, error) {
+	rowLen, err := d.Int32()
+	if err != nil {
+		return nil, err
 	}
-
-	return newVoltTable(colCount, columnTypes, columnNames, rowCount, rows), nil
+	if rowLen < 0 {
+		return nil, fmt.Errorf("voltdbclient: invalid row length %d", rowLen)
+	}
+	row := make([]byte, rowLen)
+	if _, err = io.ReadFull(d, row); err != nil {
+		return nil, err
+	}
+	return row, nil
 }
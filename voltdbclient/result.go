@@ -17,6 +17,8 @@
 
 package voltdbclient
 
+import "time"
+
 // VoltResult is an implementation of database/sql/driver.Result
 type VoltResult struct {
 	voltResponse
@@ -31,6 +33,24 @@ func newVoltResult(resp voltResponse, rowsAff []int64) *VoltResult {
 	}
 }
 
+// ClusterRoundTripTime returns the time the server took to process the
+// request, it is negative when unknown.
+func (vr VoltResult) ClusterRoundTripTime() time.Duration {
+	return roundTripTime(vr.voltResponse)
+}
+
+// Latency returns the time from sending the request to receiving its
+// response, as measured by the client.
+func (vr VoltResult) Latency() time.Duration {
+	return vr.getLatency()
+}
+
+// Raw returns the message of the response the result was decoded from, see
+// VoltRows.Raw.
+func (vr VoltResult) Raw() []byte {
+	return vr.getRaw()
+}
+
 // AdvanceTable advances to the next table. Returns false if there isn't a next
 // table.
 func (vr *VoltResult) AdvanceTable() bool {
@@ -0,0 +1,140 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2017 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"github.com/VoltDB/voltdb-client-go/wire"
+)
+
+// RowStream reads the rows of a response one at a time. Only the current row
+// is decoded, a row is discarded when the stream moves past it, so callers
+// can process a large result without holding all of its rows.
+//
+// VoltDB sends a response as a single message, the message is received in
+// full before the stream is returned. What the stream saves is the copy of
+// every row VoltRows makes and the values decoded from them.
+//
+// The rows of the current table are iterated with
+//
+//	for s.Next() {
+//		var id int64
+//		if err := s.Scan(&id); err != nil {
+//			...
+//		}
+//	}
+//	if err := s.Err(); err != nil {
+//		...
+//	}
+//
+// NextTable moves to the next table of the response.
+type RowStream struct {
+	voltResponse
+	d *wire.Decoder
+	// the number of tables and rows of the current table left to read.
+	tables int16
+	rows   int32
+	// current holds a table with the current row only.
+	current VoltRows
+	err     error
+}
+
+func newRowStream(rsp voltResponse, d *wire.Decoder) *RowStream {
+	return &RowStream{
+		voltResponse: rsp,
+		d:            d,
+		tables:       rsp.getNumTables(),
+		current:      *newVoltRows(rsp, nil),
+	}
+}
+
+// NextTable moves to the next table of the response, the rows left in the
+// current table are skipped. Returns false if there isn't a next table or an
+// error occurred. Next moves to the first table if NextTable wasn't called.
+func (s *RowStream) NextTable() bool {
+	if s.err != nil {
+		return false
+	}
+	for ; s.rows > 0; s.rows-- {
+		if _, s.err = decodeRow(s.d); s.err != nil {
+			return false
+		}
+	}
+	if s.tables == 0 {
+		return false
+	}
+	colCount, statusCode, columnTypes, columnNames, rowCount, err := decodeTableHeader(s.d)
+	if err != nil {
+		s.err = err
+		return false
+	}
+	s.tables--
+	s.rows = rowCount
+	t := newVoltTable(colCount, columnTypes, columnNames, 0, nil)
+	t.statusCode = statusCode
+	s.current = *newVoltRows(s.voltResponse, []*voltTable{t})
+	return true
+}
+
+// Next reads the next row of the current table. Returns false if there isn't
+// a next row or an error occurred.
+func (s *RowStream) Next() bool {
+	if !s.current.isValidTable() && !s.NextTable() {
+		return false
+	}
+	if s.err != nil || s.rows == 0 {
+		return false
+	}
+	row, err := decodeRow(s.d)
+	if err != nil {
+		s.err = err
+		return false
+	}
+	s.rows--
+	t := s.current.table()
+	if t.rows == nil {
+		t.rows = make([][]byte, 1)
+		t.numRows = 1
+	}
+	t.rows[0] = row
+	t.reset()
+	t.advanceRow()
+	return true
+}
+
+// Row returns the current row, its columns are read with the accessors of
+// VoltRows. The returned VoltRows is only valid until the next call to Next or
+// NextTable.
+func (s *RowStream) Row() VoltRows {
+	return s.current
+}
+
+// Scan copies the columns of the current row into the values pointed at by
+// dest, see VoltRows.ScanRow.
+func (s *RowStream) Scan(dest ...interface{}) error {
+	return s.current.ScanRow(dest...)
+}
+
+// Columns returns the names of the columns of the current table.
+func (s *RowStream) Columns() []string {
+	return s.current.Columns()
+}
+
+// Err returns the error that stopped the stream, if any.
+func (s *RowStream) Err() error {
+	return s.err
+}
@@ -19,21 +19,37 @@ package voltdbclient
 
 import (
 	"bytes"
+	"database/sql"
 	"database/sql/driver"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"math"
-	"math/big"
+	"reflect"
 	"strings"
 	"time"
+
+	"github.com/VoltDB/voltdb-client-go/wire"
 )
 
 var nullDecimal = [...]byte{128, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
 var nullTimestamp = [...]byte{128, 0, 0, 0, 0, 0, 0, 0}
+var nullGeographyPoint = wire.GeographyPoint{Longitude: 360, Latitude: 360}
 var order = binary.BigEndian
 
+// ColumnTypeError is returned by the column accessors of VoltRows when the
+// column being read is not of the type the accessor expects.
+type ColumnTypeError struct {
+	Index    int16
+	Expected int8
+	Actual   int8
+}
+
+func (e ColumnTypeError) Error() string {
+	return fmt.Sprintf("column at index %d has type %d, expected type %d", e.Index, e.Actual, e.Expected)
+}
+
 // VoltRows is an implementation of database/sql/driver.Rows.
 //
 // A response to a query from the VoltDB server might include rows from more
@@ -43,7 +59,8 @@ var order = binary.BigEndian
 // The value for a column can be accessed by either column index or by column
 // name.  These accessors return interface{} type; the returned interface
 // needs to be cast to the correct type.  This is how null database values are
-// supported, a null value will be returned as nil.
+// supported, a null value will be returned as nil. Reading a column with the
+// accessor of another type returns a ColumnTypeError.
 type VoltRows struct {
 	voltResponse
 	tables     []*voltTable
@@ -130,7 +147,7 @@ func (vr VoltRows) Next(dest []driver.Value) (err error) {
 			}
 			dest[i] = v
 		case 9: // STRING
-			v, err := vr.GetVarbinary(int16(i))
+			v, err := vr.getVarLength(int16(i))
 			if err != nil {
 				return fmt.Errorf("Failed to get STRING/VARBINARY at column index %d %s", i, err)
 			}
@@ -142,7 +159,11 @@ func (vr VoltRows) Next(dest []driver.Value) (err error) {
 			}
 			dest[i] = v
 		case 22: // DECIMAL
-			return fmt.Errorf("Not supporting DECIMAL")
+			v, err := vr.GetDecimal(int16(i))
+			if err != nil {
+				return fmt.Errorf("Failed to get DECIMAL at column index %d %s", i, err)
+			}
+			dest[i] = v
 		case 25: // VARBINARY
 			v, err := vr.GetVarbinary(int16(i))
 			if err != nil {
@@ -150,9 +171,17 @@ func (vr VoltRows) Next(dest []driver.Value) (err error) {
 			}
 			dest[i] = v
 		case 26: // GEOGRAPHY_POINT
-			return errors.New("Not supporting GEOGRAPHY_POINT")
+			v, err := vr.GetGeographyPoint(int16(i))
+			if err != nil {
+				return fmt.Errorf("Failed to get GEOGRAPHY_POINT at column index %d %s", i, err)
+			}
+			dest[i] = v
 		case 27: // GEOGRAPHY
-			return errors.New("Not supporting GEOGRAPHY")
+			v, err := vr.GetGeography(int16(i))
+			if err != nil {
+				return fmt.Errorf("Failed to get GEOGRAPHY at column index %d %s", i, err)
+			}
+			dest[i] = v
 		default:
 			return fmt.Errorf("Unexpected type %d", ct)
 		}
@@ -161,11 +190,48 @@ func (vr VoltRows) Next(dest []driver.Value) (err error) {
 }
 
 // AdvanceRow advances to the next row of data, returns false if there isn't a
-// next row.
+// next row. The column accessors read from the row AdvanceRow moved to, so the
+// rows of the current table are iterated with
+//
+//	for rows.AdvanceRow() {
+//		v, err := rows.GetInteger(0)
+//		...
+//	}
 func (vr VoltRows) AdvanceRow() bool {
+	if !vr.isValidTable() {
+		return false
+	}
 	return vr.table().advanceRow()
 }
 
+// Reset rewinds the current table, the next call to AdvanceRow moves to its
+// first row again.
+func (vr VoltRows) Reset() {
+	if vr.isValidTable() {
+		vr.table().reset()
+	}
+}
+
+// eachRow calls f with the cursor on each row of the current table in turn,
+// stopping at the first error. The cursor is put back where it was.
+func (vr VoltRows) eachRow(f func() error) error {
+	vt := vr.table()
+	rowIndex := vt.rowIndex
+	defer func() {
+		vt.reset()
+		if rowIndex != invalidRowIndex {
+			vt.advanceToRow(rowIndex)
+		}
+	}()
+	vt.reset()
+	for vt.advanceRow() {
+		if err := f(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // AdvanceToRow advances to the row of data indicated by the index.  Returns
 // false if there is no row at the given index.
 func (vr VoltRows) AdvanceToRow(rowIndex int32) bool {
@@ -191,6 +257,81 @@ func (vr *VoltRows) AdvanceToTable(tableIndex int16) bool {
 	return true
 }
 
+// Table returns the rows positioned on the table at the given index, the
+// current table of vr is left as it is. The tables and their current rows are
+// shared with vr.
+func (vr VoltRows) Table(tableIndex int) (VoltRows, error) {
+	if tableIndex < 0 || tableIndex >= len(vr.tables) {
+		return VoltRows{}, fmt.Errorf("table index %d is out of range", tableIndex)
+	}
+	vr.tableIndex = int16(tableIndex)
+	return vr, nil
+}
+
+// TableCount returns the number of tables in the response.
+func (vr VoltRows) TableCount() int {
+	return len(vr.tables)
+}
+
+// Tables returns the rows positioned on each table of the response in turn,
+// like Table does.
+func (vr VoltRows) Tables() []VoltRows {
+	tables := make([]VoltRows, len(vr.tables))
+	for i := range tables {
+		tables[i], _ = vr.Table(i)
+	}
+	return tables
+}
+
+// SingleTable returns the rows positioned on the only table of the response,
+// most procedures return a single table. An error is returned when the
+// response doesn't have exactly one table.
+func (vr VoltRows) SingleTable() (VoltRows, error) {
+	if len(vr.tables) != 1 {
+		return VoltRows{}, fmt.Errorf("expected a single table but the response has %d", len(vr.tables))
+	}
+	return vr.Table(0)
+}
+
+// ClusterRoundTripTime returns the time the server took to process the
+// request, it is negative when unknown.
+func (vr VoltRows) ClusterRoundTripTime() time.Duration {
+	return roundTripTime(vr.voltResponse)
+}
+
+// Latency returns the time from sending the request to receiving its
+// response, as measured by the client.
+func (vr VoltRows) Latency() time.Duration {
+	return vr.getLatency()
+}
+
+// Raw returns the message of the response the rows were decoded from, for
+// decoding parts of it the client doesn't. It starts with the protocol
+// version, the int32 length prefix of the message is left out. Raw is nil
+// unless ConnectOptions.KeepRawResponses is set, the returned slice must not
+// be modified.
+func (vr VoltRows) Raw() []byte {
+	return vr.getRaw()
+}
+
+// RowCount returns the number of rows in the current table.
+func (vr VoltRows) RowCount() int {
+	if !vr.isValidTable() {
+		return 0
+	}
+	return int(vr.table().numRows)
+}
+
+// TableStatusCode returns the status code the procedure set on the current
+// table, it is math.MinInt8 when the procedure didn't set one. VoltDB
+// procedures use it to report the outcome for a table to the client.
+func (vr VoltRows) TableStatusCode() int8 {
+	if !vr.isValidTable() {
+		return math.MinInt8
+	}
+	return vr.table().statusCode
+}
+
 // ColumnCount returns the number of columns in the current table.
 func (vr VoltRows) ColumnCount() int {
 	if !vr.isValidTable() {
@@ -208,9 +349,64 @@ func (vr VoltRows) ColumnTypes() []int8 {
 	return rv
 }
 
+// ColumnTypesTyped returns the column types of the columns in the current
+// table as ColumnType values, see ColumnTypes.
+func (vr VoltRows) ColumnTypesTyped() []ColumnType {
+	var rv []ColumnType
+	if vr.isValidTable() {
+		for _, ct := range vr.table().columnTypes {
+			rv = append(rv, ColumnType(ct))
+		}
+	}
+	return rv
+}
+
+// ColumnName returns the name of the column at the given index in the current
+// table.
+func (vr VoltRows) ColumnName(colIndex int) (string, error) {
+	if !vr.isValidTable() {
+		return "", errors.New("No valid table")
+	}
+	cns := vr.table().columnNames
+	if colIndex < 0 || colIndex >= len(cns) {
+		return "", fmt.Errorf("column index %d is out of range", colIndex)
+	}
+	return cns[colIndex], nil
+}
+
+// ColumnType returns the type of the column at the given index in the current
+// table.
+func (vr VoltRows) ColumnType(colIndex int) (ColumnType, error) {
+	if !vr.isValidTable() {
+		return 0, errors.New("No valid table")
+	}
+	cts := vr.table().columnTypes
+	if colIndex < 0 || colIndex >= len(cts) {
+		return 0, fmt.Errorf("column index %d is out of range", colIndex)
+	}
+	return ColumnType(cts[colIndex]), nil
+}
+
+// ColumnIndex returns the index of the column with the given name in the
+// current table. Column names are case insensitive, when more than one column
+// has the name the index of the first is returned.
+func (vr VoltRows) ColumnIndex(cn string) (int, error) {
+	if !vr.isValidTable() {
+		return 0, errors.New("No valid table")
+	}
+	ci, ok := vr.table().cnToCi[strings.ToUpper(cn)]
+	if !ok {
+		return 0, fmt.Errorf("column name %v was not found", cn)
+	}
+	return int(ci), nil
+}
+
 // GetBigInt returns the value of a BIGINT column at the given index in the
 // current row.
 func (vr VoltRows) GetBigInt(colIndex int16) (interface{}, error) {
+	if err := vr.checkColumnType(colIndex, wire.LongColumn); err != nil {
+		return nil, err
+	}
 	bs, err := vr.table().getBytes(vr.table().rowIndex, colIndex)
 	if err != nil {
 		return nil, err
@@ -236,8 +432,12 @@ func (vr VoltRows) GetBigIntByName(cn string) (interface{}, error) {
 }
 
 // GetDecimal returns the value of a DECIMAL column at the given index in the
-// current row.
+// current row. The value is returned as a *big.Rat, a null DECIMAL is returned
+// as nil.
 func (vr VoltRows) GetDecimal(colIndex int16) (interface{}, error) {
+	if err := vr.checkColumnType(colIndex, wire.DecimalColumn); err != nil {
+		return nil, err
+	}
 	bs, err := vr.table().getBytes(vr.table().rowIndex, colIndex)
 	if err != nil {
 		return nil, err
@@ -245,23 +445,12 @@ func (vr VoltRows) GetDecimal(colIndex int16) (interface{}, error) {
 	if len(bs) != 16 {
 		return nil, fmt.Errorf("Did not find at DECIMAL column at index %d\n", colIndex)
 	}
-	if bytes.Compare(bs, nullDecimal[:]) == 0 {
-		return nil, nil
-	}
-	var leadingZeroCount = 0
-	for i, b := range bs {
-		if b != 0 {
-			leadingZeroCount = i
-			break
-		}
+	d, err := wire.NewDecoder(bytes.NewReader(bs)).Decimal()
+	if d == nil || err != nil {
+		// a NULL DECIMAL is returned as an untyped nil.
+		return nil, err
 	}
-	bi := new(big.Int)
-	bi.SetBytes(bs[leadingZeroCount:])
-	fl := new(big.Float)
-	fl.SetInt(bi)
-	dec := new(big.Float)
-	dec = dec.Quo(fl, big.NewFloat(1e12))
-	return dec, nil
+	return d, nil
 }
 
 // GetDecimalByName returns the value of a DECIMAL column with the given name in
@@ -274,9 +463,45 @@ func (vr VoltRows) GetDecimalByName(cn string) (interface{}, error) {
 	return vr.GetDecimal(ci)
 }
 
+// GetDecimalWithScale returns the value of a DECIMAL column at the given index
+// in the current row as a wire.VoltDecimal with the given scale, for callers
+// formatting it with a number of fractional digits of their choosing. VoltDB
+// stores DECIMALs with a scale of wire.DecimalScale, digits dropped by a
+// smaller scale are rounded half to even. A null DECIMAL is returned as nil.
+func (vr VoltRows) GetDecimalWithScale(colIndex int16, scale int) (interface{}, error) {
+	if err := vr.checkColumnType(colIndex, wire.DecimalColumn); err != nil {
+		return nil, err
+	}
+	bs, err := vr.table().getBytes(vr.table().rowIndex, colIndex)
+	if err != nil {
+		return nil, err
+	}
+	if len(bs) != wire.DecimalSize {
+		return nil, fmt.Errorf("Did not find at DECIMAL column at index %d\n", colIndex)
+	}
+	d, err := wire.NewDecoder(bytes.NewReader(bs)).VoltDecimal()
+	if d.Unscaled == nil || err != nil {
+		return nil, err
+	}
+	return d.Rescale(scale), nil
+}
+
+// GetDecimalWithScaleByName returns the value of a DECIMAL column with the
+// given name in the current row, see GetDecimalWithScale.
+func (vr VoltRows) GetDecimalWithScaleByName(cn string, scale int) (interface{}, error) {
+	ci, ok := vr.table().cnToCi[strings.ToUpper(cn)]
+	if !ok {
+		return nil, fmt.Errorf("column name %v was not found", cn)
+	}
+	return vr.GetDecimalWithScale(ci, scale)
+}
+
 // GetFloat returns the value of a FLOAT column at the given index in the
 // current row.
 func (vr VoltRows) GetFloat(colIndex int16) (interface{}, error) {
+	if err := vr.checkColumnType(colIndex, wire.FloatColumn); err != nil {
+		return nil, err
+	}
 	bs, err := vr.table().getBytes(vr.table().rowIndex, colIndex)
 	if err != nil {
 		return nil, err
@@ -301,9 +526,73 @@ func (vr VoltRows) GetFloatByName(cn string) (interface{}, error) {
 	return vr.GetFloat(ci)
 }
 
+// GetGeography returns the value of a GEOGRAPHY column at the given index in
+// the current row. The value is returned as a wire.GeographyPolygon, a null
+// GEOGRAPHY is returned as nil.
+func (vr VoltRows) GetGeography(colIndex int16) (interface{}, error) {
+	if err := vr.checkColumnType(colIndex, wire.GeographyColumn); err != nil {
+		return nil, err
+	}
+	bs, err := vr.table().getBytes(vr.table().rowIndex, colIndex)
+	if err != nil {
+		return nil, err
+	}
+	if len(bs) == 4 && bytesToInt(bs) == -1 {
+		return nil, nil
+	}
+	return wire.NewDecoder(bytes.NewReader(bs)).Geography()
+}
+
+// GetGeographyByName returns the value of a GEOGRAPHY column with the given
+// name in the current row.
+func (vr VoltRows) GetGeographyByName(cn string) (interface{}, error) {
+	ci, ok := vr.table().cnToCi[strings.ToUpper(cn)]
+	if !ok {
+		return nil, fmt.Errorf("column name %v was not found", cn)
+	}
+	return vr.GetGeography(ci)
+}
+
+// GetGeographyPoint returns the value of a GEOGRAPHY_POINT column at the given
+// index in the current row. The value is returned as a wire.GeographyPoint, a
+// null GEOGRAPHY_POINT is returned as nil.
+func (vr VoltRows) GetGeographyPoint(colIndex int16) (interface{}, error) {
+	if err := vr.checkColumnType(colIndex, wire.GeographyPointColumn); err != nil {
+		return nil, err
+	}
+	bs, err := vr.table().getBytes(vr.table().rowIndex, colIndex)
+	if err != nil {
+		return nil, err
+	}
+	if len(bs) != wire.GeographyPointSize {
+		return nil, fmt.Errorf("Did not find at GEOGRAPHY_POINT column at index %d\n", colIndex)
+	}
+	p, err := wire.NewDecoder(bytes.NewReader(bs)).GeographyPoint()
+	if err != nil {
+		return nil, err
+	}
+	if p == nullGeographyPoint {
+		return nil, nil
+	}
+	return p, nil
+}
+
+// GetGeographyPointByName returns the value of a GEOGRAPHY_POINT column with
+// the given name in the current row.
+func (vr VoltRows) GetGeographyPointByName(cn string) (interface{}, error) {
+	ci, ok := vr.table().cnToCi[strings.ToUpper(cn)]
+	if !ok {
+		return nil, fmt.Errorf("column name %v was not found", cn)
+	}
+	return vr.GetGeographyPoint(ci)
+}
+
 // GetInteger returns the value of a INTEGER column at the given index in the
 // current row.
 func (vr VoltRows) GetInteger(colIndex int16) (interface{}, error) {
+	if err := vr.checkColumnType(colIndex, wire.IntColumn); err != nil {
+		return nil, err
+	}
 	bs, err := vr.table().getBytes(vr.table().rowIndex, colIndex)
 	if err != nil {
 		return nil, err
@@ -331,6 +620,9 @@ func (vr VoltRows) GetIntegerByName(cn string) (interface{}, error) {
 // GetSmallInt returns the value of a SMALLINT column at the given index in the
 // current row.
 func (vr VoltRows) GetSmallInt(colIndex int16) (interface{}, error) {
+	if err := vr.checkColumnType(colIndex, wire.ShortColumn); err != nil {
+		return nil, err
+	}
 	bs, err := vr.table().getBytes(vr.table().rowIndex, colIndex)
 	if err != nil {
 		return nil, err
@@ -358,6 +650,9 @@ func (vr VoltRows) GetSmallIntByName(cn string) (interface{}, error) {
 // GetString returns the value of a STRING column at the given index in the
 // current row.
 func (vr VoltRows) GetString(colIndex int16) (interface{}, error) {
+	if err := vr.checkColumnType(colIndex, wire.StringColumn); err != nil {
+		return nil, err
+	}
 	bs, err := vr.table().getBytes(vr.table().rowIndex, colIndex)
 	if err != nil {
 		return nil, err
@@ -385,8 +680,12 @@ func (vr VoltRows) GetStringByName(cn string) (interface{}, error) {
 }
 
 // GetTimestamp returns the value of a TIMESTAMP column at the given index in
-// the current row.
+// the current row as a time.Time in UTC, a null value is returned as nil.
+// Timestamps before the epoch are supported.
 func (vr VoltRows) GetTimestamp(colIndex int16) (interface{}, error) {
+	if err := vr.checkColumnType(colIndex, wire.TimestampColumn); err != nil {
+		return nil, err
+	}
 	bs, err := vr.table().getBytes(vr.table().rowIndex, colIndex)
 	if err != nil {
 		return nil, err
@@ -414,6 +713,9 @@ func (vr VoltRows) GetTimestampByName(cn string) (interface{}, error) {
 // GetTinyInt returns the value of a TINYINT column at the given index in the
 // current row.
 func (vr VoltRows) GetTinyInt(colIndex int16) (interface{}, error) {
+	if err := vr.checkColumnType(colIndex, wire.TinyIntColumn); err != nil {
+		return nil, err
+	}
 	bs, err := vr.table().getBytes(vr.table().rowIndex, colIndex)
 	if err != nil {
 		return nil, err
@@ -442,6 +744,15 @@ func (vr VoltRows) GetTinyIntByName(cn string) (interface{}, error) {
 // GetVarbinary returns the value of a VARBINARY column at the given index in
 // the current row.
 func (vr VoltRows) GetVarbinary(colIndex int16) (interface{}, error) {
+	if err := vr.checkColumnType(colIndex, wire.VarBinColumn); err != nil {
+		return nil, err
+	}
+	return vr.getVarLength(colIndex)
+}
+
+// getVarLength returns the bytes of a STRING or VARBINARY column at the given
+// index in the current row without the length prefix.
+func (vr VoltRows) getVarLength(colIndex int16) (interface{}, error) {
 	bs, err := vr.table().getBytes(vr.table().rowIndex, colIndex)
 	if err != nil {
 		return nil, err
@@ -465,6 +776,194 @@ func (vr VoltRows) GetVarbinaryByName(cn string) (interface{}, error) {
 	return vr.GetVarbinary(ci)
 }
 
+// IsNull reports whether the column at the given index in the current row holds
+// a null value. VoltDB encodes null as a sentinel value of the column type,
+// e.g. math.MinInt32 for an INTEGER, so IsNull tells a null apart from data.
+func (vr VoltRows) IsNull(colIndex int16) (bool, error) {
+	cts := vr.table().getColumnTypes()
+	if colIndex < 0 || int(colIndex) >= len(cts) {
+		return false, fmt.Errorf("column index %d is out of range", colIndex)
+	}
+	if cts[colIndex] == wire.NullColumn {
+		return true, nil
+	}
+	get, ok := columnAccessors[cts[colIndex]]
+	if !ok {
+		return false, fmt.Errorf("Unexpected type %d", cts[colIndex])
+	}
+	v, err := get(vr, colIndex)
+	if err != nil {
+		return false, err
+	}
+	return v == nil, nil
+}
+
+// IsNullByName reports whether the column with the given name in the current
+// row holds a null value.
+func (vr VoltRows) IsNullByName(cn string) (bool, error) {
+	ci, ok := vr.table().cnToCi[strings.ToUpper(cn)]
+	if !ok {
+		return false, fmt.Errorf("column name %v was not found", cn)
+	}
+	return vr.IsNull(ci)
+}
+
+// ScanRow copies the columns of the current row into the values pointed at by
+// dest, there must be one dest per column. A column is stored in a pointer to
+// the type its accessor returns, e.g. *int32 for an INTEGER column, *string
+// for a STRING column or **big.Rat for a DECIMAL column, or in a
+// *interface{}. A dest implementing sql.Scanner, like the sql.Null* types,
+// is passed the column value instead, a null value is passed as nil. Scanning
+// a null value into any other dest returns an error.
+func (vr VoltRows) ScanRow(dest ...interface{}) error {
+	if !vr.isValidTable() {
+		return errors.New("No valid table")
+	}
+	if vr.table().rowIndex == invalidRowIndex {
+		return errors.New("ScanRow called before AdvanceRow")
+	}
+	if vr.table().getColumnCount() != len(dest) {
+		return fmt.Errorf("Wrong number of values to ScanRow, expected %d but saw %d", vr.table().getColumnCount(), len(dest))
+	}
+	for i, ct := range vr.table().getColumnTypes() {
+		get, ok := columnAccessors[ct]
+		if !ok {
+			return fmt.Errorf("Unexpected type %d", ct)
+		}
+		v, err := get(vr, int16(i))
+		if err != nil {
+			return err
+		}
+		if err := scanValue(dest[i], v); err != nil {
+			return fmt.Errorf("Failed to scan column index %d %s", i, err)
+		}
+	}
+	return nil
+}
+
+// DecodeRow copies the columns of the current row into the exported fields of
+// the struct dest points at. A field is decoded from the column named by its
+// volt struct tag, such as `volt:"colname"`, or else from the column with the
+// name of the field, names are case insensitive. Fields tagged `volt:"-"` are
+// skipped and columns without a field are ignored. A field without a column
+// returns an error unless it's tagged with the omitempty option, such as
+// `volt:"colname,omitempty"`, then it is left as it is. The fields are set
+// the way ScanRow sets its dest values.
+func (vr VoltRows) DecodeRow(dest interface{}) error {
+	if !vr.isValidTable() {
+		return errors.New("No valid table")
+	}
+	if vr.table().rowIndex == invalidRowIndex {
+		return errors.New("DecodeRow called before AdvanceRow")
+	}
+	d := reflect.ValueOf(dest)
+	if d.Kind() != reflect.Ptr || d.IsNil() || d.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("destination %T is not a pointer to a struct", dest)
+	}
+	e := d.Elem()
+	t := e.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag, err := parseVoltTag(f)
+		if err != nil {
+			return err
+		}
+		if tag.skip {
+			continue
+		}
+		cn := tag.name
+		if cn == "" {
+			cn = f.Name
+		}
+		ci, ok := vr.table().cnToCi[strings.ToUpper(cn)]
+		if !ok {
+			if tag.omitempty {
+				continue
+			}
+			return fmt.Errorf("no column %s for field %s", cn, f.Name)
+		}
+		ct := vr.table().columnTypes[ci]
+		get, ok := columnAccessors[ct]
+		if !ok {
+			return fmt.Errorf("Unexpected type %d", ct)
+		}
+		v, err := get(vr, ci)
+		if err != nil {
+			return err
+		}
+		if err := scanValue(e.Field(i).Addr().Interface(), v); err != nil {
+			return fmt.Errorf("Failed to decode column %s into field %s %s", cn, f.Name, err)
+		}
+	}
+	return nil
+}
+
+// columnValue returns the value of the column at the given index in the
+// current row, read with the accessor of its type.
+func (vr VoltRows) columnValue(colIndex int16) (interface{}, error) {
+	ct := vr.table().columnTypes[colIndex]
+	get, ok := columnAccessors[ct]
+	if !ok {
+		return nil, fmt.Errorf("Unexpected type %d", ct)
+	}
+	return get(vr, colIndex)
+}
+
+// columnAccessors maps the column types to the accessors reading them.
+var columnAccessors = map[int8]func(vr VoltRows, colIndex int16) (interface{}, error){
+	wire.TinyIntColumn:        VoltRows.GetTinyInt,
+	wire.ShortColumn:          VoltRows.GetSmallInt,
+	wire.IntColumn:            VoltRows.GetInteger,
+	wire.LongColumn:           VoltRows.GetBigInt,
+	wire.FloatColumn:          VoltRows.GetFloat,
+	wire.StringColumn:         VoltRows.GetString,
+	wire.TimestampColumn:      VoltRows.GetTimestamp,
+	wire.DecimalColumn:        VoltRows.GetDecimal,
+	wire.VarBinColumn:         VoltRows.GetVarbinary,
+	wire.GeographyPointColumn: VoltRows.GetGeographyPoint,
+	wire.GeographyColumn:      VoltRows.GetGeography,
+}
+
+func scanValue(dest, v interface{}) error {
+	if s, ok := dest.(sql.Scanner); ok {
+		return s.Scan(v)
+	}
+	d := reflect.ValueOf(dest)
+	if d.Kind() != reflect.Ptr || d.IsNil() {
+		return fmt.Errorf("destination %T is not a pointer", dest)
+	}
+	e := d.Elem()
+	if v == nil {
+		if e.Kind() != reflect.Interface {
+			return fmt.Errorf("can't scan a null value into %T", dest)
+		}
+		e.Set(reflect.Zero(e.Type()))
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.Type().AssignableTo(e.Type()) {
+		return fmt.Errorf("can't scan a %T value into %T", v, dest)
+	}
+	e.Set(rv)
+	return nil
+}
+
+// checkColumnType returns a ColumnTypeError if the column at the given index in
+// the current table isn't of the expected type.
+func (vr VoltRows) checkColumnType(colIndex int16, expected int8) error {
+	cts := vr.table().getColumnTypes()
+	if colIndex < 0 || int(colIndex) >= len(cts) {
+		return fmt.Errorf("column index %d is out of range", colIndex)
+	}
+	if cts[colIndex] != expected {
+		return ColumnTypeError{Index: colIndex, Expected: expected, Actual: cts[colIndex]}
+	}
+	return nil
+}
+
 func (vr VoltRows) isValidTable() bool {
 	return vr.tableIndex != -1
 }
@@ -491,8 +990,11 @@ func bytesToSmallInt(bs []byte) int16 {
 }
 
 func bytesToTime(bs []byte) time.Time {
-	// the time is essentially a long as milliseconds
-	millis := int64(order.Uint64(bs))
-	// time.Unix will take either seconds or nanos. Multiply by 1000 and use nanos.
-	return time.Unix(0, millis*1000)
+	// the time is a long holding microseconds since the epoch, VoltDB has no
+	// other timestamp resolution.
+	micros := int64(order.Uint64(bs))
+	// the seconds are split off first, the nanoseconds of timestamps far from
+	// the epoch overflow an int64. time.Unix normalizes the negative remainder
+	// of times before the epoch.
+	return time.Unix(micros/1e6, micros%1e6*1e3).UTC()
 }
@@ -0,0 +1,92 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2017 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// WriteCSV writes the current table to w as RFC 4180 CSV, a header row with the
+// column names is followed by a record per row. Timestamps are formatted with
+// timeLayout, time.RFC3339Nano when it is empty, VARBINARY values are base64
+// encoded and null values are written as empty fields. The rows are written
+// as they are read, the output isn't held in memory.
+//
+// The row cursor of the table is left where it was.
+func (vr VoltRows) WriteCSV(w io.Writer, timeLayout string) error {
+	if !vr.isValidTable() {
+		return errors.New("No valid table")
+	}
+	if timeLayout == "" {
+		timeLayout = time.RFC3339Nano
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(vr.table().columnNames); err != nil {
+		return err
+	}
+	record := make([]string, vr.table().columnCount)
+	err := vr.eachRow(func() error {
+		for i := range record {
+			v, err := vr.columnValue(int16(i))
+			if err != nil {
+				return err
+			}
+			record[i] = csvValue(v, timeLayout)
+		}
+		return cw.Write(record)
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvValue formats a column value as a CSV field.
+func csvValue(v interface{}, timeLayout string) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case int8:
+		return strconv.FormatInt(int64(x), 10)
+	case int16:
+		return strconv.FormatInt(int64(x), 10)
+	case int32:
+		return strconv.FormatInt(int64(x), 10)
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	case string:
+		return x
+	case []byte:
+		return base64.StdEncoding.EncodeToString(x)
+	case time.Time:
+		return x.UTC().Format(timeLayout)
+	case *big.Rat:
+		return decimalString(x)
+	}
+	return fmt.Sprint(v)
+}
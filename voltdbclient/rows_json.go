@@ -0,0 +1,130 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2017 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/VoltDB/voltdb-client-go/wire"
+)
+
+// MarshalJSON implements json.Marshaler, the current table is encoded as an
+// array holding an object per row. The keys of a row are the column names in
+// column order and the values are typed: numbers for the numeric columns,
+// including DECIMAL, strings for VARCHAR, RFC 3339 strings for TIMESTAMP and
+// base64 strings for VARBINARY. Null values are encoded as null. Use Tables to
+// encode every table of the response, json.Marshal(rows.Tables()) gives an
+// array of tables.
+//
+// The row cursor of the table is left where it was.
+func (vr VoltRows) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	if !vr.isValidTable() {
+		buf.WriteByte(']')
+		return buf.Bytes(), nil
+	}
+	vt := vr.table()
+	keys := make([][]byte, len(vt.columnNames))
+	for i, cn := range vt.columnNames {
+		k, err := json.Marshal(cn)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = k
+	}
+	first := true
+	err := vr.eachRow(func() error {
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			v, err := vr.columnValue(int16(i))
+			if err != nil {
+				return err
+			}
+			b, err := json.Marshal(jsonValue(v))
+			if err != nil {
+				return err
+			}
+			buf.Write(b)
+		}
+		buf.WriteByte('}')
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// responseJSON is the JSON encoding of a Response.
+type responseJSON struct {
+	Status string     `json:"status"`
+	Error  string     `json:"error,omitempty"`
+	Tables []VoltRows `json:"tables,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler, the response is encoded as an object
+// holding its status and either the error message of a failed call or the
+// tables of the rows, each encoded like VoltRows.MarshalJSON does. Calls that
+// failed without a response from the server have the RESPONSE UNKNOWN status.
+func (r *Response) MarshalJSON() ([]byte, error) {
+	if r.Err != nil {
+		status := ResponseUnknown
+		if verr, ok := r.Err.(VoltError); ok {
+			status = verr.Status()
+		}
+		return json.Marshal(responseJSON{Status: status.String(), Error: r.Err.Error()})
+	}
+	rj := responseJSON{Status: Success.String()}
+	if vr, ok := r.Rows.(VoltRows); ok {
+		rj.Tables = vr.Tables()
+	}
+	return json.Marshal(rj)
+}
+
+// jsonValue returns the value a column value is encoded to JSON as.
+func jsonValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case time.Time:
+		return x.UTC().Format(time.RFC3339Nano)
+	case *big.Rat:
+		return json.Number(decimalString(x))
+	}
+	return v
+}
+
+// decimalString formats a DECIMAL value without the trailing zeros of its
+// fractional digits.
+func decimalString(r *big.Rat) string {
+	s := strings.TrimRight(r.FloatString(wire.DecimalScale), "0")
+	return strings.TrimSuffix(s, ".")
+}
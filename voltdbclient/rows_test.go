@@ -0,0 +1,707 @@
+package voltdbclient
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/VoltDB/voltdb-client-go/wire"
+)
+
+// newTestRows returns VoltRows holding a single table with the given columns,
+// each row is the raw serialized bytes of the row values.
+func newTestRows(types []int8, names []string, rows ...[]byte) VoltRows {
+	t := newVoltTable(int16(len(types)), types, names, int32(len(rows)), rows)
+	rsp := voltResponseInfo{status: Success, numTables: 1}
+	return *newVoltRows(rsp, []*voltTable{t})
+}
+
+func decimalBytes(t *testing.T, v *big.Rat) []byte {
+	e := wire.NewEncoder()
+	_, err := e.MarshalDecimal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// skip the type byte
+	return e.Bytes()[1:]
+}
+
+// columnBytes returns the serialized value of a column.
+func columnBytes(t *testing.T, v interface{}) []byte {
+	e := wire.NewEncoder()
+	if _, err := e.Marshal(v); err != nil {
+		t.Fatal(err)
+	}
+	// skip the type byte
+	return e.Bytes()[1:]
+}
+
+// nullColumnBytes returns the serialized null value of a column of type ct.
+func nullColumnBytes(t *testing.T, ct int8) []byte {
+	e := wire.NewEncoder()
+	if _, err := e.MarshalNull(ct); err != nil {
+		t.Fatal(err)
+	}
+	// skip the type byte
+	return e.Bytes()[1:]
+}
+
+func TestVoltRows_Getters(t *testing.T) {
+	ts := time.Date(2017, 3, 14, 15, 9, 26, 535000, time.UTC)
+	sample := []struct {
+		ct    int8
+		v     interface{}
+		get   func(vr VoltRows, colIndex int16) (interface{}, error)
+		equal func(a, b interface{}) bool
+	}{
+		{ct: wire.TinyIntColumn, v: int8(-7), get: VoltRows.GetTinyInt},
+		{ct: wire.ShortColumn, v: int16(-300), get: VoltRows.GetSmallInt},
+		{ct: wire.IntColumn, v: int32(70000), get: VoltRows.GetInteger},
+		{ct: wire.LongColumn, v: int64(-5000000000), get: VoltRows.GetBigInt},
+		{ct: wire.FloatColumn, v: 3.25, get: VoltRows.GetFloat},
+		{ct: wire.StringColumn, v: "volt", get: VoltRows.GetString},
+		{ct: wire.VarBinColumn, v: []byte{1, 2, 3}, get: VoltRows.GetVarbinary,
+			equal: func(a, b interface{}) bool { return bytes.Equal(a.([]byte), b.([]byte)) }},
+		{ct: wire.TimestampColumn, v: ts, get: VoltRows.GetTimestamp,
+			equal: func(a, b interface{}) bool { return a.(time.Time).Equal(b.(time.Time)) }},
+	}
+	for _, s := range sample {
+		equal := s.equal
+		if equal == nil {
+			equal = func(a, b interface{}) bool { return a == b }
+		}
+		rows := newTestRows([]int8{s.ct}, []string{"C"}, columnBytes(t, s.v), nullColumnBytes(t, s.ct))
+		rows.AdvanceRow()
+		v, err := s.get(rows, 0)
+		if err != nil {
+			t.Fatalf("column type %d: %v", s.ct, err)
+		}
+		if !equal(v, s.v) {
+			t.Errorf("column type %d: expected %v got %v", s.ct, s.v, v)
+		}
+		rows.AdvanceRow()
+		v, err = s.get(rows, 0)
+		if err != nil {
+			t.Fatalf("column type %d: %v", s.ct, err)
+		}
+		if v != nil {
+			t.Errorf("column type %d: expected nil got %v", s.ct, v)
+		}
+
+		// every other accessor must refuse the column.
+		for _, o := range sample {
+			if o.ct == s.ct {
+				continue
+			}
+			if _, err := o.get(rows, 0); err == nil {
+				t.Errorf("column type %d: expected an error reading it as type %d", s.ct, o.ct)
+			} else if _, ok := err.(ColumnTypeError); !ok {
+				t.Errorf("column type %d: expected ColumnTypeError got %v", s.ct, err)
+			}
+		}
+	}
+}
+
+func TestVoltRows_GetDecimal(t *testing.T) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(38), nil)
+	max.Sub(max, big.NewInt(1))
+	largest := new(big.Rat).SetFrac(max, new(big.Int).Exp(big.NewInt(10), big.NewInt(12), nil))
+	smallest := new(big.Rat).Neg(largest)
+	sample := []*big.Rat{
+		big.NewRat(0, 1),
+		big.NewRat(1, 1000000000000),
+		big.NewRat(-1, 1000000000000),
+		big.NewRat(12345678, 100),
+		big.NewRat(-12345678, 100),
+		largest,
+		smallest,
+	}
+	for _, v := range sample {
+		rows := newTestRows([]int8{wire.DecimalColumn}, []string{"D"}, decimalBytes(t, v))
+		if !rows.AdvanceRow() {
+			t.Fatal("expected a row")
+		}
+		d, err := rows.GetDecimal(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.(*big.Rat).Cmp(v) != 0 {
+			t.Errorf("expected %v got %v", v, d)
+		}
+	}
+}
+
+func TestVoltRows_GetDecimalWithScale(t *testing.T) {
+	sample := []struct {
+		v     *big.Rat
+		scale int
+		exp   string
+	}{
+		{big.NewRat(12345678, 100), 12, "123456.780000000000"},
+		{big.NewRat(1, 1000000000000), 12, "0.000000000001"},
+		{big.NewRat(12345678, 100), 0, "123457"},
+		{big.NewRat(25, 10), 0, "2"},
+		{big.NewRat(-35, 10), 0, "-4"},
+		{big.NewRat(1005, 1000), 2, "1.00"},
+		{big.NewRat(1015, 1000), 2, "1.02"},
+		{big.NewRat(1, 3), 4, "0.3333"},
+	}
+	for _, s := range sample {
+		rows := newTestRows([]int8{wire.DecimalColumn}, []string{"D"}, decimalBytes(t, roundRat(s.v)))
+		if !rows.AdvanceRow() {
+			t.Fatal("expected a row")
+		}
+		d, err := rows.GetDecimalWithScaleByName("d", s.scale)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := d.(wire.VoltDecimal)
+		if v.Scale != s.scale || v.String() != s.exp {
+			t.Errorf("%v at scale %d: expected %s got %s", s.v, s.scale, s.exp, v)
+		}
+	}
+	rows := newTestRows([]int8{wire.DecimalColumn}, []string{"D"}, nullDecimal[:])
+	rows.AdvanceRow()
+	if d, err := rows.GetDecimalWithScale(0, 2); d != nil || err != nil {
+		t.Errorf("expected nil got %v %v", d, err)
+	}
+}
+
+// roundRat returns v rounded to the 12 fractional digits of a DECIMAL.
+func roundRat(v *big.Rat) *big.Rat {
+	f := new(big.Int).Exp(big.NewInt(10), big.NewInt(12), nil)
+	n := new(big.Int).Mul(v.Num(), f)
+	n.Quo(n, v.Denom())
+	return new(big.Rat).SetFrac(n, f)
+}
+
+func TestVoltRows_GetDecimalNull(t *testing.T) {
+	rows := newTestRows([]int8{wire.DecimalColumn}, []string{"D"}, nullDecimal[:])
+	rows.AdvanceRow()
+	d, err := rows.GetDecimal(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != nil {
+		t.Errorf("expected nil got %v", d)
+	}
+}
+
+func TestVoltRows_GetDecimalWrongType(t *testing.T) {
+	rows := newTestRows([]int8{wire.LongColumn}, []string{"L"}, make([]byte, 8))
+	rows.AdvanceRow()
+	_, err := rows.GetDecimal(0)
+	e, ok := err.(ColumnTypeError)
+	if !ok {
+		t.Fatalf("expected ColumnTypeError got %v", err)
+	}
+	if e.Expected != wire.DecimalColumn || e.Actual != wire.LongColumn {
+		t.Errorf("unexpected error %v", e)
+	}
+}
+
+func TestVoltRows_GetGeographyPoint(t *testing.T) {
+	p := wire.GeographyPoint{Longitude: -71.0589, Latitude: 42.3601}
+	e := wire.NewEncoder()
+	e.GeographyPoint(p)
+	e.Float64(360)
+	e.Float64(360)
+	b := e.Bytes()
+	rows := newTestRows([]int8{wire.GeographyPointColumn}, []string{"P"}, b[:16], b[16:])
+	rows.AdvanceRow()
+	v, err := rows.GetGeographyPoint(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != p {
+		t.Errorf("expected %v got %v", p, v)
+	}
+	rows.AdvanceRow()
+	v, err = rows.GetGeographyPointByName("p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != nil {
+		t.Errorf("expected nil got %v", v)
+	}
+}
+
+func TestVoltRows_GetGeography(t *testing.T) {
+	p := wire.GeographyPolygon{
+		OuterRing: []wire.GeographyPoint{
+			{Longitude: -10, Latitude: -10}, {Longitude: 10, Latitude: -10},
+			{Longitude: 10, Latitude: 10}, {Longitude: -10, Latitude: 10},
+			{Longitude: -10, Latitude: -10},
+		},
+		InnerRings: [][]wire.GeographyPoint{{
+			{Longitude: -5, Latitude: -5}, {Longitude: -5, Latitude: 5},
+			{Longitude: 5, Latitude: 5}, {Longitude: 5, Latitude: -5},
+			{Longitude: -5, Latitude: -5},
+		}},
+	}
+	e := wire.NewEncoder()
+	if _, err := e.Geography(p); err != nil {
+		t.Fatal(err)
+	}
+	poly := e.Bytes()
+	null := []byte{0xff, 0xff, 0xff, 0xff}
+	types := []int8{wire.GeographyColumn, wire.IntColumn}
+	rows := newTestRows(types, []string{"G", "I"}, append(poly, 0, 0, 0, 1), append(null, 0, 0, 0, 2))
+
+	rows.AdvanceRow()
+	v, err := rows.GetGeography(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := v.(wire.GeographyPolygon)
+	if len(g.InnerRings) != 1 {
+		t.Fatalf("expected 1 inner ring got %v", g.InnerRings)
+	}
+	rings := [][][]wire.GeographyPoint{{p.OuterRing, g.OuterRing}, {p.InnerRings[0], g.InnerRings[0]}}
+	for _, r := range rings {
+		if len(r[0]) != len(r[1]) {
+			t.Fatalf("expected %v got %v", r[0], r[1])
+		}
+		for i := range r[0] {
+			if math.Abs(r[0][i].Longitude-r[1][i].Longitude) > 1e-9 ||
+				math.Abs(r[0][i].Latitude-r[1][i].Latitude) > 1e-9 {
+				t.Errorf("expected %v got %v", r[0], r[1])
+				break
+			}
+		}
+	}
+	// the column following the polygon is found at the right offset
+	i, err := rows.GetInteger(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != int32(1) {
+		t.Errorf("expected 1 got %v", i)
+	}
+
+	rows.AdvanceRow()
+	v, err = rows.GetGeography(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != nil {
+		t.Errorf("expected nil got %v", v)
+	}
+	i, err = rows.GetInteger(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != int32(2) {
+		t.Errorf("expected 2 got %v", i)
+	}
+}
+
+func TestVoltRows_Reset(t *testing.T) {
+	var rs [][]byte
+	for i := int32(0); i < 3; i++ {
+		rs = append(rs, columnBytes(t, i))
+	}
+	rows := newTestRows([]int8{wire.IntColumn}, []string{"I"}, rs...)
+	for pass := 0; pass < 2; pass++ {
+		var got []int32
+		for rows.AdvanceRow() {
+			v, err := rows.GetInteger(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, v.(int32))
+		}
+		if len(got) != 3 || got[0] != 0 || got[1] != 1 || got[2] != 2 {
+			t.Errorf("pass %d: expected [0 1 2] got %v", pass, got)
+		}
+		rows.Reset()
+	}
+}
+
+func TestVoltRows_ScanRow(t *testing.T) {
+	ts := time.Date(2017, 3, 14, 15, 9, 26, 0, time.UTC)
+	types := []int8{wire.IntColumn, wire.StringColumn, wire.TimestampColumn, wire.StringColumn, wire.LongColumn}
+	names := []string{"I", "S", "T", "N", "L"}
+	var row []byte
+	row = append(row, columnBytes(t, int32(7))...)
+	row = append(row, columnBytes(t, "volt")...)
+	row = append(row, columnBytes(t, ts)...)
+	row = append(row, nullColumnBytes(t, wire.StringColumn)...)
+	row = append(row, nullColumnBytes(t, wire.LongColumn)...)
+	rows := newTestRows(types, names, row)
+
+	var (
+		i  int32
+		s  string
+		tm time.Time
+		n  sql.NullString
+		l  interface{}
+	)
+	if err := rows.ScanRow(&i, &s, &tm, &n, &l); err == nil {
+		t.Error("expected an error scanning before AdvanceRow")
+	}
+	rows.AdvanceRow()
+	if err := rows.ScanRow(&i, &s, &tm, &n, &l); err != nil {
+		t.Fatal(err)
+	}
+	if i != 7 || s != "volt" || !tm.Equal(ts) || n.Valid || l != nil {
+		t.Errorf("unexpected values %v %v %v %v %v", i, s, tm, n, l)
+	}
+
+	if err := rows.ScanRow(&i, &s, &tm, &n); err == nil {
+		t.Error("expected an error scanning into too few values")
+	}
+	var ns string
+	if err := rows.ScanRow(&i, &s, &tm, &ns, &l); err == nil {
+		t.Error("expected an error scanning a null value into a *string")
+	}
+	var wrong int64
+	if err := rows.ScanRow(&wrong, &s, &tm, &n, &l); err == nil {
+		t.Error("expected an error scanning an INTEGER into a *int64")
+	}
+	var nl sql.NullInt64
+	if err := rows.ScanRow(&i, &s, &tm, &n, &nl); err != nil {
+		t.Fatal(err)
+	}
+	if nl.Valid {
+		t.Errorf("expected an invalid sql.NullInt64 got %v", nl)
+	}
+}
+
+func TestVoltRows_IsNull(t *testing.T) {
+	sample := []struct {
+		ct int8
+		v  interface{}
+	}{
+		{wire.TinyIntColumn, int8(math.MinInt8 + 1)},
+		{wire.ShortColumn, int16(math.MinInt16 + 1)},
+		{wire.IntColumn, int32(math.MinInt32 + 1)},
+		{wire.LongColumn, int64(math.MinInt64 + 1)},
+		{wire.FloatColumn, -math.MaxFloat64},
+		{wire.StringColumn, ""},
+		{wire.VarBinColumn, []byte{}},
+		{wire.TimestampColumn, time.Unix(0, 1000)},
+		{wire.DecimalColumn, big.NewRat(0, 1)},
+		{wire.GeographyPointColumn, wire.GeographyPoint{Longitude: 180, Latitude: 90}},
+	}
+	for _, s := range sample {
+		rows := newTestRows([]int8{s.ct}, []string{"C"}, columnBytes(t, s.v), nullColumnBytes(t, s.ct))
+		rows.AdvanceRow()
+		null, err := rows.IsNull(0)
+		if err != nil {
+			t.Fatalf("column type %d: %v", s.ct, err)
+		}
+		if null {
+			t.Errorf("column type %d: expected %v not to be null", s.ct, s.v)
+		}
+		rows.AdvanceRow()
+		null, err = rows.IsNullByName("c")
+		if err != nil {
+			t.Fatalf("column type %d: %v", s.ct, err)
+		}
+		if !null {
+			t.Errorf("column type %d: expected null", s.ct)
+		}
+	}
+}
+
+func TestColumnType_String(t *testing.T) {
+	names := map[int8]string{
+		wire.TinyIntColumn:        "TINYINT",
+		wire.ShortColumn:          "SMALLINT",
+		wire.IntColumn:            "INTEGER",
+		wire.LongColumn:           "BIGINT",
+		wire.FloatColumn:          "FLOAT",
+		wire.DecimalColumn:        "DECIMAL",
+		wire.StringColumn:         "VARCHAR",
+		wire.VarBinColumn:         "VARBINARY",
+		wire.TimestampColumn:      "TIMESTAMP",
+		wire.GeographyColumn:      "GEOGRAPHY",
+		wire.GeographyPointColumn: "GEOGRAPHY_POINT",
+		wire.NullColumn:           "UNKNOWN",
+	}
+	for code, name := range names {
+		if s := ColumnType(code).String(); s != name {
+			t.Errorf("%d: expected %s got %s", code, name, s)
+		}
+	}
+	if BoolColumn != TinyIntColumn {
+		t.Errorf("expected booleans to be stored as %v got %v", TinyIntColumn, BoolColumn)
+	}
+}
+
+func TestVoltRows_ColumnMetadata(t *testing.T) {
+	types := []int8{wire.IntColumn, wire.StringColumn, wire.LongColumn}
+	rows := newTestRows(types, []string{"ID", "Name", "ID"})
+	if n := rows.ColumnCount(); n != 3 {
+		t.Errorf("expected 3 columns got %d", n)
+	}
+	if cn, err := rows.ColumnName(1); err != nil || cn != "Name" {
+		t.Errorf("expected Name got %v %v", cn, err)
+	}
+	if ct, err := rows.ColumnType(2); err != nil || ct != BigIntColumn {
+		t.Errorf("expected %v got %v %v", BigIntColumn, ct, err)
+	}
+	if _, err := rows.ColumnName(3); err == nil {
+		t.Error("expected an error for a column index out of range")
+	}
+	if _, err := rows.ColumnType(-1); err == nil {
+		t.Error("expected an error for a column index out of range")
+	}
+	if cts := rows.ColumnTypes(); len(cts) != 3 || cts[0] != wire.IntColumn || cts[1] != wire.StringColumn {
+		t.Errorf("unexpected column types %v", cts)
+	}
+	if cts := rows.ColumnTypesTyped(); len(cts) != 3 || cts[0] != IntegerColumn || cts[1] != VarcharColumn {
+		t.Errorf("unexpected column types %v", cts)
+	}
+	if ci, err := rows.ColumnIndex("name"); err != nil || ci != 1 {
+		t.Errorf("expected 1 got %v %v", ci, err)
+	}
+	// the first of the duplicated columns is found.
+	if ci, err := rows.ColumnIndex("id"); err != nil || ci != 0 {
+		t.Errorf("expected 0 got %v %v", ci, err)
+	}
+	if _, err := rows.ColumnIndex("missing"); err == nil {
+		t.Error("expected an error for a missing column name")
+	}
+}
+
+func TestDecodeTableForRows_ShortReads(t *testing.T) {
+	var rows [][]interface{}
+	for i := 0; i < 100; i++ {
+		rows = append(rows, []interface{}{int32(i), strings.Repeat("x", i)})
+	}
+	b := stubTable([]int8{wire.IntColumn, wire.StringColumn}, []string{"I", "S"}, rows...)
+	vt, err := decodeTableForRows(wire.NewDecoder(iotest.OneByteReader(bytes.NewReader(b))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vr := *newVoltRows(voltResponseInfo{status: Success, numTables: 1}, []*voltTable{vt})
+	for i := 0; vr.AdvanceRow(); i++ {
+		var n int32
+		var s string
+		if err := vr.ScanRow(&n, &s); err != nil {
+			t.Fatal(err)
+		}
+		if n != int32(i) || s != strings.Repeat("x", i) {
+			t.Fatalf("row %d: unexpected values %v %v", i, n, s)
+		}
+	}
+
+	// a table cut short fails to decode.
+	if _, err := decodeTableForRows(wire.NewDecoder(bytes.NewReader(b[:len(b)-10]))); err == nil {
+		t.Error("expected an error decoding a truncated table")
+	}
+	// a corrupt row length fails to decode.
+	if _, err := decodeRow(wire.NewDecoder(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xfe}))); err == nil {
+		t.Error("expected an error decoding a negative row length")
+	}
+}
+
+func TestVoltRows_DecodeRow(t *testing.T) {
+	types := []int8{wire.IntColumn, wire.StringColumn, wire.StringColumn, wire.LongColumn}
+	names := []string{"ID", "USER_NAME", "NOTES", "EXTRA"}
+	var row []byte
+	row = append(row, columnBytes(t, int32(7))...)
+	row = append(row, columnBytes(t, "volt")...)
+	row = append(row, nullColumnBytes(t, wire.StringColumn)...)
+	row = append(row, columnBytes(t, int64(9))...)
+	rows := newTestRows(types, names, row)
+
+	type user struct {
+		ID      int32
+		Name    string `volt:"user_name"`
+		Notes   sql.NullString
+		Skipped string `volt:"-"`
+		Score   int64  `volt:"score,omitempty"`
+		hidden  string
+	}
+	var u user
+	if err := rows.DecodeRow(&u); err == nil {
+		t.Error("expected an error decoding before AdvanceRow")
+	}
+	rows.AdvanceRow()
+	u = user{Skipped: "kept", Score: 3}
+	if err := rows.DecodeRow(&u); err != nil {
+		t.Fatal(err)
+	}
+	exp := user{ID: 7, Name: "volt", Skipped: "kept", Score: 3}
+	if u != exp {
+		t.Errorf("expected %+v got %+v", exp, u)
+	}
+
+	var missing struct {
+		ID    int32
+		Score int64 `volt:"score"`
+	}
+	if err := rows.DecodeRow(&missing); err == nil {
+		t.Error("expected an error for a field without a column")
+	}
+	if err := rows.DecodeRow(u); err == nil {
+		t.Error("expected an error decoding into a struct that isn't a pointer")
+	}
+}
+
+func TestVoltRows_TimestampResolution(t *testing.T) {
+	// 2017-03-01 12:00:00.000001 UTC in microseconds since the epoch.
+	var micros int64 = 1488369600000001
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(micros))
+	rows := newTestRows([]int8{wire.TimestampColumn}, []string{"T"}, b)
+	rows.AdvanceRow()
+	v, err := rows.GetTimestamp(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := time.Date(2017, 3, 1, 12, 0, 0, 1000, time.UTC)
+	if !v.(time.Time).Equal(exp) {
+		t.Errorf("expected %v got %v", exp, v)
+	}
+	// sending it back gives the same microseconds.
+	if got := columnBytes(t, v); !bytes.Equal(got, b) {
+		t.Errorf("expected %v got %v", b, got)
+	}
+}
+
+func TestVoltRows_GetTimestampEpoch(t *testing.T) {
+	sample := []struct {
+		micros int64
+		exp    time.Time
+	}{
+		{0, time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{-1, time.Date(1969, 12, 31, 23, 59, 59, 999999000, time.UTC)},
+		{-14182940000000, time.Date(1969, 7, 20, 20, 17, 40, 0, time.UTC)},
+		// too far from the epoch for its nanoseconds to fit an int64.
+		{-11676096000000000, time.Date(1600, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, s := range sample {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, uint64(s.micros))
+		rows := newTestRows([]int8{wire.TimestampColumn}, []string{"T"}, b)
+		rows.AdvanceRow()
+		v, err := rows.GetTimestamp(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ts, ok := v.(time.Time)
+		if !ok {
+			t.Fatalf("%d: expected a time.Time got %v", s.micros, v)
+		}
+		if !ts.Equal(s.exp) || ts.Location() != time.UTC {
+			t.Errorf("%d: expected %v got %v", s.micros, s.exp, ts)
+		}
+		// sending it back gives the same microseconds.
+		if got := columnBytes(t, ts); !bytes.Equal(got, b) {
+			t.Errorf("%d: expected %v got %v", s.micros, b, got)
+		}
+	}
+
+	rows := newTestRows([]int8{wire.TimestampColumn}, []string{"T"}, nullColumnBytes(t, wire.TimestampColumn))
+	rows.AdvanceRow()
+	if v, err := rows.GetTimestamp(0); err != nil || v != nil {
+		t.Errorf("expected a null timestamp got %v %v", v, err)
+	}
+	if null, err := rows.IsNull(0); err != nil || !null {
+		t.Errorf("expected IsNull to report the null timestamp got %v %v", null, err)
+	}
+}
+
+func TestVoltRows_MarshalJSON(t *testing.T) {
+	ts := time.Date(2017, 3, 14, 15, 9, 26, 535000, time.UTC)
+	types := []int8{wire.IntColumn, wire.StringColumn, wire.FloatColumn, wire.DecimalColumn, wire.TimestampColumn, wire.VarBinColumn}
+	names := []string{"ID", "NAME", "SCORE", "PRICE", "AT", "DATA"}
+	var first, second []byte
+	first = append(first, columnBytes(t, int32(1))...)
+	first = append(first, columnBytes(t, `say "hi"`)...)
+	first = append(first, columnBytes(t, 1.5)...)
+	first = append(first, decimalBytes(t, big.NewRat(-5, 4))...)
+	first = append(first, columnBytes(t, ts)...)
+	first = append(first, columnBytes(t, []byte{1, 2, 3})...)
+	second = append(second, columnBytes(t, int32(2))...)
+	for _, ct := range types[1:] {
+		second = append(second, nullColumnBytes(t, ct)...)
+	}
+	rows := newTestRows(types, names, first, second)
+	rows.AdvanceRow()
+
+	b, err := json.Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `[{"ID":1,"NAME":"say \"hi\"","SCORE":1.5,"PRICE":-1.25,"AT":"2017-03-14T15:09:26.000535Z","DATA":"AQID"},` +
+		`{"ID":2,"NAME":null,"SCORE":null,"PRICE":null,"AT":null,"DATA":null}]`
+	if string(b) != exp {
+		t.Errorf("expected\n%s\ngot\n%s", exp, b)
+	}
+	// the cursor stays on the first row.
+	if v, err := rows.GetInteger(0); err != nil || v.(int32) != 1 {
+		t.Errorf("expected the cursor on the first row got %v %v", v, err)
+	}
+
+	b, err = json.Marshal(rows.Tables())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "["+exp+"]" {
+		t.Errorf("expected\n[%s]\ngot\n%s", exp, b)
+	}
+
+	b, err = json.Marshal(&Response{Rows: rows})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := `{"status":"SUCCESS","tables":[` + exp + `]}`; string(b) != s {
+		t.Errorf("expected\n%s\ngot\n%s", s, b)
+	}
+	verr := VoltError{voltResponse: voltResponseInfo{status: GracefulFailure}, error: errors.New("constraint violated")}
+	b, err = json.Marshal(&Response{Err: verr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := `{"status":"GRACEFUL FAILURE","error":"constraint violated"}`; string(b) != s {
+		t.Errorf("expected\n%s\ngot\n%s", s, b)
+	}
+}
+
+func TestVoltRows_WriteCSV(t *testing.T) {
+	ts := time.Date(2017, 3, 14, 15, 9, 26, 0, time.UTC)
+	types := []int8{wire.IntColumn, wire.StringColumn, wire.DecimalColumn, wire.TimestampColumn, wire.VarBinColumn}
+	names := []string{"ID", "NOTE", "PRICE", "AT", "DATA"}
+	var first, second []byte
+	first = append(first, columnBytes(t, int32(1))...)
+	first = append(first, columnBytes(t, "a, \"quoted\"\nnote")...)
+	first = append(first, decimalBytes(t, big.NewRat(5, 2))...)
+	first = append(first, columnBytes(t, ts)...)
+	first = append(first, columnBytes(t, []byte{1, 2, 3})...)
+	second = append(second, columnBytes(t, int32(2))...)
+	for _, ct := range types[1:] {
+		second = append(second, nullColumnBytes(t, ct)...)
+	}
+	rows := newTestRows(types, names, first, second)
+
+	var buf bytes.Buffer
+	if err := rows.WriteCSV(&buf, "2006-01-02 15:04:05"); err != nil {
+		t.Fatal(err)
+	}
+	exp := "ID,NOTE,PRICE,AT,DATA\n" +
+		"1,\"a, \"\"quoted\"\"\nnote\",2.5,2017-03-14 15:09:26,AQID\n" +
+		"2,,,,\n"
+	if buf.String() != exp {
+		t.Errorf("expected\n%q\ngot\n%q", exp, buf.String())
+	}
+	// the cursor is still before the first row.
+	if !rows.AdvanceRow() {
+		t.Fatal("expected a row")
+	}
+	if v, err := rows.GetInteger(0); err != nil || v.(int32) != 1 {
+		t.Errorf("expected the first row got %v %v", v, err)
+	}
+}
@@ -0,0 +1,190 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2017 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds of the buckets of the latency
+// histograms, a last bucket counts the latencies above the largest bound.
+var LatencyBuckets = []time.Duration{
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+}
+
+// ProcedureStats holds the statistics of the invocations of a procedure.
+type ProcedureStats struct {
+	// Invocations is the number of invocations that got a response, timed
+	// out or lost their connection, Errors the number of those that failed.
+	Invocations int64
+	Errors      int64
+
+	// The latencies are measured from sending the request to receiving the
+	// response, invocations that got no response are not included.
+	TotalLatency time.Duration
+	MinLatency   time.Duration
+	MaxLatency   time.Duration
+
+	// Histogram counts the latencies per bucket of LatencyBuckets, it has one
+	// more entry for the latencies above the largest bucket.
+	Histogram []int64
+}
+
+// MeanLatency returns the average latency of the invocations that didn't time
+// out.
+func (ps ProcedureStats) MeanLatency() time.Duration {
+	var n int64
+	for _, c := range ps.Histogram {
+		n += c
+	}
+	if n == 0 {
+		return 0
+	}
+	return ps.TotalLatency / time.Duration(n)
+}
+
+func (ps *ProcedureStats) add(latency time.Duration, failed bool) {
+	ps.Invocations++
+	if failed {
+		ps.Errors++
+	}
+	if latency < 0 {
+		return
+	}
+	if ps.Histogram == nil {
+		ps.Histogram = make([]int64, len(LatencyBuckets)+1)
+	}
+	if ps.MinLatency == 0 || latency < ps.MinLatency {
+		ps.MinLatency = latency
+	}
+	if latency > ps.MaxLatency {
+		ps.MaxLatency = latency
+	}
+	ps.TotalLatency += latency
+	i := 0
+	for i < len(LatencyBuckets) && latency > LatencyBuckets[i] {
+		i++
+	}
+	ps.Histogram[i]++
+}
+
+// clientStats aggregates the statistics of the procedures invoked on a
+// connection, it is shared by its node connections.
+type clientStats struct {
+	mu    sync.Mutex
+	procs map[string]*ProcedureStats
+}
+
+func newClientStats() *clientStats {
+	return &clientStats{procs: make(map[string]*ProcedureStats)}
+}
+
+// record adds an invocation of proc, a negative latency records an invocation
+// that got no response.
+func (cs *clientStats) record(proc string, latency time.Duration, failed bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	ps, ok := cs.procs[proc]
+	if !ok {
+		ps = &ProcedureStats{}
+		cs.procs[proc] = ps
+	}
+	ps.add(latency, failed)
+}
+
+func (cs *clientStats) snapshot() map[string]ProcedureStats {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	stats := make(map[string]ProcedureStats, len(cs.procs))
+	for proc, ps := range cs.procs {
+		s := *ps
+		s.Histogram = append([]int64(nil), ps.Histogram...)
+		stats[proc] = s
+	}
+	return stats
+}
+
+// Stats returns the statistics of the procedures invoked on the connection,
+// keyed by procedure name. System procedures the client invokes on its own
+// are included.
+func (c *Conn) Stats() map[string]ProcedureStats {
+	return c.stats.snapshot()
+}
+
+// ConnStats holds the traffic of the connection to a server since it was
+// opened, reconnecting doesn't reset it.
+type ConnStats struct {
+	// Address is the host:port of the server as given in the connection
+	// string, without the credentials it may hold.
+	Address string
+
+	// BytesSent and BytesReceived count the bytes of the messages written to
+	// and read from the server, including their length prefix but not the
+	// login.
+	BytesSent     int64
+	BytesReceived int64
+
+	// CallsSent is the number of invocations written to the server and
+	// ResponsesReceived the number of responses read from it, both include
+	// the pings of idle connections and the system procedures the client
+	// invokes on its own.
+	CallsSent         int64
+	ResponsesReceived int64
+}
+
+// ConnStats returns the traffic of the connections to the servers, in the
+// order they appear in the connection string. The counters are updated
+// atomically as messages are written and read, they are cheap to read at any
+// time.
+func (c *Conn) ConnStats() []ConnStats {
+	c.ncsMu.Lock()
+	defer c.ncsMu.Unlock()
+	stats := make([]ConnStats, len(c.ncs))
+	for i, nc := range c.ncs {
+		stats[i] = ConnStats{
+			Address:           nc.host(),
+			BytesSent:         atomic.LoadInt64(&nc.bytesSent),
+			BytesReceived:     atomic.LoadInt64(&nc.bytesReceived),
+			CallsSent:         atomic.LoadInt64(&nc.callsSent),
+			ResponsesReceived: atomic.LoadInt64(&nc.responsesReceived),
+		}
+	}
+	return stats
+}
+
+// roundTripTime converts the cluster round trip time of rsp from milliseconds.
+func roundTripTime(rsp voltResponse) time.Duration {
+	rtt := rsp.getClusterRoundTripTime()
+	if rtt < 0 {
+		return -1
+	}
+	return time.Duration(rtt) * time.Millisecond
+}
@@ -0,0 +1,167 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2017 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VoltDB/voltdb-client-go/wire"
+)
+
+var errStructParam = errors.New("voltdbclient: CallStruct needs a struct holding the parameters")
+
+// valueStructs are the struct types that are sent as a single parameter, they
+// can't hold the parameters of a call.
+var valueStructs = map[reflect.Type]bool{
+	reflect.TypeOf(time.Time{}):             true,
+	reflect.TypeOf(big.Rat{}):               true,
+	reflect.TypeOf(wire.VoltDecimal{}):      true,
+	reflect.TypeOf(wire.NullValue{}):        true,
+	reflect.TypeOf(wire.GeographyPoint{}):   true,
+	reflect.TypeOf(wire.GeographyPolygon{}): true,
+	reflect.TypeOf(wire.FixedVarbinary{}):   true,
+	reflect.TypeOf(sql.NullBool{}):          true,
+	reflect.TypeOf(sql.NullByte{}):          true,
+	reflect.TypeOf(sql.NullInt16{}):         true,
+	reflect.TypeOf(sql.NullInt32{}):         true,
+	reflect.TypeOf(sql.NullInt64{}):         true,
+	reflect.TypeOf(sql.NullFloat64{}):       true,
+	reflect.TypeOf(sql.NullString{}):        true,
+	reflect.TypeOf(sql.NullTime{}):          true,
+}
+
+// CallStruct invokes the stored procedure proc with the exported fields of the
+// struct v as its parameters, it is analogous to CallContext. v may also be a
+// pointer to a struct.
+//
+// The fields are sent in declaration order, unexported fields are skipped. The
+// volt struct tag changes this, a field tagged `volt:"-"` is skipped and a
+// field tagged with a pos option, such as `volt:",pos=0"`, is sent at that
+// position. The other fields fill the remaining positions in declaration order.
+func (c *Conn) CallStruct(ctx context.Context, proc string, v interface{}) (driver.Rows, error) {
+	args, err := structParams(v)
+	if err != nil {
+		return nil, err
+	}
+	return c.CallContext(ctx, proc, args...)
+}
+
+// structParams returns the parameters held by the struct v in the order they
+// are sent.
+func structParams(v interface{}) ([]driver.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct || valueStructs[rv.Type()] {
+		return nil, errStructParam
+	}
+	t := rv.Type()
+	type param struct {
+		value driver.Value
+		pos   int
+	}
+	var params []param
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag, err := parseVoltTag(f)
+		if err != nil {
+			return nil, err
+		}
+		if tag.skip {
+			continue
+		}
+		params = append(params, param{rv.Field(i).Interface(), tag.pos})
+	}
+	args := make([]driver.Value, len(params))
+	placed := make([]bool, len(params))
+	for _, p := range params {
+		if p.pos < 0 {
+			continue
+		}
+		if p.pos >= len(args) || placed[p.pos] {
+			return nil, fmt.Errorf("voltdbclient: parameter position %d of %s is out of range or used twice", p.pos, t)
+		}
+		args[p.pos] = p.value
+		placed[p.pos] = true
+	}
+	next := 0
+	for _, p := range params {
+		if p.pos >= 0 {
+			continue
+		}
+		for placed[next] {
+			next++
+		}
+		args[next] = p.value
+		placed[next] = true
+	}
+	return args, nil
+}
+
+// voltTag is a parsed volt struct tag. name is the column the field is
+// decoded from, pos is -1 when the field has no position.
+type voltTag struct {
+	name      string
+	skip      bool
+	pos       int
+	omitempty bool
+}
+
+// parseVoltTag parses the volt tag of f, it has the form "name,option...".
+// The options are pos=N and omitempty.
+func parseVoltTag(f reflect.StructField) (voltTag, error) {
+	tag := voltTag{pos: -1}
+	s, ok := f.Tag.Lookup("volt")
+	if !ok {
+		return tag, nil
+	}
+	if s == "-" {
+		tag.skip = true
+		return tag, nil
+	}
+	parts := strings.Split(s, ",")
+	tag.name = parts[0]
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			tag.omitempty = true
+			continue
+		}
+		if !strings.HasPrefix(opt, "pos=") {
+			return tag, fmt.Errorf("voltdbclient: unknown option %q in the volt tag of field %s", opt, f.Name)
+		}
+		pos, err := strconv.Atoi(strings.TrimPrefix(opt, "pos="))
+		if err != nil || pos < 0 {
+			return tag, fmt.Errorf("voltdbclient: invalid position %q in the volt tag of field %s", opt, f.Name)
+		}
+		tag.pos = pos
+	}
+	return tag, nil
+}
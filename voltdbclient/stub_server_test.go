@@ -0,0 +1,309 @@
+package voltdbclient
+
+import (
+	"bytes"
+	"crypto/tls"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VoltDB/voltdb-client-go/wire"
+)
+
+// stubServer is a minimal VoltDB server used to test the client without a
+// running database. It accepts any login and passes every procedure invocation
+// to handler, the message returned by handler is sent back to the client. When
+// handler returns nil nothing is sent.
+//
+// The system procedures the client invokes on its own when it connects are
+// passed to system instead. By default they are never answered, so client
+// affinity stays disabled.
+type stubServer struct {
+	t       *testing.T
+	ln      net.Listener
+	handler func(inv stubInvocation) []byte
+	system  func(inv stubInvocation) []byte
+	hostID  int32
+
+	mu    sync.Mutex
+	conns []net.Conn
+	// closed counts the connections the client closed.
+	closed int
+}
+
+// stubInvocation is a procedure invocation received by the stubServer.
+type stubInvocation struct {
+	proc   string
+	handle int64
+	// queryTimeout is the timeout sent with the invocation, or 0.
+	queryTimeout time.Duration
+	// params holds the encoded parameters of the invocation.
+	params []byte
+}
+
+func newStubServer(t *testing.T, handler func(inv stubInvocation) []byte) *stubServer {
+	return newStubServerAt(t, "127.0.0.1:0", handler)
+}
+
+// newStubServerAt returns a stubServer listening on addr, it is used to restart
+// a server on the address of one that was closed.
+func newStubServerAt(t *testing.T, addr string, handler func(inv stubInvocation) []byte) *stubServer {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return startStubServer(t, ln, &stubServer{handler: handler})
+}
+
+// newClusterStubServer returns a stubServer acting as the host hostID of a
+// cluster, the system procedures are answered by system.
+func newClusterStubServer(t *testing.T, hostID int32, system, handler func(inv stubInvocation) []byte) *stubServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return startStubServer(t, ln, &stubServer{handler: handler, system: system, hostID: hostID})
+}
+
+// newTLSStubServer returns a stubServer that accepts TLS connections only.
+func newTLSStubServer(t *testing.T, cert tls.Certificate, handler func(inv stubInvocation) []byte) *stubServer {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return startStubServer(t, ln, &stubServer{handler: handler})
+}
+
+func startStubServer(t *testing.T, ln net.Listener, s *stubServer) *stubServer {
+	s.t = t
+	s.ln = ln
+	go s.accept()
+	return s
+}
+
+func (s *stubServer) addr() string {
+	return s.ln.Addr().String()
+}
+
+// url returns the connection string for the server.
+func (s *stubServer) url() string {
+	return "voltdb://" + s.addr()
+}
+
+// connCount returns the number of connections the server accepted.
+func (s *stubServer) connCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// closedCount returns the number of connections the client closed.
+func (s *stubServer) closedCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// close stops the server and closes all the client connections.
+func (s *stubServer) close() {
+	s.ln.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+// dropConns closes the client connections, unlike close the server keeps
+// accepting new ones.
+func (s *stubServer) dropConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+func (s *stubServer) accept() {
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, c)
+		s.mu.Unlock()
+		go s.serve(c)
+	}
+}
+
+func (s *stubServer) serve(c net.Conn) {
+	d := wire.NewDecoder(c)
+	defer func() {
+		s.mu.Lock()
+		s.closed++
+		s.mu.Unlock()
+	}()
+	if _, err := d.Message(); err != nil {
+		return
+	}
+	if _, err := c.Write(stubLoginResponse(s.hostID)); err != nil {
+		return
+	}
+	for {
+		msg, err := d.Message()
+		if err != nil {
+			return
+		}
+		inv, err := decodeStubInvocation(msg)
+		if err != nil {
+			s.t.Errorf("stub server: failed to decode invocation %v", err)
+			return
+		}
+		handler := s.handler
+		if strings.HasPrefix(inv.proc, "@") && inv.handle < 0 {
+			if s.system == nil {
+				continue
+			}
+			handler = s.system
+		}
+		if rsp := handler(inv); rsp != nil {
+			if _, err := c.Write(rsp); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func decodeStubInvocation(msg []byte) (stubInvocation, error) {
+	r := bytes.NewReader(msg)
+	d := wire.NewDecoder(r)
+	var inv stubInvocation
+	timeoutType, err := d.Byte()
+	if err != nil {
+		return inv, err
+	}
+	if timeoutType == batchTimeoutOverride {
+		ms, err := d.Int32()
+		if err != nil {
+			return inv, err
+		}
+		inv.queryTimeout = time.Duration(ms) * time.Millisecond
+	}
+	if inv.proc, err = d.String(); err != nil {
+		return inv, err
+	}
+	if inv.handle, err = d.Int64(); err != nil {
+		return inv, err
+	}
+	inv.params = msg[len(msg)-r.Len():]
+	return inv, nil
+}
+
+func stubLoginResponse(hostID int32) []byte {
+	e := wire.NewEncoder()
+	e.Byte(0) // version
+	e.Byte(0) // auth code
+	e.Int32(hostID)
+	e.Int64(1)
+	e.Time(time.Now())
+	e.Int32(0x7f000001)
+	e.String("stub")
+	return stubMessage(e.Bytes())
+}
+
+// stubResponse returns a successful response holding the given tables.
+func stubResponse(handle int64, tables ...[]byte) []byte {
+	e := wire.NewEncoder()
+	e.Byte(0) // version
+	e.Int64(handle)
+	e.Byte(0) // fields present
+	e.Byte(int8(Success))
+	e.Byte(math.MinInt8) // app status
+	e.Int32(0)           // cluster round trip time
+	e.Int16(int16(len(tables)))
+	for _, t := range tables {
+		e.Write(t)
+	}
+	return stubMessage(e.Bytes())
+}
+
+// stubErrorResponse returns a failed response with the given status.
+func stubErrorResponse(handle int64, status ResponseStatus, msg string) []byte {
+	return stubStatusResponse(handle, status, msg, math.MinInt8, "")
+}
+
+// stubStatusResponse returns a response without tables with the given status
+// and application status.
+func stubStatusResponse(handle int64, status ResponseStatus, msg string, appStatus ResponseStatus, appMsg string) []byte {
+	e := wire.NewEncoder()
+	e.Byte(0) // version
+	e.Int64(handle)
+	var fieldsPresent uint8 = 1 << 5 // status string present
+	if appMsg != "" {
+		fieldsPresent |= 1 << 7
+	}
+	e.Byte(int8(fieldsPresent))
+	e.Byte(int8(status))
+	e.String(msg)
+	e.Byte(int8(appStatus))
+	if appMsg != "" {
+		e.String(appMsg)
+	}
+	e.Int32(0) // cluster round trip time
+	e.Int16(0)
+	return stubMessage(e.Bytes())
+}
+
+// stubResult returns the table VoltDB sends in response to a statement that
+// modifies n rows.
+func stubResult(n int64) []byte {
+	return stubTable([]int8{wire.LongColumn}, []string{"modified_tuples"}, []interface{}{n})
+}
+
+// stubTable encodes a table with the given columns and rows, the row values are
+// encoded with wire.Encoder.Marshal.
+func stubTable(types []int8, names []string, rows ...[]interface{}) []byte {
+	meta := wire.NewEncoder()
+	meta.Byte(math.MinInt8) // status
+	meta.Int16(int16(len(types)))
+	for _, ct := range types {
+		meta.Byte(ct)
+	}
+	for _, cn := range names {
+		meta.String(cn)
+	}
+	body := wire.NewEncoder()
+	body.Int32(int32(len(rows)))
+	for _, row := range rows {
+		r := wire.NewEncoder()
+		for _, v := range row {
+			vb := wire.NewEncoder()
+			if _, err := vb.Marshal(v); err != nil {
+				panic(err)
+			}
+			// drop the type byte
+			r.Write(vb.Bytes()[1:])
+		}
+		body.Int32(int32(r.Len()))
+		body.Write(r.Bytes())
+	}
+	e := wire.NewEncoder()
+	e.Int32(int32(wire.IntegerSize + meta.Len() + body.Len()))
+	e.Int32(int32(meta.Len()))
+	e.Write(meta.Bytes())
+	e.Write(body.Bytes())
+	return e.Bytes()
+}
+
+func stubMessage(b []byte) []byte {
+	e := wire.NewEncoder()
+	e.Int32(int32(len(b)))
+	e.Write(b)
+	return e.Bytes()
+}
@@ -0,0 +1,220 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2017 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// TableStats is a row of @Statistics TABLE, it describes a table on one
+// partition. The memory sizes are in kilobytes.
+type TableStats struct {
+	Timestamp            time.Time
+	HostID               int64
+	HostName             string
+	SiteID               int64
+	PartitionID          int64
+	TableName            string
+	TableType            string
+	TupleCount           int64
+	TupleAllocatedMemory int64
+	TupleDataMemory      int64
+	StringDataMemory     int64
+	// TupleLimit is the row limit of the table, or 0 when it has none.
+	TupleLimit  int64
+	PercentFull int64
+}
+
+// TableStatistics invokes @Statistics TABLE and returns its rows.
+func (c *Conn) TableStatistics(ctx context.Context) ([]TableStats, error) {
+	rows, err := c.CallContext(ctx, "@Statistics", "TABLE", int32(0))
+	if err != nil {
+		return nil, err
+	}
+	return decodeTableStats(rows.(VoltRows))
+}
+
+func decodeTableStats(rows VoltRows) ([]TableStats, error) {
+	var stats []TableStats
+	for rows.AdvanceRow() {
+		r := sysRow{rows: rows}
+		ts := TableStats{
+			Timestamp:            millisToTime(r.int64("TIMESTAMP")),
+			HostID:               r.int64("HOST_ID"),
+			HostName:             r.string("HOSTNAME"),
+			SiteID:               r.int64("SITE_ID"),
+			PartitionID:          r.int64("PARTITION_ID"),
+			TableName:            r.string("TABLE_NAME"),
+			TableType:            r.string("TABLE_TYPE"),
+			TupleCount:           r.int64("TUPLE_COUNT"),
+			TupleAllocatedMemory: r.int64("TUPLE_ALLOCATED_MEMORY"),
+			TupleDataMemory:      r.int64("TUPLE_DATA_MEMORY"),
+			StringDataMemory:     r.int64("STRING_DATA_MEMORY"),
+			TupleLimit:           r.int64("TUPLE_LIMIT"),
+			PercentFull:          r.int64("PERCENT_FULL"),
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		stats = append(stats, ts)
+	}
+	return stats, nil
+}
+
+// HostInfo describes a host of the cluster as reported by
+// @SystemInformation OVERVIEW.
+type HostInfo struct {
+	HostID       int32
+	HostName     string
+	IPAddress    string
+	Version      string
+	BuildString  string
+	ClusterState string
+	StartTime    time.Time
+
+	// Properties holds all the keys reported for the host, including the
+	// ones above.
+	Properties map[string]string
+}
+
+// SystemInfo is the result of @SystemInformation OVERVIEW.
+type SystemInfo struct {
+	// Hosts holds the hosts of the cluster ordered by host id.
+	Hosts []HostInfo
+}
+
+// SystemInformation invokes @SystemInformation OVERVIEW and returns the
+// description of the hosts of the cluster.
+func (c *Conn) SystemInformation(ctx context.Context) (*SystemInfo, error) {
+	rows, err := c.CallContext(ctx, "@SystemInformation", "OVERVIEW")
+	if err != nil {
+		return nil, err
+	}
+	return decodeSystemInfo(rows.(VoltRows))
+}
+
+func decodeSystemInfo(rows VoltRows) (*SystemInfo, error) {
+	info := &SystemInfo{}
+	hosts := make(map[int32]int)
+	for rows.AdvanceRow() {
+		r := sysRow{rows: rows}
+		hostID := int32(r.int64("HOST_ID"))
+		key := r.string("KEY")
+		value := r.string("VALUE")
+		if r.err != nil {
+			return nil, r.err
+		}
+		i, ok := hosts[hostID]
+		if !ok {
+			i = len(info.Hosts)
+			hosts[hostID] = i
+			info.Hosts = append(info.Hosts, HostInfo{HostID: hostID, Properties: make(map[string]string)})
+		}
+		h := &info.Hosts[i]
+		h.Properties[key] = value
+		switch key {
+		case "HOSTNAME":
+			h.HostName = value
+		case "IPADDRESS":
+			h.IPAddress = value
+		case "VERSION":
+			h.Version = value
+		case "BUILDSTRING":
+			h.BuildString = value
+		case "CLUSTERSTATE":
+			h.ClusterState = value
+		case "STARTTIME":
+			if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+				h.StartTime = millisToTime(ms)
+			}
+		}
+	}
+	sort.Slice(info.Hosts, func(i, j int) bool { return info.Hosts[i].HostID < info.Hosts[j].HostID })
+	return info, nil
+}
+
+func millisToTime(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond))
+}
+
+// sysRow reads the columns of a system procedure result by name. The columns
+// of the system procedures vary between server versions, missing columns and
+// null values read as zero values. The first error is kept in err.
+type sysRow struct {
+	rows VoltRows
+	err  error
+}
+
+func (r *sysRow) value(cn string) interface{} {
+	if r.err != nil {
+		return nil
+	}
+	ci, err := r.rows.ColumnIndex(cn)
+	if err != nil {
+		return nil
+	}
+	ct, _ := r.rows.ColumnType(ci)
+	get, ok := columnAccessors[int8(ct)]
+	if !ok {
+		r.err = fmt.Errorf("voltdbclient: unexpected type %v of column %s", ct, cn)
+		return nil
+	}
+	v, err := get(r.rows, int16(ci))
+	if err != nil {
+		r.err = err
+		return nil
+	}
+	return v
+}
+
+func (r *sysRow) int64(cn string) int64 {
+	switch v := r.value(cn).(type) {
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case nil:
+		return 0
+	default:
+		r.err = fmt.Errorf("voltdbclient: column %s is not an integer", cn)
+		return 0
+	}
+}
+
+func (r *sysRow) string(cn string) string {
+	switch v := r.value(cn).(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		r.err = fmt.Errorf("voltdbclient: column %s is not a string", cn)
+		return ""
+	}
+}
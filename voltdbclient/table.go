@@ -20,6 +20,8 @@ package voltdbclient
 import (
 	"bytes"
 	"fmt"
+	"math"
+	"strings"
 
 	"github.com/VoltDB/voltdb-client-go/wire"
 )
@@ -29,6 +31,7 @@ const invalidRowIndex = -1
 // Table represents a single result set for a stored procedure invocation.
 type voltTable struct {
 	columnCount int16
+	statusCode  int8
 	columnTypes []int8
 	columnNames []string
 	numRows     int32
@@ -46,13 +49,18 @@ func newVoltTable(columnCount int16, columnTypes []int8, columnNames []string, r
 		columnNames: columnNames,
 		numRows:     rowCount,
 		rows:        rows,
+		statusCode:  math.MinInt8,
 		rowIndex:    invalidRowIndex,
 		cnToCi:      make(map[string]int16),
 	}
 
-	// store columnName to columnIndex
+	// store columnName to columnIndex, the names are case insensitive and a
+	// duplicated name refers to its first column.
 	for ci, cn := range columnNames {
-		vt.cnToCi[cn] = int16(ci)
+		cn = strings.ToUpper(cn)
+		if _, ok := vt.cnToCi[cn]; !ok {
+			vt.cnToCi[cn] = int16(ci)
+		}
 	}
 	return vt
 }
@@ -72,6 +80,12 @@ func (vt *voltTable) advanceToRow(rowIndex int32) bool {
 	return true
 }
 
+// reset moves the row pointer before the first row.
+func (vt *voltTable) reset() {
+	vt.columnOffsets = nil
+	vt.rowIndex = invalidRowIndex
+}
+
 // the common logic for reading a column is here.  Read a column as bytes and
 // the represent it as the correct type.
 func (vt *voltTable) calcOffsets() error {
@@ -134,9 +148,16 @@ func (vt *voltTable) colLength(r *bytes.Reader, offset int32, colType int8) (int
 		}
 		return strlen + 4, nil
 	case 26: // GEOGRAPHY_POINT
-		return 0, fmt.Errorf("Not supporting GEOGRAPHY_POINT")
+		return 16, nil
 	case 27: // GEOGRAPHY
-		return 0, fmt.Errorf("Not supporting GEOGRAPHY")
+		glen, err := a.Int32At(int64(offset))
+		if err != nil {
+			return 0, err
+		}
+		if glen == -1 { // encoding for null.
+			return 4, nil
+		}
+		return glen + 4, nil
 	default:
 		return 0, fmt.Errorf("Unexpected type %d", colType)
 	}
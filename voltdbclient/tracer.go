@@ -0,0 +1,68 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2017 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"context"
+	"time"
+)
+
+// Tracer traces the calls made with a context, such as CallContext and
+// QueryContext. It lets every call be recorded as a span of a tracing library
+// like OpenTelemetry without the client depending on it, an implementation
+// starts a child span of the span in ctx named after the procedure.
+type Tracer interface {
+	// StartCall is called before the invocation of proc with the given
+	// number of parameters is sent. The call waits for its response on the
+	// returned context.
+	StartCall(ctx context.Context, proc string, params int) (context.Context, CallSpan)
+}
+
+// CallSpan is the span of a single call.
+type CallSpan interface {
+	// End is called once when the call finished. status is the status of the
+	// response, it is ResponseUnknown when no response was received. roundTrip
+	// is the time the server took to process the call, it is negative when
+	// unknown. err is nil when the call succeeded.
+	End(status ResponseStatus, roundTrip time.Duration, err error)
+}
+
+// nopTracer is the Tracer used when none is configured.
+type nopTracer struct{}
+
+func (nopTracer) StartCall(ctx context.Context, proc string, params int) (context.Context, CallSpan) {
+	return ctx, nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) End(ResponseStatus, time.Duration, error) {}
+
+// endSpan ends span with the outcome of a call.
+func endSpan(span CallSpan, resp voltResponse, err error) {
+	if verr, ok := err.(VoltError); ok && verr.voltResponse != nil {
+		resp = verr.voltResponse
+	}
+	// errors raised by the client, like timeouts, carry an empty response
+	// with the Success status.
+	if resp == nil || (err != nil && resp.getStatus() == Success) {
+		span.End(ResponseUnknown, -1, err)
+		return
+	}
+	span.End(resp.getStatus(), roundTripTime(resp), err)
+}
@@ -44,9 +44,15 @@ func (s Token2PartitionSlice) Sort() {
 	sort.Sort(s)
 }
 
-// SearchToken2Partitions searches the needed partition by token.
+// SearchToken2Partitions searches the needed partition by token. A token
+// belongs to the partition of the largest token of the ring that isn't above
+// it, the tokens below the smallest one wrap around to the partition of the
+// largest.
 func SearchToken2Partitions(a []token2Partition, token int) (partition int) {
-	t := sort.Search(len(a), func(i int) bool { return a[i].token >= token })
+	t := sort.Search(len(a), func(i int) bool { return a[i].token > token })
+	if t == 0 {
+		t = len(a)
+	}
 	partition = a[t-1].partition
 	return
 }
@@ -0,0 +1,99 @@
+/* This file is part of VoltDB.
+ * Copyright (C) 2008-2017 VoltDB Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with VoltDB.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package voltdbclient
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/VoltDB/voltdb-client-go/wire"
+)
+
+var errGeographyUnsupported = errors.New("voltdbclient: GEOGRAPHY values need VoltDB 6.0 or later")
+
+// buildVersion matches the version in a build string, such as
+// voltdb-8.4.2-0-g1a2b3c4-local, and an optional suffix such as beta1 or dev.
+var buildVersion = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?(?:[-.]?([A-Za-z]\w*))?`)
+
+// ServerVersion is the version of a VoltDB server.
+type ServerVersion struct {
+	Major int
+	Minor int
+	Patch int
+	// Suffix marks pre release builds, such as beta1, dev or SNAPSHOT.
+	Suffix string
+}
+
+// ParseServerVersion parses the version out of the build string a server
+// sends when logging in.
+func ParseServerVersion(build string) (ServerVersion, error) {
+	m := buildVersion.FindStringSubmatch(build)
+	if m == nil {
+		return ServerVersion{}, fmt.Errorf("voltdbclient: no version in build string %q", build)
+	}
+	var v ServerVersion
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		v.Patch, _ = strconv.Atoi(m[3])
+	}
+	v.Suffix = m[4]
+	return v, nil
+}
+
+// AtLeast reports whether v is the version major.minor.patch or later, pre
+// release builds count as the version they precede.
+func (v ServerVersion) AtLeast(major, minor, patch int) bool {
+	if v.Major != major {
+		return v.Major > major
+	}
+	if v.Minor != minor {
+		return v.Minor > minor
+	}
+	return v.Patch >= patch
+}
+
+func (v ServerVersion) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Suffix != "" {
+		s += "-" + v.Suffix
+	}
+	return s
+}
+
+// checkParamSupport returns an error when a parameter has a type the server of
+// version v doesn't support.
+func checkParamSupport(v ServerVersion, params []driver.Value) error {
+	if v.AtLeast(6, 0, 0) {
+		return nil
+	}
+	for _, p := range params {
+		switch x := p.(type) {
+		case wire.GeographyPoint, *wire.GeographyPoint, wire.GeographyPolygon, *wire.GeographyPolygon:
+			return errGeographyUnsupported
+		case wire.NullValue:
+			if x.ColType() == wire.GeographyPointColumn || x.ColType() == wire.GeographyColumn {
+				return errGeographyUnsupported
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package voltdbclient
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/VoltDB/voltdb-client-go/wire"
+)
+
+func TestParseServerVersion(t *testing.T) {
+	sample := []struct {
+		build string
+		exp   ServerVersion
+	}{
+		{"voltdb-8.4.2-0-g1a2b3c4-local", ServerVersion{8, 4, 2, ""}},
+		{"voltdb-7.5-0-g9b6e1a4", ServerVersion{7, 5, 0, ""}},
+		{"voltdb-9.3beta1-12-gdeadbee-local", ServerVersion{9, 3, 0, "beta1"}},
+		{"voltdb-10.0-dev-3-gabc1234", ServerVersion{10, 0, 0, "dev"}},
+		{"6.0.1-SNAPSHOT", ServerVersion{6, 0, 1, "SNAPSHOT"}},
+	}
+	for _, s := range sample {
+		v, err := ParseServerVersion(s.build)
+		if err != nil {
+			t.Errorf("%s: %v", s.build, err)
+			continue
+		}
+		if v != s.exp {
+			t.Errorf("%s: expected %v got %v", s.build, s.exp, v)
+		}
+	}
+	if _, err := ParseServerVersion("stub"); err == nil {
+		t.Error("expected an error for a build string without a version")
+	}
+}
+
+func TestServerVersion_AtLeast(t *testing.T) {
+	v := ServerVersion{Major: 6, Minor: 2, Patch: 1}
+	if !v.AtLeast(6, 2, 1) || !v.AtLeast(6, 0, 5) || !v.AtLeast(5, 9, 9) {
+		t.Errorf("expected %v to be at least 6.2.1, 6.0.5 and 5.9.9", v)
+	}
+	if v.AtLeast(6, 2, 2) || v.AtLeast(6, 3, 0) || v.AtLeast(7, 0, 0) {
+		t.Errorf("expected %v to be older than 6.2.2, 6.3.0 and 7.0.0", v)
+	}
+}
+
+func TestCheckParamSupport(t *testing.T) {
+	params := []driver.Value{int32(1), wire.GeographyPoint{Longitude: 1, Latitude: 2}}
+	if err := checkParamSupport(ServerVersion{Major: 5, Minor: 9}, params); err != errGeographyUnsupported {
+		t.Errorf("expected %v got %v", errGeographyUnsupported, err)
+	}
+	null := []driver.Value{wire.NewNullValue(wire.GeographyColumn)}
+	if err := checkParamSupport(ServerVersion{Major: 5, Minor: 9}, null); err != errGeographyUnsupported {
+		t.Errorf("expected %v got %v", errGeographyUnsupported, err)
+	}
+	if err := checkParamSupport(ServerVersion{Major: 6}, params); err != nil {
+		t.Error(err)
+	}
+	if err := checkParamSupport(ServerVersion{Major: 5}, []driver.Value{int32(1)}); err != nil {
+		t.Error(err)
+	}
+}
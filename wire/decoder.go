@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
+	"math/big"
 	"net"
 	"time"
 )
@@ -15,12 +17,18 @@ const (
 	ShortSize   = 2
 	IntegerSize = 4
 	LongSize    = 8
+	DecimalSize = 16
+
+	GeographyPointSize = 16
 )
 
 // ConnInfo contains information about the database connection. This is returned
 // from the database on a successful login.
 type ConnInfo struct {
 
+	// Version is the protocol version of the login response
+	Version int8
+
 	// HostID is the host ID of the volt node
 	HostID int32
 
@@ -30,7 +38,9 @@ type ConnInfo struct {
 	// Time  in which the cluster was started
 	ClusterStart time.Time
 
-	// IPV4 address of the leader node
+	// IPV4 address of the leader node. The login response carries the
+	// address as an int32 in network byte order, Value holds it as sent and IP
+	// holds it as a net.IP. The protocol has no room for IPv6 addresses.
 	LeaderAddr struct {
 		Value int32
 		IP    net.IP
@@ -78,7 +88,7 @@ func (d *Decoder) Int32() (int32, error) {
 func (d *Decoder) Uint32() (uint32, error) {
 	var a [IntegerSize]byte
 	b := a[:]
-	_, err := d.r.Read(b)
+	_, err := io.ReadFull(d.r, b)
 	if err != nil {
 		return 0, err
 	}
@@ -103,7 +113,7 @@ func (d *Decoder) Int64() (int64, error) {
 func (d *Decoder) Uint64() (uint64, error) {
 	var a [LongSize]byte
 	b := a[:]
-	_, err := d.r.Read(b)
+	_, err := io.ReadFull(d.r, b)
 	if err != nil {
 		return 0, err
 	}
@@ -117,11 +127,34 @@ func (d *Decoder) Time() (time.Time, error) {
 	if err != nil {
 		return time.Time{}, err
 	}
-	if v != math.MinInt64 {
-		ts := time.Unix(0, v*int64(time.Microsecond))
-		return ts.Round(time.Microsecond), err
+	return timestampTime(v), nil
+}
+
+// ReadTimestamp reads a timestamp written by WriteTimestamp from r, or the 8
+// bytes of a TIMESTAMP value. The time is returned in UTC with microsecond
+// precision, a NULL TIMESTAMP is returned as the zero time.Time. It is the
+// same instant Decoder.Time returns, which is in the local time zone.
+func ReadTimestamp(r io.Reader) (time.Time, error) {
+	var b [LongSize]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return time.Time{}, err
+	}
+	t := timestampTime(int64(endian.Uint64(b[:])))
+	if t.IsZero() {
+		return t, nil
 	}
-	return time.Time{}, nil
+	return t.UTC(), nil
+}
+
+// timestampTime returns the time of a TIMESTAMP value of micros microseconds
+// since the epoch, the zero time.Time for NULL.
+func timestampTime(micros int64) time.Time {
+	if micros == math.MinInt64 {
+		return time.Time{}
+	}
+	// the seconds are split off first, the nanoseconds of timestamps far from
+	// the epoch overflow an int64.
+	return time.Unix(micros/1e6, micros%1e6*1e3)
 }
 
 // Float64 reads and decodes voltdb wire protocol encoded []byte to float64.
@@ -133,21 +166,71 @@ func (d *Decoder) Float64() (float64, error) {
 	return math.Float64frombits(v), nil
 }
 
-// String reads and decodes voltdb wire protocol encoded []byte to string.
+// String reads and decodes voltdb wire protocol encoded []byte to string. A
+// NULL string is decoded as an empty string.
 func (d *Decoder) String() (string, error) {
+	s, _, err := d.StringOrNull()
+	return s, err
+}
+
+// StringOrNull reads and decodes a string like String, null is true when the
+// string is NULL, which is sent with a length of -1, and s is empty then.
+// Other negative lengths are invalid and fail without reading further.
+func (d *Decoder) StringOrNull() (s string, null bool, err error) {
 	length, err := d.Int32()
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 	if length == -1 {
-		return "", nil
+		return "", true, nil
+	}
+	if length < 0 {
+		return "", false, fmt.Errorf("voltdbclient: invalid string length %d", length)
 	}
 	b := make([]byte, length)
-	_, err = d.r.Read(b)
+	_, err = io.ReadFull(d.r, b)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
-	return string(b), nil
+	return string(b), false, nil
+}
+
+// Decimal reads and decodes a DECIMAL, the 16 bytes two's complement big
+// endian integer v * 10^12. NULL, the smallest 16 bytes integer, is decoded as
+// a nil *big.Rat.
+func (d *Decoder) Decimal() (*big.Rat, error) {
+	i, err := d.unscaledDecimal()
+	if i == nil || err != nil {
+		return nil, err
+	}
+	return new(big.Rat).SetFrac(i, decimalScaleFactor), nil
+}
+
+// VoltDecimal reads a DECIMAL like Decimal and returns it as it is stored,
+// with a scale of DecimalScale. A NULL DECIMAL is returned with a nil
+// Unscaled.
+func (d *Decoder) VoltDecimal() (VoltDecimal, error) {
+	i, err := d.unscaledDecimal()
+	if i == nil || err != nil {
+		return VoltDecimal{}, err
+	}
+	return VoltDecimal{Unscaled: i, Scale: DecimalScale}, nil
+}
+
+// unscaledDecimal reads the unscaled value of a DECIMAL, it is nil for NULL.
+func (d *Decoder) unscaledDecimal() (*big.Int, error) {
+	var b [DecimalSize]byte
+	if _, err := io.ReadFull(d.r, b[:]); err != nil {
+		return nil, err
+	}
+	if b[0] == 0x80 && bytes.Count(b[1:], []byte{0}) == DecimalSize-1 {
+		return nil, nil
+	}
+	i := new(big.Int).SetBytes(b[:])
+	if b[0]&0x80 != 0 {
+		i.Sub(i, decimalModulus)
+	}
+	return i, nil
 }
 
 // Uint16 reads and decodes voltdb wire protocol encoded []byte into uint16.
@@ -158,7 +241,7 @@ func (d *Decoder) String() (string, error) {
 func (d *Decoder) Uint16() (uint16, error) {
 	var a [ShortSize]byte
 	b := a[:]
-	_, err := d.r.Read(b)
+	_, err := io.ReadFull(d.r, b)
 	if err != nil {
 		return 0, err
 	}
@@ -192,6 +275,118 @@ func (d *Decoder) StringSlice() ([]string, error) {
 	return a, nil
 }
 
+// GeographyPoint reads and decodes voltdb wire protocol encoded []byte to
+// GeographyPoint. A null point has both coordinates set to 360.
+func (d *Decoder) GeographyPoint() (GeographyPoint, error) {
+	lng, err := d.Float64()
+	if err != nil {
+		return GeographyPoint{}, err
+	}
+	lat, err := d.Float64()
+	if err != nil {
+		return GeographyPoint{}, err
+	}
+	return GeographyPoint{Longitude: lng, Latitude: lat}, nil
+}
+
+// Geography reads and decodes voltdb wire protocol encoded []byte to
+// GeographyPolygon. The polygon is prefixed with its size, a null polygon has a
+// size of -1 and is decoded as an empty GeographyPolygon.
+//
+// Inner rings are reversed back to the order they were sent in and the closing
+// point of every ring is restored, so decoding a polygon returns the rings that
+// were passed to (*Encoder).Geography.
+func (d *Decoder) Geography() (GeographyPolygon, error) {
+	size, err := d.Int32()
+	if err != nil {
+		return GeographyPolygon{}, err
+	}
+	if size == -1 {
+		return GeographyPolygon{}, nil
+	}
+	if size < -1 {
+		return GeographyPolygon{}, fmt.Errorf("voltdbclient: invalid geography length %d", size)
+	}
+	b := make([]byte, size)
+	if _, err = io.ReadFull(d.r, b); err != nil {
+		return GeographyPolygon{}, err
+	}
+	p := NewDecoder(bytes.NewReader(b))
+	if err = p.skip(polygonHeaderSize - IntegerSize); err != nil {
+		return GeographyPolygon{}, err
+	}
+	n, err := p.Int32()
+	if err != nil {
+		return GeographyPolygon{}, err
+	}
+	if n < 0 {
+		return GeographyPolygon{}, fmt.Errorf("voltdbclient: invalid polygon ring count %d", n)
+	}
+	var v GeographyPolygon
+	for i := int32(0); i < n; i++ {
+		r, depth, err := p.ring()
+		if err != nil {
+			return GeographyPolygon{}, err
+		}
+		if depth == 0 && v.OuterRing == nil {
+			v.OuterRing = r
+			continue
+		}
+		// holes are stored with the opposite orientation of the outer ring.
+		for j, k := 1, len(r)-2; j < k; j, k = j+1, k-1 {
+			r[j], r[k] = r[k], r[j]
+		}
+		v.InnerRings = append(v.InnerRings, r)
+	}
+	return v, nil
+}
+
+// ring decodes one ring of a polygon and returns its points, including the
+// closing point, and its nesting depth.
+func (d *Decoder) ring() ([]GeographyPoint, int32, error) {
+	if err := d.skip(ByteSize); err != nil {
+		return nil, 0, err
+	}
+	n, err := d.Int32()
+	if err != nil {
+		return nil, 0, err
+	}
+	if n <= 0 {
+		return nil, 0, fmt.Errorf("voltdbclient: invalid polygon ring size %d", n)
+	}
+	r := make([]GeographyPoint, n+1)
+	for i := int32(0); i < n; i++ {
+		var xyz [3]float64
+		for j := range xyz {
+			if xyz[j], err = d.Float64(); err != nil {
+				return nil, 0, err
+			}
+		}
+		r[i] = GeographyPoint{
+			Longitude: math.Atan2(xyz[1], xyz[0]) * 180 / math.Pi,
+			Latitude:  math.Atan2(xyz[2], math.Hypot(xyz[0], xyz[1])) * 180 / math.Pi,
+		}
+	}
+	r[n] = r[0]
+	if err = d.skip(ByteSize); err != nil {
+		return nil, 0, err
+	}
+	depth, err := d.Int32()
+	if err != nil {
+		return nil, 0, err
+	}
+	if err = d.skip(boundSize); err != nil {
+		return nil, 0, err
+	}
+	return r, depth, nil
+}
+
+// skip discards the next n bytes.
+func (d *Decoder) skip(n int64) error {
+	_, err := io.CopyN(ioutil.Discard, d.r, n)
+	return err
+}
+
 // Read implements io.Reader
 func (d *Decoder) Read(b []byte) (int, error) {
 	return d.r.Read(b)
@@ -227,7 +422,7 @@ func (d *Decoder) MessageHeader() (int32, error) {
 func (d *Decoder) Byte() (int8, error) {
 	var a [ByteSize]byte
 	b := a[:]
-	_, err := d.r.Read(b)
+	_, err := io.ReadFull(d.r, b)
 	if err != nil {
 		return 0, err
 	}
@@ -246,50 +441,120 @@ func (d *Decoder) Byte() (int8, error) {
 func (d *Decoder) Login() (*ConnInfo, error) {
 	msg, err := d.Message()
 	if err != nil {
-		return nil, err
+		return nil, incompleteLogin(err)
 	}
 	return NewDecoder(bytes.NewReader(msg)).LoginInfo()
 }
 
+// IncompleteLoginError is returned when the login response ends before all of
+// its fields are read, such as when the server closes the connection during
+// the handshake. Err is the underlying read error. Unlike AuthError the server
+// didn't reject the credentials.
+type IncompleteLoginError struct {
+	Err error
+}
+
+func (e IncompleteLoginError) Error() string {
+	return fmt.Sprintf("voltdbclient: incomplete login response from server, %v", e.Err)
+}
+
+// incompleteLogin turns the errors of a short login response into an
+// IncompleteLoginError, other errors such as timeouts are returned as is.
+func incompleteLogin(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return IncompleteLoginError{Err: err}
+	}
+	return err
+}
+
+// The result codes of a login response rejected by the server.
+const (
+	AuthFailure         int8 = -1
+	AuthMaxConnections  int8 = 1
+	AuthTimeout         int8 = 2
+	AuthFormatViolation int8 = 3
+	AuthRejoining       int8 = 4
+	AuthExportDisabled  int8 = 5
+)
+
+// AuthError is returned when the server rejects a login, Code is the result
+// code of the login response.
+type AuthError struct {
+	Code int8
+}
+
+func (e AuthError) Error() string {
+	return fmt.Sprintf("voltdbclient: authentication failed with code %d, %s", e.Code, e.Reason())
+}
+
+// Reason describes the result code.
+func (e AuthError) Reason() string {
+	switch e.Code {
+	case AuthFailure:
+		return "bad credentials"
+	case AuthMaxConnections:
+		return "server has too many connections"
+	case AuthTimeout:
+		return "connection timed out during authentication, the server may be overloaded"
+	case AuthFormatViolation:
+		return "wire protocol format violation"
+	case AuthRejoining:
+		return "failed to authenticate to rejoining node"
+	case AuthExportDisabled:
+		return "export not enabled for server"
+	}
+	return "authentication rejected"
+}
+
+// Temporary reports whether logging in again later may succeed, it is false
+// for bad credentials and other errors retrying won't fix.
+func (e AuthError) Temporary() bool {
+	switch e.Code {
+	case AuthMaxConnections, AuthTimeout, AuthRejoining:
+		return true
+	}
+	return false
+}
+
 //LoginInfo decodes login message.
 func (d *Decoder) LoginInfo() (*ConnInfo, error) {
 	c := &ConnInfo{}
 
-	// version
-	_, err := d.Byte()
+	version, err := d.Byte()
 	if err != nil {
-		return nil, err
+		return nil, incompleteLogin(err)
 	}
+	c.Version = version
 	// auth code
 	code, err := d.Byte()
 	if err != nil {
-		return nil, err
+		return nil, incompleteLogin(err)
 	}
 	if code != 0 {
-		return nil, fmt.Errorf("voltdbclient:authorization failed with code %d", code)
+		return nil, AuthError{Code: code}
 	}
 
 	host, err := d.Int32()
 	if err != nil {
-		return nil, err
+		return nil, incompleteLogin(err)
 	}
 	c.HostID = host
 
 	conn, err := d.Int64()
 	if err != nil {
-		return nil, err
+		return nil, incompleteLogin(err)
 	}
 	c.Connection = conn
 
 	start, err := d.Time()
 	if err != nil {
-		return nil, err
+		return nil, incompleteLogin(err)
 	}
 	c.ClusterStart = start
 
 	leader, err := d.Int32()
 	if err != nil {
-		return nil, err
+		return nil, incompleteLogin(err)
 	}
 	c.LeaderAddr.Value = leader
 	c.LeaderAddr.IP = make(net.IP, IntegerSize)
@@ -297,7 +562,7 @@ func (d *Decoder) LoginInfo() (*ConnInfo, error) {
 
 	build, err := d.String()
 	if err != nil {
-		return nil, err
+		return nil, incompleteLogin(err)
 	}
 	c.Build = build
 	return c, nil
@@ -2,8 +2,14 @@ package wire
 
 import (
 	"bytes"
+	"io"
 	"io/ioutil"
+	"math"
+	"math/big"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 )
 
 func TestDecodeLoginInfo(t *testing.T) {
@@ -16,6 +22,9 @@ func TestDecodeLoginInfo(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if info.Version != 0 {
+		t.Errorf("expected version 0 got %d", info.Version)
+	}
 	if info.HostID != 0 {
 		t.Errorf("expected 0 got %d", info.HostID)
 	}
@@ -31,3 +40,401 @@ func TestDecodeLoginInfo(t *testing.T) {
 		t.Errorf("expected build got %s", info.Build)
 	}
 }
+
+func TestDecoder_LoginLeaderAddr(t *testing.T) {
+	e := NewEncoder()
+	e.Byte(0) // version
+	e.Byte(0) // auth code
+	e.Int32(1)
+	e.Int64(2)
+	e.Int64(0) // cluster start
+	e.Int32(-1062729211)
+	e.String("build")
+	info, err := NewDecoder(bytes.NewReader(e.Bytes())).LoginInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// -1062729211 is 0xc0a80a05
+	if ip := info.LeaderAddr.IP.String(); ip != "192.168.10.5" {
+		t.Errorf("expected 192.168.10.5 got %s", ip)
+	}
+	if info.LeaderAddr.Value != -1062729211 {
+		t.Errorf("expected %d got %d", -1062729211, info.LeaderAddr.Value)
+	}
+}
+
+func TestExportLoginHandshake(t *testing.T) {
+	e := NewEncoder()
+	req, err := e.EncodeLogin(LoginRequest{Version: 1, Scheme: HashSHA256, Service: "export", User: "u", Password: "p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the service follows the message length, protocol and hash versions.
+	service := []byte{0, 0, 0, 6, 'e', 'x', 'p', 'o', 'r', 't'}
+	if !bytes.Equal(req[6:6+len(service)], service) {
+		t.Errorf("expected service %v got %v", service, req[6:6+len(service)])
+	}
+
+	resp := []byte{
+		0, 0, 0, 0x27, // message length
+		1,          // version
+		0,          // auth code
+		0, 0, 0, 3, // host id
+		0, 0, 0, 0, 0, 0, 0, 9, // connection id
+		0, 0, 0x01, 0x5a, 0x7c, 0x9a, 0x6e, 0x00, // cluster start millis
+		0x7f, 0, 0, 1, // leader address
+		0, 0, 0, 0x09, 'v', 'o', 'l', 't', '_', '7', '.', '0', 'x', // build
+	}
+	info, err := NewDecoder(bytes.NewReader(resp)).Login()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != 1 || info.HostID != 3 || info.Connection != 9 {
+		t.Errorf("unexpected login info %+v", info)
+	}
+	if ip := info.LeaderAddr.IP.String(); ip != "127.0.0.1" {
+		t.Errorf("expected 127.0.0.1 got %s", ip)
+	}
+	if info.Build != "volt_7.0x" {
+		t.Errorf("expected volt_7.0x got %s", info.Build)
+	}
+}
+
+func TestDecoder_TimeFarFromEpoch(t *testing.T) {
+	for _, ts := range []time.Time{
+		time.Date(1600, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(9999, 12, 31, 23, 59, 59, 999999000, time.UTC),
+	} {
+		e := NewEncoder()
+		if _, err := e.Time(ts); err != nil {
+			t.Fatal(err)
+		}
+		v, err := NewDecoder(e).Time()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !v.Equal(ts) {
+			t.Errorf("expected %v got %v", ts, v)
+		}
+	}
+}
+
+func TestDecoder_LoginRejected(t *testing.T) {
+	sample := []struct {
+		code      int8
+		reason    string
+		temporary bool
+	}{
+		{AuthFailure, "bad credentials", false},
+		{AuthMaxConnections, "server has too many connections", true},
+		{AuthTimeout, "connection timed out during authentication, the server may be overloaded", true},
+		{AuthFormatViolation, "wire protocol format violation", false},
+		{AuthRejoining, "failed to authenticate to rejoining node", true},
+		{AuthExportDisabled, "export not enabled for server", false},
+		{42, "authentication rejected", false},
+	}
+	for _, s := range sample {
+		e := NewEncoder()
+		e.Byte(0) // version
+		e.Byte(s.code)
+		_, err := NewDecoder(bytes.NewReader(e.Message(e.Bytes()))).Login()
+		aerr, ok := err.(AuthError)
+		if !ok {
+			t.Fatalf("code %d: expected AuthError got %v", s.code, err)
+		}
+		if aerr.Code != s.code || aerr.Reason() != s.reason || aerr.Temporary() != s.temporary {
+			t.Errorf("code %d: unexpected error %v temporary %v", s.code, aerr, aerr.Temporary())
+		}
+	}
+}
+
+func TestDecoder_LoginIncomplete(t *testing.T) {
+	e := NewEncoder()
+	e.Byte(0)  // version
+	e.Byte(0)  // auth code
+	e.Int32(1) // host id
+	e.Int64(2) // connection id
+	e.Int64(3) // cluster start
+	e.Int32(4) // leader address
+	e.String("build")
+	msg := e.Message(e.Bytes())
+	if _, err := NewDecoder(bytes.NewReader(msg)).Login(); err != nil {
+		t.Fatal(err)
+	}
+	// the server closing the connection at any point of the handshake.
+	for n := 0; n < len(msg); n++ {
+		_, err := NewDecoder(bytes.NewReader(msg[:n])).Login()
+		if _, ok := err.(IncompleteLoginError); !ok {
+			t.Fatalf("%d bytes: expected IncompleteLoginError got %v", n, err)
+		}
+		if !strings.Contains(err.Error(), "incomplete login response from server") {
+			t.Errorf("%d bytes: unexpected message %q", n, err)
+		}
+	}
+	// a complete message with missing fields.
+	body := msg[4:]
+	for n := 0; n < len(body); n++ {
+		_, err := NewDecoder(bytes.NewReader(e.Message(body[:n]))).Login()
+		if _, ok := err.(IncompleteLoginError); !ok {
+			t.Fatalf("%d byte body: expected IncompleteLoginError got %v", n, err)
+		}
+	}
+}
+
+func TestDecoder_Geography(t *testing.T) {
+	p := GeographyPolygon{
+		OuterRing: []GeographyPoint{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}},
+		InnerRings: [][]GeographyPoint{
+			{{2, 2}, {2, 4}, {4, 4}, {4, 2}, {2, 2}},
+			{{6, 6}, {6, 8}, {8, 8}, {8, 6}, {6, 6}},
+		},
+	}
+	e := NewEncoder()
+	if _, err := e.Geography(p); err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewDecoder(bytes.NewReader(e.Bytes())).Geography()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.InnerRings) != len(p.InnerRings) {
+		t.Fatalf("expected %d inner rings got %d", len(p.InnerRings), len(v.InnerRings))
+	}
+	rings := append([][]GeographyPoint{p.OuterRing}, p.InnerRings...)
+	got := append([][]GeographyPoint{v.OuterRing}, v.InnerRings...)
+	for i := range rings {
+		if len(got[i]) != len(rings[i]) {
+			t.Fatalf("ring %d: expected %v got %v", i, rings[i], got[i])
+		}
+		for j := range rings[i] {
+			if !equalPoints(rings[i][j], got[i][j]) {
+				t.Errorf("ring %d: expected %v got %v", i, rings[i], got[i])
+				break
+			}
+		}
+	}
+
+	e.Reset()
+	e.Int32(-1)
+	v, err = NewDecoder(bytes.NewReader(e.Bytes())).Geography()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.OuterRing != nil || v.InnerRings != nil {
+		t.Errorf("expected an empty polygon got %v", v)
+	}
+
+	e.Reset()
+	e.Int32(-2)
+	if _, err = NewDecoder(bytes.NewReader(e.Bytes())).Geography(); err == nil {
+		t.Error("expected an error decoding a negative length")
+	}
+}
+
+func equalPoints(a, b GeographyPoint) bool {
+	return math.Abs(a.Longitude-b.Longitude) < 1e-9 && math.Abs(a.Latitude-b.Latitude) < 1e-9
+}
+
+func TestDecoder_ShortReads(t *testing.T) {
+	e := NewEncoder()
+	e.Byte(-3)
+	e.Int16(-300)
+	e.Int32(70000)
+	e.Int64(-5000000000)
+	e.Float64(3.25)
+	e.String("volt")
+	d := NewDecoder(iotest.OneByteReader(bytes.NewReader(e.Bytes())))
+	if v, err := d.Byte(); err != nil || v != -3 {
+		t.Errorf("expected -3 got %v %v", v, err)
+	}
+	if v, err := d.Int16(); err != nil || v != -300 {
+		t.Errorf("expected -300 got %v %v", v, err)
+	}
+	if v, err := d.Int32(); err != nil || v != 70000 {
+		t.Errorf("expected 70000 got %v %v", v, err)
+	}
+	if v, err := d.Int64(); err != nil || v != -5000000000 {
+		t.Errorf("expected -5000000000 got %v %v", v, err)
+	}
+	if v, err := d.Float64(); err != nil || v != 3.25 {
+		t.Errorf("expected 3.25 got %v %v", v, err)
+	}
+	if v, err := d.String(); err != nil || v != "volt" {
+		t.Errorf("expected volt got %v %v", v, err)
+	}
+	if _, err := d.Int32(); err != io.EOF {
+		t.Errorf("expected EOF got %v", err)
+	}
+}
+
+func TestDecoder_StringOrNull(t *testing.T) {
+	e := NewEncoder()
+	e.String("volt")
+	e.Int32(-1)
+	e.String("")
+	e.Int32(-2)
+	e.String("after")
+	d := NewDecoder(bytes.NewReader(e.Bytes()))
+	if s, null, err := d.StringOrNull(); err != nil || null || s != "volt" {
+		t.Errorf("expected volt got %q %v %v", s, null, err)
+	}
+	if s, null, err := d.StringOrNull(); err != nil || !null || s != "" {
+		t.Errorf("expected a null string got %q %v %v", s, null, err)
+	}
+	if s, null, err := d.StringOrNull(); err != nil || null || s != "" {
+		t.Errorf("expected an empty string got %q %v %v", s, null, err)
+	}
+	if _, _, err := d.StringOrNull(); err == nil {
+		t.Error("expected a negative length to fail")
+	}
+	// nothing is read past an invalid length.
+	if s, err := d.String(); err != nil || s != "after" {
+		t.Errorf("expected after got %q %v", s, err)
+	}
+}
+
+func TestDecoder_Decimal(t *testing.T) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(DecimalPrecision), nil)
+	max.Sub(max, big.NewInt(1))
+	largest := new(big.Rat).SetFrac(max, decimalScaleFactor)
+	values := []*big.Rat{
+		big.NewRat(0, 1),
+		big.NewRat(1, 1000000000000),
+		big.NewRat(-1, 1000000000000),
+		big.NewRat(-1, 1),
+		big.NewRat(12345678, 100),
+		largest,
+		new(big.Rat).Neg(largest),
+		nil,
+	}
+	e := NewEncoder()
+	for _, v := range values {
+		if n, err := e.Decimal(v); err != nil || n != DecimalSize {
+			t.Fatalf("%v: expected %d bytes got %d %v", v, DecimalSize, n, err)
+		}
+	}
+	// -1 is encoded as -10^12 in two's complement.
+	minusOne := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x17, 0x2b, 0x5a, 0xf0, 0x00}
+	if b := e.Bytes()[DecimalSize*3 : DecimalSize*4]; !bytes.Equal(b, minusOne) {
+		t.Errorf("expected -1 to be encoded as %x got %x", minusOne, b)
+	}
+	d := NewDecoder(bytes.NewReader(e.Bytes()))
+	for _, v := range values {
+		got, err := d.Decimal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (v == nil) != (got == nil) || (v != nil && got.Cmp(v) != 0) {
+			t.Errorf("expected %v got %v", v, got)
+		}
+	}
+	if _, err := d.Decimal(); err != io.EOF {
+		t.Errorf("expected EOF got %v", err)
+	}
+
+	// one more than the largest value overflows, more than 12 fractional
+	// digits can't be represented.
+	tooLarge := new(big.Rat).Add(largest, big.NewRat(1, 1000000000000))
+	if _, err := e.Decimal(tooLarge); err != errDecimalOverflow {
+		t.Errorf("expected %v got %v", errDecimalOverflow, err)
+	}
+	if _, err := e.Decimal(big.NewRat(1, 10000000000000)); err != errDecimalScale {
+		t.Errorf("expected %v got %v", errDecimalScale, err)
+	}
+	d = NewDecoder(bytes.NewReader(make([]byte, DecimalSize-1)))
+	if _, err := d.Decimal(); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected %v got %v", io.ErrUnexpectedEOF, err)
+	}
+}
+
+func TestDecoder_VoltDecimal(t *testing.T) {
+	e := NewEncoder()
+	e.Decimal(big.NewRat(-12345678, 100))
+	e.Decimal(nil)
+	d := NewDecoder(bytes.NewReader(e.Bytes()))
+	v, err := d.VoltDecimal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the exact value as stored, with all 12 fractional digits.
+	if v.Scale != DecimalScale || v.Unscaled.String() != "-123456780000000000" {
+		t.Errorf("unexpected %v scale %d", v.Unscaled, v.Scale)
+	}
+	if v.String() != "-123456.780000000000" {
+		t.Errorf("unexpected %s", v)
+	}
+	v, err = d.VoltDecimal()
+	if err != nil || v.Unscaled != nil {
+		t.Errorf("expected NULL got %v %v", v, err)
+	}
+	if _, err := d.VoltDecimal(); err != io.EOF {
+		t.Errorf("expected %v got %v", io.EOF, err)
+	}
+}
+
+func TestVoltDecimal_Rescale(t *testing.T) {
+	sample := []struct {
+		unscaled int64
+		scale    int
+		to       int
+		exp      string
+	}{
+		// half to even.
+		{25, 1, 0, "2"},
+		{35, 1, 0, "4"},
+		{-25, 1, 0, "-2"},
+		{-35, 1, 0, "-4"},
+		{125, 2, 1, "1.2"},
+		{135, 2, 1, "1.4"},
+		// above and below half.
+		{251, 2, 1, "2.5"},
+		{2501, 3, 1, "2.5"},
+		{2551, 3, 1, "2.6"},
+		{-2551, 3, 1, "-2.6"},
+		{249, 2, 0, "2"},
+		{-249, 2, 0, "-2"},
+		{5, 1, 0, "0"},
+		{15, 1, 0, "2"},
+		{4, 1, 0, "0"},
+		{6, 1, 0, "1"},
+		// more fractional digits are exact.
+		{125, 2, 4, "1.2500"},
+		{-1, 0, 2, "-1.00"},
+		{0, 2, 0, "0"},
+		{12, 0, -1, "10"},
+		{15, 0, -1, "20"},
+	}
+	for _, s := range sample {
+		d := VoltDecimal{Unscaled: big.NewInt(s.unscaled), Scale: s.scale}
+		r := d.Rescale(s.to)
+		if r.Scale != s.to || r.String() != s.exp {
+			t.Errorf("%s to scale %d: expected %s got %s scale %d", d, s.to, s.exp, r, r.Scale)
+		}
+		if d.Unscaled.Int64() != s.unscaled {
+			t.Errorf("%d: the rescaled decimal was modified", s.unscaled)
+		}
+	}
+	if r := (VoltDecimal{}).Rescale(2); r.Unscaled != nil || r.String() != "<nil>" {
+		t.Errorf("expected NULL got %v", r)
+	}
+}
+
+func TestVoltDecimal_String(t *testing.T) {
+	sample := []struct {
+		d   VoltDecimal
+		exp string
+	}{
+		{VoltDecimal{big.NewInt(150), 2}, "1.50"},
+		{VoltDecimal{big.NewInt(-150), 2}, "-1.50"},
+		{VoltDecimal{big.NewInt(5), 3}, "0.005"},
+		{VoltDecimal{big.NewInt(-5), 3}, "-0.005"},
+		{VoltDecimal{big.NewInt(0), 2}, "0.00"},
+		{VoltDecimal{big.NewInt(7), 0}, "7"},
+		{VoltDecimal{big.NewInt(7), -2}, "700"},
+	}
+	for _, s := range sample {
+		if got := s.d.String(); got != s.exp {
+			t.Errorf("expected %s got %s", s.exp, got)
+		}
+	}
+}
@@ -4,23 +4,31 @@ import (
 	"bytes"
 	"crypto/sha1"
 	"crypto/sha256"
+	"database/sql"
 	"database/sql/driver"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"hash"
+	"io"
 	"math"
+	"math/big"
 	"reflect"
+	"strings"
+	"sync"
 	"time"
 )
 
-//size of bytes
+// size of bytes
 const ()
 
-//Column types
+// Column types
 const (
 	ArrayColumn     int8 = -99 // array (short)(values*)
 	NullColumn      int8 = 1   // null
 	BoolColumn      int8 = 3   // boolean
+	TinyIntColumn   int8 = 3   // int8
 	ByteColumn      int8 = 3   // byte
 	ShortColumn     int8 = 4   // int16
 	IntColumn       int8 = 5   // int32
@@ -31,9 +39,265 @@ const (
 	Table           int8 = 21  // VoltTable
 	DecimalColumn   int8 = 22  // fix-scaled, fix-precision decimal
 	VarBinColumn    int8 = 25  // varbinary (int)(bytes)
+
+	GeographyPointColumn int8 = 26 // geography point (float64 longitude)(float64 latitude)
+	GeographyColumn      int8 = 27 // geography (int32 length)(polygon bytes)
+)
+
+// DECIMAL values are fixed-scale, fixed-precision numbers
+const (
+	DecimalScale     = 12
+	DecimalPrecision = 38
 )
 
 var errUnknownParam = errors.New("voltdbclient: unknown parameter type")
+var errDecimalScale = errors.New("voltdbclient: decimal has more than 12 fractional digits")
+var errDecimalOverflow = errors.New("voltdbclient: decimal exceeds 38 digits of precision")
+var errLongitude = errors.New("voltdbclient: longitude must be in the range [-180, 180]")
+var errLatitude = errors.New("voltdbclient: latitude must be in the range [-90, 90]")
+var errHashScheme = errors.New("voltdbclient: unknown password hash scheme")
+var errPasswordHashSize = errors.New("voltdbclient: password hash size does not match the hash scheme")
+var errRingNotClosed = errors.New("voltdbclient: polygon ring is not closed, the first and last points must be the same")
+var errRingTooShort = errors.New("voltdbclient: polygon ring must have at least 4 points")
+var errRingEmpty = errors.New("voltdbclient: polygon ring is empty, send a NULL GEOGRAPHY with NullGeography or a nil *GeographyPolygon")
+var errUnsignedRange = errors.New("voltdbclient: unsigned value exceeds the range of BIGINT")
+var errStreamLength = errors.New("voltdbclient: stream length must be in the range [0, 2147483647]")
+var errArrayElemType = errors.New("voltdbclient: array element doesn't match the element type of the array")
+var errMapKey = errors.New("voltdbclient: only maps with string keys can be sent")
+var errMapAsJSON = errors.New("voltdbclient: maps are only sent as JSON when MapAsJSON is set")
+var errVarbinaryWidth = errors.New("voltdbclient: fixed width varbinary doesn't have the declared width")
+
+var (
+	decimalScaleFactor = new(big.Int).Exp(big.NewInt(10), big.NewInt(DecimalScale), nil)
+	decimalMaxUnscaled = new(big.Int).Exp(big.NewInt(10), big.NewInt(DecimalPrecision), nil)
+	decimalModulus     = new(big.Int).Lsh(big.NewInt(1), DecimalSize*8)
+)
+
+// VoltDecimal is a fixed-point number to be sent to a DECIMAL column. The
+// value it represents is Unscaled * 10^-Scale, a VoltDecimal with a nil
+// Unscaled is sent as a NULL DECIMAL.
+type VoltDecimal struct {
+	Unscaled *big.Int
+	Scale    int
+}
+
+// Rat returns the value of d as a *big.Rat.
+func (d VoltDecimal) Rat() *big.Rat {
+	r := new(big.Rat).SetInt(d.Unscaled)
+	if d.Scale == 0 {
+		return r
+	}
+	f := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(d.Scale))), nil)
+	if d.Scale > 0 {
+		return r.Quo(r, new(big.Rat).SetInt(f))
+	}
+	return r.Mul(r, new(big.Rat).SetInt(f))
+}
+
+// Rescale returns d with the given scale. Digits dropped when the scale is
+// reduced are rounded half to even, 2.5 rescaled to 0 is 2 and 3.5 is 4. A
+// NULL d stays NULL.
+func (d VoltDecimal) Rescale(scale int) VoltDecimal {
+	if d.Unscaled == nil {
+		return VoltDecimal{Scale: scale}
+	}
+	diff := scale - d.Scale
+	f := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(diff))), nil)
+	if diff >= 0 {
+		return VoltDecimal{Unscaled: f.Mul(d.Unscaled, f), Scale: scale}
+	}
+	q, r := new(big.Int).QuoRem(d.Unscaled, f, new(big.Int))
+	// round away from zero when the remainder is above half, or exactly half
+	// and the quotient is odd.
+	half := new(big.Int).Abs(r)
+	switch half.Lsh(half, 1).Cmp(f) {
+	case 1:
+		q.Add(q, big.NewInt(int64(d.Unscaled.Sign())))
+	case 0:
+		if q.Bit(0) == 1 {
+			q.Add(q, big.NewInt(int64(d.Unscaled.Sign())))
+		}
+	}
+	return VoltDecimal{Unscaled: q, Scale: scale}
+}
+
+// String formats d with Scale fractional digits, such as 1.50 for an Unscaled
+// of 150 and a Scale of 2. A NULL d is formatted as <nil>.
+func (d VoltDecimal) String() string {
+	if d.Unscaled == nil {
+		return "<nil>"
+	}
+	if d.Scale <= 0 {
+		return d.Unscaled.String() + strings.Repeat("0", -d.Scale)
+	}
+	digits := new(big.Int).Abs(d.Unscaled).String()
+	if len(digits) <= d.Scale {
+		digits = strings.Repeat("0", d.Scale-len(digits)+1) + digits
+	}
+	sign := ""
+	if d.Unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	i := len(digits) - d.Scale
+	return sign + digits[:i] + "." + digits[i:]
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// GeographyPoint is a point on the surface of the earth to be sent to a
+// GEOGRAPHY_POINT column. Longitude and Latitude are in degrees.
+type GeographyPoint struct {
+	Longitude float64
+	Latitude  float64
+}
+
+// nullCoord is the value of both coordinates of a null GEOGRAPHY_POINT
+const nullCoord = 360.0
+
+// GeographyPolygon is a polygon on the surface of the earth to be sent to a
+// GEOGRAPHY column. It has one outer ring and zero or more inner rings which
+// are holes in the polygon. Every ring must be closed, that is its first and
+// last points must be the same. A polygon with an empty ring can't be sent, a
+// NULL GEOGRAPHY is sent with NullGeography or a nil *GeographyPolygon.
+type GeographyPolygon struct {
+	OuterRing  []GeographyPoint
+	InnerRings [][]GeographyPoint
+}
+
+// Sizes of the parts of an encoded polygon. VoltDB doesn't expect the client
+// to compute the bounding boxes of the polygon and its rings, they are sent
+// empty and the server fills them in.
+const (
+	polygonHeaderSize = 7               // 3 flags, int32 ring count
+	boundSize         = 1 + 4*LongSize  // version, lat and lng intervals
+	ringHeaderSize    = 1 + IntegerSize // flag, int32 vertex count
+	ringTrailerSize   = 1 + IntegerSize // origin inside flag, int32 depth
+	vertexSize        = 3 * LongSize    // x, y, z
+)
+
+// FixedVarbinary is a value to be sent to a VARBINARY(Width) column that is
+// always filled to its declared width, such as a binary key. Marshalling it
+// fails when Bytes isn't exactly Width bytes long, instead of silently sending
+// a value of the wrong width.
+type FixedVarbinary struct {
+	Width int
+	Bytes []byte
+}
+
+// NullValue is a NULL argument. VoltDB needs to know the type of the column a
+// NULL is meant for, this is one of the column type constants.
+type NullValue struct {
+	colType int8
+}
+
+// NewNullValue returns a NULL argument for a column of type colType.
+func NewNullValue(colType int8) NullValue {
+	return NullValue{colType: colType}
+}
+
+// ColType returns the column type of the NULL value.
+func (n NullValue) ColType() int8 {
+	return n.colType
+}
+
+// Value implements database/sql/driver.Valuer, the value of a NULL is nil. The
+// voltdbclient driver passes NullValue arguments through as they are, so the
+// type of the column is kept.
+func (n NullValue) Value() (driver.Value, error) {
+	return nil, nil
+}
+
+// NullTinyInt returns a NULL argument for a TINYINT column.
+func NullTinyInt() NullValue { return NewNullValue(TinyIntColumn) }
+
+// NullSmallInt returns a NULL argument for a SMALLINT column.
+func NullSmallInt() NullValue { return NewNullValue(ShortColumn) }
+
+// NullInteger returns a NULL argument for an INTEGER column.
+func NullInteger() NullValue { return NewNullValue(IntColumn) }
+
+// NullBigInt returns a NULL argument for a BIGINT column.
+func NullBigInt() NullValue { return NewNullValue(LongColumn) }
+
+// NullFloat returns a NULL argument for a FLOAT column.
+func NullFloat() NullValue { return NewNullValue(FloatColumn) }
+
+// NullString returns a NULL argument for a VARCHAR column.
+func NullString() NullValue { return NewNullValue(StringColumn) }
+
+// NullVarbinary returns a NULL argument for a VARBINARY column.
+func NullVarbinary() NullValue { return NewNullValue(VarBinColumn) }
+
+// NullTimestamp returns a NULL argument for a TIMESTAMP column.
+func NullTimestamp() NullValue { return NewNullValue(TimestampColumn) }
+
+// NullDecimal returns a NULL argument for a DECIMAL column.
+func NullDecimal() NullValue { return NewNullValue(DecimalColumn) }
+
+// NullGeographyPoint returns a NULL argument for a GEOGRAPHY_POINT column.
+func NullGeographyPoint() NullValue { return NewNullValue(GeographyPointColumn) }
+
+// NullGeography returns a NULL argument for a GEOGRAPHY column.
+func NullGeography() NullValue { return NewNullValue(GeographyColumn) }
+
+// DefaultMaxStringLen is the maximum length in bytes of VARCHAR arguments
+// unless the Encoder is configured otherwise, it's the largest VARCHAR
+// column VoltDB supports.
+const DefaultMaxStringLen = 1 << 20
+
+// StringLengthError is returned when a VARCHAR argument is longer than the
+// maximum length of the Encoder, nothing is written for it.
+type StringLengthError struct {
+	Len int
+	Max int
+}
+
+func (e StringLengthError) Error() string {
+	return fmt.Sprintf("voltdbclient: string exceeds max length %d, it is %d bytes", e.Max, e.Len)
+}
+
+// StreamValue is a VARBINARY or VARCHAR argument whose bytes are read from a
+// reader when the encoded values are written with WriteTo, instead of being
+// copied into the buffer of the Encoder. Large values are sent without being
+// held in memory twice. The reader must hold at least the given number of
+// bytes, the values can't be sent otherwise.
+type StreamValue struct {
+	r       io.Reader
+	n       int
+	colType int8
+}
+
+// StreamVarbinary returns a VARBINARY argument of n bytes read from r.
+func StreamVarbinary(r io.Reader, n int) StreamValue {
+	return StreamValue{r: r, n: n, colType: VarBinColumn}
+}
+
+// StreamString returns a VARCHAR argument of n bytes of UTF-8 read from r.
+func StreamString(r io.Reader, n int) StreamValue {
+	return StreamValue{r: r, n: n, colType: StringColumn}
+}
+
+// Len returns the number of bytes of the value.
+func (s StreamValue) Len() int {
+	return s.n
+}
+
+// ColType returns the column type the value is sent as.
+func (s StreamValue) ColType() int8 {
+	return s.colType
+}
+
+// stream is a StreamValue whose bytes follow the first off bytes of the
+// buffer of an Encoder.
+type stream struct {
+	off int
+	v   StreamValue
+}
 
 // We are using big endian to encode the values for voltdb wire protocol
 var endian = binary.BigEndian
@@ -44,24 +308,59 @@ var endian = binary.BigEndian
 //
 // Values are encoded in Big Endian byte order mark.
 //
-// To retrieve []byte of the encoded values use Bytes method.
+// To retrieve []byte of the encoded values use Bytes method, or WriteTo when
+// StreamValue arguments were encoded.
 type Encoder struct {
-	buf *bytes.Buffer
-	tmp *bytes.Buffer
+	buf     *bytes.Buffer
+	streams []stream
+
+	// maxStringLen is the maximum length of VARCHAR arguments, 0 stands for
+	// DefaultMaxStringLen and a negative value for no limit.
+	maxStringLen int
+	// mapAsJSON is set when maps are sent as JSON, see SetMapAsJSON.
+	mapAsJSON bool
 }
 
 // NewEncoder returns a new Encoder instance
 func NewEncoder() *Encoder {
-	return &Encoder{buf: &bytes.Buffer{}, tmp: &bytes.Buffer{}}
+	return &Encoder{buf: &bytes.Buffer{}}
+}
+
+// SetMaxStringLen sets the maximum length in bytes of the VARCHAR arguments
+// Marshal encodes, longer strings return a StringLengthError. A negative n
+// disables the check and 0 restores DefaultMaxStringLen. Strings that aren't
+// arguments, such as procedure names, aren't checked.
+func (e *Encoder) SetMaxStringLen(n int) {
+	e.maxStringLen = n
+}
+
+// SetMapAsJSON sets whether Marshal encodes maps with string keys as a VARCHAR
+// holding their JSON encoding. VoltDB has no map type, maps fail to encode
+// when it isn't set so that a map isn't sent by mistake.
+func (e *Encoder) SetMapAsJSON(v bool) {
+	e.mapAsJSON = v
+}
+
+// checkStringLen returns an error when a VARCHAR argument of n bytes is longer
+// than the maximum length.
+func (e *Encoder) checkStringLen(n int) error {
+	max := e.maxStringLen
+	if max == 0 {
+		max = DefaultMaxStringLen
+	}
+	if max > 0 && n > max {
+		return StringLengthError{Len: n, Max: max}
+	}
+	return nil
 }
 
-//Reset resets the underlying buffer. This will remove any values that were
-//encoded before.
+// Reset resets the underlying buffer. This will remove any values that were
+// encoded before.
 //
-//Call this to reuse the Encoder and avoid unnecessary allocations.
+// Call this to reuse the Encoder and avoid unnecessary allocations.
 func (e *Encoder) Reset() {
 	e.buf.Reset()
-	e.tmp.Reset()
+	e.streams = e.streams[:0]
 }
 
 // Len retuns the size of the cueent encoded values
@@ -79,11 +378,37 @@ func (e *Encoder) Byte(v int8) (int, error) {
 	return e.buf.Write(b)
 }
 
-// Bytes returns the buffered voltdb wire protocol encoded bytes
+// Bytes returns the buffered voltdb wire protocol encoded bytes. The bytes of
+// StreamValue arguments aren't buffered, they are left out.
 func (e *Encoder) Bytes() []byte {
 	return e.buf.Bytes()
 }
 
+// WriteTo writes the encoded values to w, the bytes of StreamValue arguments
+// are copied from their readers in between the buffered bytes. It implements
+// io.WriterTo. The streams are consumed, the values can't be written again.
+func (e *Encoder) WriteTo(w io.Writer) (int64, error) {
+	b := e.buf.Bytes()
+	var written int64
+	off := 0
+	for _, s := range e.streams {
+		n, err := w.Write(b[off:s.off])
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+		off = s.off
+		c, err := io.CopyN(w, s.v.r, int64(s.v.n))
+		written += c
+		if err != nil {
+			return written, err
+		}
+	}
+	n, err := w.Write(b[off:])
+	written += int64(n)
+	return written, err
+}
+
 // Int16 encodes int16 value to voltdb wire protocol Short. For a successful
 // encoding the number of bytes written is 2
 func (e *Encoder) Int16(v int16) (int, error) {
@@ -154,18 +479,180 @@ func (e *Encoder) Bool(v bool) (int, error) {
 // like []byte. We first encode the size of the string, followed by the raw
 // bytes of the string.
 func (e *Encoder) String(v string) (int, error) {
-	e.tmp.Reset()
-	e.tmp.WriteString(v)
-	return e.Binary(e.tmp.Bytes())
+	s, err := e.Int32(int32(len(v)))
+	if err != nil {
+		return 0, err
+	}
+	n, err := e.buf.WriteString(v)
+	if err != nil {
+		return 0, err
+	}
+	return s + n, nil
 }
 
 // Time encodes time.Time value to voltdb wire protocol time.
+//
+// VoltDB stores timestamps as microseconds since the epoch in UTC, v is
+// converted to UTC and truncated to the microsecond, any sub-microsecond
+// precision is dropped. The zero time.Time is encoded as a null timestamp.
 func (e *Encoder) Time(v time.Time) (int, error) {
-	nano := v.Round(time.Microsecond).UnixNano()
+	return e.Int64(timestampMicros(v))
+}
+
+// WriteTimestamp writes t to w in the format of TIMESTAMP values, 8 big endian
+// bytes holding the microseconds since the epoch in UTC. Like Encoder.Time, t
+// is truncated to the microsecond, the nanoseconds below are dropped, and the
+// zero time.Time is written as a NULL TIMESTAMP. ReadTimestamp reads it back.
+func WriteTimestamp(w io.Writer, t time.Time) error {
+	var b [LongSize]byte
+	endian.PutUint64(b[:], uint64(timestampMicros(t)))
+	_, err := w.Write(b[:])
+	return err
+}
+
+// timestampMicros returns the microseconds since the epoch of v, or the NULL
+// TIMESTAMP for the zero time.Time.
+func timestampMicros(v time.Time) int64 {
 	if v.IsZero() {
-		return e.Int64(math.MinInt64)
+		return math.MinInt64
+	}
+	v = v.UTC()
+	return v.Unix()*1e6 + int64(v.Nanosecond()/1e3)
+}
+
+// Decimal encodes v as the 16 bytes two's complement big endian integer
+// v * 10^12, the format of DECIMAL values. An error is returned when v can not
+// be represented without loss. A nil v is encoded as NULL, the smallest 16
+// bytes integer.
+func (e *Encoder) Decimal(v *big.Rat) (int, error) {
+	if v == nil {
+		var null [DecimalSize]byte
+		null[0] = 0x80
+		return e.buf.Write(null[:])
+	}
+	scaled := new(big.Rat).Mul(v, new(big.Rat).SetInt(decimalScaleFactor))
+	if !scaled.IsInt() {
+		return 0, errDecimalScale
+	}
+	i := new(big.Int).Set(scaled.Num())
+	if new(big.Int).Abs(i).Cmp(decimalMaxUnscaled) >= 0 {
+		return 0, errDecimalOverflow
+	}
+	if i.Sign() < 0 {
+		i.Add(i, decimalModulus)
+	}
+	b := make([]byte, DecimalSize)
+	v0 := i.Bytes()
+	copy(b[DecimalSize-len(v0):], v0)
+	return e.buf.Write(b)
+}
+
+// GeographyPoint encodes v as its longitude followed by its latitude. Both
+// coordinates are validated before anything is written.
+func (e *Encoder) GeographyPoint(v GeographyPoint) (int, error) {
+	if v.Longitude < -180 || v.Longitude > 180 {
+		return 0, errLongitude
+	}
+	if v.Latitude < -90 || v.Latitude > 90 {
+		return 0, errLatitude
+	}
+	return e.geographyPoint(v)
+}
+
+func (e *Encoder) geographyPoint(v GeographyPoint) (int, error) {
+	n, err := e.Float64(v.Longitude)
+	if err != nil {
+		return 0, err
+	}
+	i, err := e.Float64(v.Latitude)
+	if err != nil {
+		return 0, err
+	}
+	return n + i, nil
+}
+
+// Geography encodes v in the format VoltDB uses to store GEOGRAPHY values, the
+// encoded polygon is prefixed with its size like varbinary values.
+//
+// Points are sent as coordinates (x, y, z) on the unit sphere. The closing
+// point of each ring is not sent, and inner rings are sent in reverse order so
+// that all rings have the same orientation.
+func (e *Encoder) Geography(v GeographyPolygon) (int, error) {
+	rings := make([][]GeographyPoint, 0, len(v.InnerRings)+1)
+	rings = append(rings, v.OuterRing)
+	rings = append(rings, v.InnerRings...)
+	size := polygonHeaderSize + boundSize
+	for _, r := range rings {
+		if err := validateRing(r); err != nil {
+			return 0, err
+		}
+		size += ringHeaderSize + (len(r)-1)*vertexSize + ringTrailerSize + boundSize
+	}
+	n, err := e.Int32(int32(size))
+	if err != nil {
+		return 0, err
+	}
+	e.buf.Write([]byte{0, 0, 0})
+	e.Int32(int32(len(rings)))
+	for i, r := range rings {
+		e.Byte(0)
+		e.Int32(int32(len(r) - 1))
+		e.vertex(r[0])
+		if i == 0 {
+			for j := 1; j < len(r)-1; j++ {
+				e.vertex(r[j])
+			}
+		} else {
+			for j := len(r) - 2; j > 0; j-- {
+				e.vertex(r[j])
+			}
+		}
+		e.Byte(0)
+		e.Int32(0)
+		e.emptyBound()
+	}
+	e.emptyBound()
+	return n + size, nil
+}
+
+func validateRing(r []GeographyPoint) error {
+	if len(r) == 0 {
+		return errRingEmpty
+	}
+	if len(r) < 4 {
+		return errRingTooShort
 	}
-	return e.Int64(nano / int64(time.Microsecond))
+	if r[0] != r[len(r)-1] {
+		return errRingNotClosed
+	}
+	for _, p := range r {
+		if p.Longitude < -180 || p.Longitude > 180 {
+			return errLongitude
+		}
+		if p.Latitude < -90 || p.Latitude > 90 {
+			return errLatitude
+		}
+	}
+	return nil
+}
+
+// vertex encodes p as a point on the unit sphere.
+func (e *Encoder) vertex(p GeographyPoint) {
+	lat := p.Latitude * math.Pi / 180
+	lng := p.Longitude * math.Pi / 180
+	cosLat := math.Cos(lat)
+	e.Float64(math.Cos(lng) * cosLat)
+	e.Float64(math.Sin(lng) * cosLat)
+	e.Float64(math.Sin(lat))
+}
+
+// emptyBound encodes an empty latitude/longitude rectangle.
+func (e *Encoder) emptyBound() {
+	e.Byte(0)
+	e.Float64(1)
+	e.Float64(0)
+	e.Float64(math.Pi)
+	e.Float64(-math.Pi)
 }
 
 // Write implements io.Writer interface
@@ -179,7 +666,8 @@ func (e *Encoder) Read(b []byte) (int, error) {
 }
 
 // Marshal encodes query arguments, these are values passed as driver.Value when
-// executing queries
+// executing queries. Values of types that can't be sent to VoltDB return an
+// error, nothing is written for them.
 func (e *Encoder) Marshal(v interface{}) (int, error) {
 	switch x := v.(type) {
 	case bool:
@@ -192,24 +680,127 @@ func (e *Encoder) Marshal(v interface{}) (int, error) {
 		return e.MarshalInt32(x)
 	case int64:
 		return e.MarshalInt64(x)
+	case int:
+		return e.MarshalInt64(int64(x))
+	case uint8:
+		return e.MarshalShort(int16(x))
+	case uint16:
+		return e.MarshalInt32(int32(x))
+	case uint32:
+		return e.MarshalInt64(int64(x))
+	case uint64:
+		return e.MarshalUint64(x)
+	case uint:
+		return e.MarshalUint64(uint64(x))
+	case float32:
+		// VoltDB has a single 8 byte FLOAT type.
+		return e.MarshalFloat64(float64(x))
 	case float64:
 		return e.MarshalFloat64(x)
 	case string:
 		return e.MarshalString(x)
 	case time.Time:
 		return e.MarshalTime(x)
+	case *big.Rat:
+		if x == nil {
+			return e.MarshalNull(DecimalColumn)
+		}
+		return e.MarshalDecimal(x)
+	case big.Rat:
+		return e.MarshalDecimal(&x)
+	case VoltDecimal:
+		if x.Unscaled == nil {
+			return e.MarshalNull(DecimalColumn)
+		}
+		return e.MarshalDecimal(x.Rat())
+	case GeographyPoint:
+		return e.MarshalGeographyPoint(x)
+	case GeographyPolygon:
+		return e.MarshalGeography(x)
+	case NullValue:
+		return e.MarshalNull(x.ColType())
+	case FixedVarbinary:
+		return e.MarshalFixedVarbinary(x)
+	case StreamValue:
+		return e.MarshalStream(x)
+	case sql.NullBool, sql.NullByte, sql.NullInt16, sql.NullInt32, sql.NullInt64,
+		sql.NullFloat64, sql.NullString, sql.NullTime:
+		return e.marshalSQLNull(x)
 	default:
 		rv := reflect.ValueOf(v)
 		switch rv.Kind() {
 		case reflect.Slice:
 			return e.MarshalSlice(rv)
+		case reflect.Map:
+			return e.MarshalMap(rv)
 		case reflect.Ptr:
+			if rv.IsNil() {
+				colType, err := nullColumnType(rv.Type().Elem())
+				if err != nil {
+					return 0, err
+				}
+				return e.MarshalNull(colType)
+			}
 			return e.Marshal(rv.Elem().Interface())
 		}
 		return 0, errUnknownParam
 	}
 }
 
+// marshalSQLNull encodes the database/sql Null types, their value is sent like
+// the value of the field it wraps and invalid ones are sent as a NULL of the
+// same column type.
+func (e *Encoder) marshalSQLNull(v interface{}) (int, error) {
+	switch x := v.(type) {
+	case sql.NullBool:
+		if x.Valid {
+			return e.MarshalBool(x.Bool)
+		}
+	case sql.NullByte:
+		if x.Valid {
+			return e.MarshalShort(int16(x.Byte))
+		}
+	case sql.NullInt16:
+		if x.Valid {
+			return e.MarshalShort(x.Int16)
+		}
+	case sql.NullInt32:
+		if x.Valid {
+			return e.MarshalInt32(x.Int32)
+		}
+	case sql.NullInt64:
+		if x.Valid {
+			return e.MarshalInt64(x.Int64)
+		}
+	case sql.NullFloat64:
+		if x.Valid {
+			return e.MarshalFloat64(x.Float64)
+		}
+	case sql.NullString:
+		if x.Valid {
+			return e.MarshalString(x.String)
+		}
+	case sql.NullTime:
+		if x.Valid {
+			return e.MarshalTime(x.Time)
+		}
+	}
+	colType, err := nullColumnType(reflect.TypeOf(v))
+	if err != nil {
+		return 0, err
+	}
+	return e.MarshalNull(colType)
+}
+
+// MarshalUint64 encodes uint64 argument as a BIGINT, VoltDB has no unsigned
+// types so values above math.MaxInt64 can't be sent.
+func (e *Encoder) MarshalUint64(v uint64) (int, error) {
+	if v > math.MaxInt64 {
+		return 0, errUnsignedRange
+	}
+	return e.MarshalInt64(int64(v))
+}
+
 // MarshalBool encodes boolean argument
 func (e *Encoder) MarshalBool(v bool) (int, error) {
 	n, err := e.Byte(BoolColumn)
@@ -223,9 +814,9 @@ func (e *Encoder) MarshalBool(v bool) (int, error) {
 	return n + i, nil
 }
 
-// MarshalByte encodes int8 argument
+// MarshalByte encodes int8 argument as a TINYINT
 func (e *Encoder) MarshalByte(v int8) (int, error) {
-	n, err := e.Byte(ByteColumn)
+	n, err := e.Byte(TinyIntColumn)
 	if err != nil {
 		return 0, err
 	}
@@ -287,6 +878,9 @@ func (e *Encoder) MarshalFloat64(v float64) (int, error) {
 
 // MarshalString encodes string argument
 func (e *Encoder) MarshalString(v string) (int, error) {
+	if err := e.checkStringLen(len(v)); err != nil {
+		return 0, err
+	}
 	n, err := e.Byte(StringColumn)
 	if err != nil {
 		return 0, err
@@ -298,10 +892,31 @@ func (e *Encoder) MarshalString(v string) (int, error) {
 	return n + i, nil
 }
 
-// MarshalSlice encodes slice of arguments
+// MarshalDecimal encodes a DECIMAL argument. VoltDB decimals have a fixed
+// scale of 12 and a precision of 38 digits, an error is returned if v needs
+// more fractional digits or is too large to fit.
+func (e *Encoder) MarshalDecimal(v *big.Rat) (int, error) {
+	n, err := e.Byte(DecimalColumn)
+	if err != nil {
+		return 0, err
+	}
+	i, err := e.Decimal(v)
+	if err != nil {
+		// take back the column type, nothing is written for a value that
+		// doesn't fit.
+		e.buf.Truncate(e.buf.Len() - n)
+		return 0, err
+	}
+	return n + i, nil
+}
+
+// MarshalSlice encodes slice of arguments. A []byte is sent as a VARBINARY,
+// other slices as an array. The element type of an array is derived from the
+// type of the slice, so empty arrays can be sent too. Arrays with NULL
+// elements are sent from slices of pointers or of the database/sql Null types,
+// such as a []*string or a []sql.NullString for the IN list of VARCHARs.
 func (e *Encoder) MarshalSlice(v reflect.Value) (int, error) {
-	switch v.Type().Elem().Kind() {
-	case reflect.Uint8:
+	if v.Type().Elem().Kind() == reflect.Uint8 {
 		n, err := e.Byte(VarBinColumn)
 		if err != nil {
 			return 0, err
@@ -311,26 +926,273 @@ func (e *Encoder) MarshalSlice(v reflect.Value) (int, error) {
 			return 0, err
 		}
 		return n + i, nil
-	default:
-		n, err := e.Byte(ArrayColumn)
-		if err != nil {
+	}
+	return e.marshalArray(v)
+}
+
+// scratchEncoders holds the encoders the elements of arrays are encoded with
+// before being copied, they are reused across calls to cut allocations.
+var scratchEncoders = sync.Pool{
+	New: func() interface{} { return NewEncoder() },
+}
+
+// marshalArray encodes a slice as an array, the element type follows the array
+// type once and is followed by the element count and the values of the
+// elements without their type. Nothing is written when an element can't be
+// encoded.
+func (e *Encoder) marshalArray(v reflect.Value) (int, error) {
+	elemType, err := nullColumnType(v.Type().Elem())
+	if err != nil {
+		return 0, err
+	}
+	start := e.buf.Len()
+	n, err := e.Byte(ArrayColumn)
+	if err != nil {
+		e.buf.Truncate(start)
+		return 0, err
+	}
+	t, err := e.Byte(elemType)
+	if err != nil {
+		e.buf.Truncate(start)
+		return 0, err
+	}
+	l := v.Len()
+	s, err := e.Int16(int16(l))
+	if err != nil {
+		e.buf.Truncate(start)
+		return 0, err
+	}
+	size := n + t + s
+	elem := scratchEncoders.Get().(*Encoder)
+	defer scratchEncoders.Put(elem)
+	elem.maxStringLen = e.maxStringLen
+	elem.mapAsJSON = e.mapAsJSON
+	for i := 0; i < l; i++ {
+		elem.Reset()
+		if _, err := elem.Marshal(v.Index(i).Interface()); err != nil {
+			e.buf.Truncate(start)
 			return 0, err
 		}
-		l := v.Len()
-		s, err := e.Int16(int16(l))
+		b := elem.Bytes()
+		if int8(b[0]) != elemType {
+			e.buf.Truncate(start)
+			return 0, errArrayElemType
+		}
+		c, err := e.Write(b[1:])
 		if err != nil {
+			e.buf.Truncate(start)
 			return 0, err
 		}
-		size := n + s
-		for i := 0; i < l; i++ {
-			c, err := e.Marshal(v.Index(i).Interface())
-			if err != nil {
-				return 0, err
-			}
-			size += c
+		size += c
+	}
+	return size, nil
+}
+
+// MarshalMap encodes a map with string keys, such as a map[string]interface{},
+// as a VARCHAR holding the JSON encoding of the map, a nil map is sent as a
+// NULL VARCHAR. VoltDB has no map type, procedures taking such a parameter
+// receive it as a String they can read with the JSON functions of VoltDB. Maps
+// are only encoded once SetMapAsJSON is set, an error is returned otherwise.
+func (e *Encoder) MarshalMap(v reflect.Value) (int, error) {
+	if !e.mapAsJSON {
+		return 0, errMapAsJSON
+	}
+	if v.Type().Key().Kind() != reflect.String {
+		return 0, errMapKey
+	}
+	if v.IsNil() {
+		return e.MarshalNull(StringColumn)
+	}
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return 0, err
+	}
+	if err := e.checkStringLen(len(b)); err != nil {
+		return 0, err
+	}
+	n, err := e.Byte(StringColumn)
+	if err != nil {
+		return 0, err
+	}
+	i, err := e.Binary(b)
+	if err != nil {
+		return 0, err
+	}
+	return n + i, nil
+}
+
+// MarshalFixedVarbinary encodes a VARBINARY argument that must be exactly
+// v.Width bytes long.
+func (e *Encoder) MarshalFixedVarbinary(v FixedVarbinary) (int, error) {
+	if len(v.Bytes) != v.Width {
+		return 0, errVarbinaryWidth
+	}
+	n, err := e.Byte(VarBinColumn)
+	if err != nil {
+		return 0, err
+	}
+	i, err := e.Binary(v.Bytes)
+	if err != nil {
+		return 0, err
+	}
+	return n + i, nil
+}
+
+// MarshalStream encodes a StreamValue argument. Only its type and length are
+// buffered, the returned length includes the bytes WriteTo copies from its
+// reader.
+func (e *Encoder) MarshalStream(v StreamValue) (int, error) {
+	if v.n < 0 || v.n > math.MaxInt32 {
+		return 0, errStreamLength
+	}
+	if v.colType == StringColumn {
+		if err := e.checkStringLen(v.n); err != nil {
+			return 0, err
 		}
-		return size, nil
 	}
+	n, err := e.Byte(v.colType)
+	if err != nil {
+		return 0, err
+	}
+	i, err := e.Int32(int32(v.n))
+	if err != nil {
+		return 0, err
+	}
+	e.streams = append(e.streams, stream{off: e.buf.Len(), v: v})
+	return n + i + v.n, nil
+}
+
+// MarshalGeographyPoint encodes a GEOGRAPHY_POINT argument
+func (e *Encoder) MarshalGeographyPoint(v GeographyPoint) (int, error) {
+	n, err := e.Byte(GeographyPointColumn)
+	if err != nil {
+		return 0, err
+	}
+	i, err := e.GeographyPoint(v)
+	if err != nil {
+		// take back the column type, nothing is written for an invalid
+		// point.
+		e.buf.Truncate(e.buf.Len() - n)
+		return 0, err
+	}
+	return n + i, nil
+}
+
+// MarshalGeography encodes a GEOGRAPHY argument
+func (e *Encoder) MarshalGeography(v GeographyPolygon) (int, error) {
+	n, err := e.Byte(GeographyColumn)
+	if err != nil {
+		return 0, err
+	}
+	i, err := e.Geography(v)
+	if err != nil {
+		// take back the column type, nothing is written for an invalid
+		// polygon.
+		e.buf.Truncate(e.buf.Len() - n)
+		return 0, err
+	}
+	return n + i, nil
+}
+
+// MarshalNull encodes a NULL argument for a column of type colType. VoltDB
+// represents NULL with a reserved value of each type.
+func (e *Encoder) MarshalNull(colType int8) (int, error) {
+	n, err := e.Byte(colType)
+	if err != nil {
+		return 0, err
+	}
+	var i int
+	switch colType {
+	case TinyIntColumn:
+		i, err = e.Byte(math.MinInt8)
+	case ShortColumn:
+		i, err = e.Int16(math.MinInt16)
+	case IntColumn:
+		i, err = e.Int32(math.MinInt32)
+	case LongColumn, TimestampColumn:
+		i, err = e.Int64(math.MinInt64)
+	case FloatColumn:
+		i, err = e.Float64(-1.7e+308)
+	case StringColumn, VarBinColumn, GeographyColumn:
+		i, err = e.Int32(-1)
+	case DecimalColumn:
+		b := make([]byte, DecimalSize)
+		b[0] = 0x80
+		i, err = e.buf.Write(b)
+	case GeographyPointColumn:
+		i, err = e.geographyPoint(GeographyPoint{Longitude: nullCoord, Latitude: nullCoord})
+	default:
+		// take back the column type, nothing is written for an error.
+		e.buf.Truncate(e.buf.Len() - n)
+		return 0, errUnknownParam
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n + i, nil
+}
+
+// nullColumnType returns the column type that a nil pointer to a value of type t
+// is sent as, it is the column type Marshal uses for the value itself.
+func nullColumnType(t reflect.Type) (int8, error) {
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return TimestampColumn, nil
+	case reflect.TypeOf(big.Rat{}), reflect.TypeOf(VoltDecimal{}):
+		return DecimalColumn, nil
+	case reflect.TypeOf(GeographyPoint{}):
+		return GeographyPointColumn, nil
+	case reflect.TypeOf(GeographyPolygon{}):
+		return GeographyColumn, nil
+	case reflect.TypeOf(FixedVarbinary{}):
+		return VarBinColumn, nil
+	case reflect.TypeOf(sql.NullBool{}):
+		return TinyIntColumn, nil
+	case reflect.TypeOf(sql.NullByte{}), reflect.TypeOf(sql.NullInt16{}):
+		return ShortColumn, nil
+	case reflect.TypeOf(sql.NullInt32{}):
+		return IntColumn, nil
+	case reflect.TypeOf(sql.NullInt64{}):
+		return LongColumn, nil
+	case reflect.TypeOf(sql.NullFloat64{}):
+		return FloatColumn, nil
+	case reflect.TypeOf(sql.NullString{}):
+		return StringColumn, nil
+	case reflect.TypeOf(sql.NullTime{}):
+		return TimestampColumn, nil
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int8:
+		return TinyIntColumn, nil
+	case reflect.Int16, reflect.Uint8:
+		return ShortColumn, nil
+	case reflect.Int32, reflect.Uint16:
+		return IntColumn, nil
+	case reflect.Int64, reflect.Int, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		return LongColumn, nil
+	case reflect.Float32, reflect.Float64:
+		return FloatColumn, nil
+	case reflect.String:
+		return StringColumn, nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return VarBinColumn, nil
+		}
+	case reflect.Map:
+		if t.Key().Kind() == reflect.String {
+			return StringColumn, nil
+		}
+	case reflect.Ptr:
+		return nullColumnType(t.Elem())
+	}
+	return 0, errUnknownParam
+}
+
+// ColumnType returns the column type that values of type t are sent as by
+// Marshal. Slices other than []byte are sent as arrays, they have no column
+// type of their own and return an error like other unsupported types.
+func ColumnType(t reflect.Type) (int8, error) {
+	return nullColumnType(t)
 }
 
 // MarshalTime encodes time.Time argument
@@ -361,69 +1223,152 @@ func (e *Encoder) Args(v []driver.Value) error {
 	return nil
 }
 
-//Login encodes login details. This supports both version 0 and 1 of the wire
-//protocol.
+// Login encodes login details. This supports both version 0 and 1 of the wire
+// protocol. Both versions carry the service name, servers read it before the
+// username regardless of the version.
 //
-//The password is hashed using sha1 and sha356 for version 0 and 1 respectively.
+// The password is hashed using sha1 and sha256 for version 0 and 1 respectively,
+// use EncodeLogin to pick another HashScheme.
 //
 // For instance if the username is foo and password is bar,  the login message
 // will be encoded as follows
 //
 // version 0
-// 	+------------------+--------------+-----------------------+----------+--------------------------------------+
-// 	| protocol version | service name | password hash version | username | password                             |
-// 	+------------------+--------------+-----------------------+----------+--------------------------------------+
-// 	| 0                | database     | 0                     | foo      | sha1 encoded raw bytes of string bar |
-// 	+------------------+--------------+-----------------------+----------+--------------------------------------+
+//
+//	+------------------+--------------+-----------------------+----------+--------------------------------------+
+//	| protocol version | service name | password hash version | username | password                             |
+//	+------------------+--------------+-----------------------+----------+--------------------------------------+
+//	| 0                | database     | 0                     | foo      | sha1 encoded raw bytes of string bar |
+//	+------------------+--------------+-----------------------+----------+--------------------------------------+
 //
 // version 1
-// 	+------------------+--------------+-----------------------+----------+----------------------------------------+
-// 	| protocol version | service name | password hash version | username | password                               |
-// 	+------------------+--------------+-----------------------+----------+----------------------------------------+
-// 	| 1                | database     | 1                     | foo      | sha256 encoded raw bytes of string bar |
-// 	+------------------+--------------+-----------------------+----------+----------------------------------------+
+//
+//	+------------------+--------------+-----------------------+----------+----------------------------------------+
+//	| protocol version | service name | password hash version | username | password                               |
+//	+------------------+--------------+-----------------------+----------+----------------------------------------+
+//	| 1                | database     | 1                     | foo      | sha256 encoded raw bytes of string bar |
+//	+------------------+--------------+-----------------------+----------+----------------------------------------+
 func (e *Encoder) Login(version int, user, password string) ([]byte, error) {
-	var h hash.Hash
-	_, err := e.Byte(int8(version))
+	return e.EncodeLogin(LoginRequest{
+		Version:  version,
+		Scheme:   DefaultHashScheme(version),
+		User:     user,
+		Password: password,
+	})
+}
+
+// HashScheme is the algorithm the password is hashed with in the login
+// message, its value is the password hash version sent to the server.
+type HashScheme int8
+
+// The password hash schemes supported by VoltDB.
+const (
+	HashSHA1   HashScheme = 0
+	HashSHA256 HashScheme = 1
+)
+
+// DefaultHashScheme returns the scheme servers expect for the given protocol
+// version, sha1 for version 0 and sha256 for later versions.
+func DefaultHashScheme(version int) HashScheme {
+	if version == 0 {
+		return HashSHA1
+	}
+	return HashSHA256
+}
+
+func (s HashScheme) size() int {
+	switch s {
+	case HashSHA1:
+		return sha1.Size
+	case HashSHA256:
+		return sha256.Size
+	}
+	return 0
+}
+
+func (s HashScheme) hash() (hash.Hash, error) {
+	switch s {
+	case HashSHA1:
+		return sha1.New(), nil
+	case HashSHA256:
+		return sha256.New(), nil
+	}
+	return nil, errHashScheme
+}
+
+// DefaultService is the service clients log in to, the "export" service is
+// used by export clients. The server answers the login to either service with
+// the same response, LoginInfo decodes both.
+const DefaultService = "database"
+
+// LoginRequest holds the details sent to the server to log in. DefaultService
+// is used when Service is empty.
+//
+// PasswordHash, when set, is the precomputed digest of the password and is
+// sent as is instead of hashing Password. Its size must match Scheme.
+type LoginRequest struct {
+	Version      int
+	Scheme       HashScheme
+	Service      string
+	User         string
+	Password     string
+	PasswordHash []byte
+}
+
+// EncodeLogin encodes the login message for r, see Login for its layout.
+func (e *Encoder) EncodeLogin(r LoginRequest) ([]byte, error) {
+	digest, err := r.digest()
 	if err != nil {
 		return nil, err
 	}
-	if version == 0 {
-		h = sha1.New()
-		//password hash version
-		_, err = e.Byte(0)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		h = sha256.New()
-
-		//password hash version
-		_, err = e.Byte(1)
-		if err != nil {
-			return nil, err
-		}
+	_, err = e.Byte(int8(r.Version))
+	if err != nil {
+		return nil, err
 	}
-	_, err = h.Write([]byte(password))
+	//password hash version
+	_, err = e.Byte(int8(r.Scheme))
 	if err != nil {
 		return nil, err
 	}
-	_, err = e.String("database")
+	service := r.Service
+	if service == "" {
+		service = DefaultService
+	}
+	_, err = e.String(service)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = e.String(user)
+	_, err = e.String(r.User)
 	if err != nil {
 		return nil, err
 	}
-	_, err = e.Write(h.Sum(nil))
+	_, err = e.Write(digest)
 	if err != nil {
 		return nil, err
 	}
 	return e.Message(e.Bytes()), nil
 }
 
+// digest returns the password digest sent in the login message, either the
+// provided PasswordHash or Password hashed with Scheme.
+func (r LoginRequest) digest() ([]byte, error) {
+	h, err := r.Scheme.hash()
+	if err != nil {
+		return nil, err
+	}
+	if r.PasswordHash != nil {
+		if len(r.PasswordHash) != r.Scheme.size() {
+			return nil, errPasswordHashSize
+		}
+		return r.PasswordHash, nil
+	}
+	if _, err = h.Write([]byte(r.Password)); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
 // Message encodes v into a voldb wire protocol. voltdb wire protocol message
 // comprizes of int32 encoded size of v followed by v raw bytes.
 func (e *Encoder) Message(v []byte) []byte {
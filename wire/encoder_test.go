@@ -2,7 +2,15 @@ package wire
 
 import (
 	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"io"
 	"io/ioutil"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -125,6 +133,75 @@ func TestEncoder_Float64(t *testing.T) {
 	}
 }
 
+func TestEncoder_MarshalUnsigned(t *testing.T) {
+	sample := []struct {
+		v       interface{}
+		colType int8
+		size    int
+	}{
+		{uint8(math.MaxUint8), ShortColumn, ShortSize},
+		{uint16(math.MaxUint16), IntColumn, IntegerSize},
+		{uint32(math.MaxUint32), LongColumn, LongSize},
+		{uint64(math.MaxInt64), LongColumn, LongSize},
+		{uint(math.MaxInt64), LongColumn, LongSize},
+	}
+	e := NewEncoder()
+	for _, v := range sample {
+		e.Reset()
+		n, err := e.Marshal(v.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1+v.size {
+			t.Errorf("%T: expected %d got %d", v.v, 1+v.size, n)
+		}
+		b := e.Bytes()
+		if int8(b[0]) != v.colType {
+			t.Errorf("%T: expected %d got %d", v.v, v.colType, b[0])
+		}
+		d := NewDecoder(bytes.NewReader(b[1:]))
+		var got uint64
+		switch v.size {
+		case ShortSize:
+			i, _ := d.Int16()
+			got = uint64(i)
+		case IntegerSize:
+			i, _ := d.Int32()
+			got = uint64(i)
+		case LongSize:
+			i, _ := d.Int64()
+			got = uint64(i)
+		}
+		if got != reflect.ValueOf(v.v).Uint() {
+			t.Errorf("%T: expected %v got %v", v.v, v.v, got)
+		}
+	}
+
+	for _, v := range []interface{}{uint64(math.MaxInt64 + 1), uint(math.MaxInt64 + 1)} {
+		e.Reset()
+		_, err := e.Marshal(v)
+		if err != errUnsignedRange {
+			t.Errorf("%T: expected %v got %v", v, errUnsignedRange, err)
+		}
+	}
+}
+
+func TestEncoder_MarshalFloat32(t *testing.T) {
+	e := NewEncoder()
+	n, err := e.Marshal(float32(1.5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1+LongSize {
+		t.Errorf("expected %d got %d", 1+LongSize, n)
+	}
+	f := NewEncoder()
+	f.Marshal(float64(1.5))
+	if !bytes.Equal(e.Bytes(), f.Bytes()) {
+		t.Errorf("expected %v got %v", f.Bytes(), e.Bytes())
+	}
+}
+
 func TestEncoder_String(t *testing.T) {
 	t.Parallel()
 	expected := []byte{0x00, 0x00, 0x00, 0x06, 'a', 'b', 'c', 'd', 'e', 'f'}
@@ -210,6 +287,98 @@ func TestEncoder_Time(t *testing.T) {
 	}
 }
 
+func TestEncoder_TimeZone(t *testing.T) {
+	zone := time.FixedZone("UTC+5", 5*60*60)
+	sample := []struct {
+		v      time.Time
+		micros int64
+	}{
+		// 2017-03-01 12:00:00.000001999 UTC
+		{time.Date(2017, 3, 1, 17, 0, 0, 1999, zone), 1488369600000001},
+		{time.Date(2017, 3, 1, 12, 0, 0, 1999, time.UTC), 1488369600000001},
+		// one nanosecond before the epoch
+		{time.Date(1970, 1, 1, 4, 59, 59, 999999999, zone), -1},
+	}
+	e := NewEncoder()
+	for _, v := range sample {
+		e.Reset()
+		if _, err := e.Time(v.v); err != nil {
+			t.Fatal(err)
+		}
+		micros, err := NewDecoder(e).Int64()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if micros != v.micros {
+			t.Errorf("%v: expected %d got %d", v.v, v.micros, micros)
+		}
+	}
+}
+
+func TestWriteTimestamp(t *testing.T) {
+	zone := time.FixedZone("UTC+5", 5*60*60)
+	sample := []struct {
+		v   time.Time
+		exp time.Time
+	}{
+		{time.Date(2017, 3, 1, 12, 0, 0, 1000, time.UTC), time.Date(2017, 3, 1, 12, 0, 0, 1000, time.UTC)},
+		{time.Date(2017, 3, 1, 17, 0, 0, 1000, zone), time.Date(2017, 3, 1, 12, 0, 0, 1000, time.UTC)},
+		// the nanoseconds below the microsecond are truncated.
+		{time.Date(2017, 3, 1, 12, 0, 0, 1999, time.UTC), time.Date(2017, 3, 1, 12, 0, 0, 1000, time.UTC)},
+		{time.Date(1969, 12, 31, 23, 59, 59, 999999000, time.UTC), time.Date(1969, 12, 31, 23, 59, 59, 999999000, time.UTC)},
+		{time.Time{}, time.Time{}},
+	}
+	for _, v := range sample {
+		var buf bytes.Buffer
+		if err := WriteTimestamp(&buf, v.v); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() != LongSize {
+			t.Errorf("%v: expected %d bytes got %d", v.v, LongSize, buf.Len())
+		}
+		e := NewEncoder()
+		e.Time(v.v)
+		if !bytes.Equal(buf.Bytes(), e.Bytes()) {
+			t.Errorf("%v: expected %v got %v", v.v, e.Bytes(), buf.Bytes())
+		}
+		got, err := ReadTimestamp(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(v.exp) || got.Location() != v.exp.Location() {
+			t.Errorf("%v: expected %v got %v", v.v, v.exp, got)
+		}
+	}
+	if _, err := ReadTimestamp(bytes.NewReader([]byte{0, 1, 2})); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected %v got %v", io.ErrUnexpectedEOF, err)
+	}
+}
+
+func TestEncoder_MarshalByte(t *testing.T) {
+	e := NewEncoder()
+	n, err := e.Marshal(int8(-7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected %d got %d", 2, n)
+	}
+	// VoltDB has no BOOLEAN type, TINYINT is type 3 on the wire.
+	exp := []byte{3, 0xf9}
+	if !bytes.Equal(e.Bytes(), exp) {
+		t.Errorf("expected %v got %v", exp, e.Bytes())
+	}
+
+	e.Reset()
+	if _, err := e.Marshal(true); err != nil {
+		t.Fatal(err)
+	}
+	exp = []byte{3, 1}
+	if !bytes.Equal(e.Bytes(), exp) {
+		t.Errorf("expected %v got %v", exp, e.Bytes())
+	}
+}
+
 func TestEncoder_PtrParam(t *testing.T) {
 	f := 451.0
 	e := NewEncoder()
@@ -240,6 +409,67 @@ func TestEncoder_PtrParam(t *testing.T) {
 	}
 }
 
+func TestEncoder_NilPtrParam(t *testing.T) {
+	var s *string
+	e := NewEncoder()
+	n, err := e.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := NewEncoder()
+	f.MarshalNull(StringColumn)
+	if n != f.Len() {
+		t.Errorf("expected %d got %d", f.Len(), n)
+	}
+	if !bytes.Equal(e.Bytes(), f.Bytes()) {
+		t.Errorf("expected %v got %v", f.Bytes(), e.Bytes())
+	}
+
+	sample := []struct {
+		v       interface{}
+		colType int8
+	}{
+		{(*bool)(nil), TinyIntColumn},
+		{(*int)(nil), LongColumn},
+		{(*uint16)(nil), IntColumn},
+		{(*float32)(nil), FloatColumn},
+		{(*[]byte)(nil), VarBinColumn},
+		{(*time.Time)(nil), TimestampColumn},
+		{(*big.Rat)(nil), DecimalColumn},
+		{(*GeographyPoint)(nil), GeographyPointColumn},
+		{(**int16)(nil), ShortColumn},
+	}
+	for _, v := range sample {
+		e.Reset()
+		f.Reset()
+		if _, err := e.Marshal(v.v); err != nil {
+			t.Fatalf("%T: %v", v.v, err)
+		}
+		f.MarshalNull(v.colType)
+		if !bytes.Equal(e.Bytes(), f.Bytes()) {
+			t.Errorf("%T: expected %v got %v", v.v, f.Bytes(), e.Bytes())
+		}
+	}
+
+	e.Reset()
+	if _, err := e.Marshal((*struct{})(nil)); err != errUnknownParam {
+		t.Errorf("expected %v got %v", errUnknownParam, err)
+	}
+}
+
+func TestEncoder_Int64PtrParam(t *testing.T) {
+	i := int64(42)
+	e := NewEncoder()
+	if _, err := e.Marshal(&i); err != nil {
+		t.Fatal(err)
+	}
+	f := NewEncoder()
+	f.MarshalInt64(i)
+	if !bytes.Equal(e.Bytes(), f.Bytes()) {
+		t.Errorf("expected %v got %v", f.Bytes(), e.Bytes())
+	}
+}
+
 func TestEncoder_IntArrayParam(t *testing.T) {
 	array := []int32{11, 12, 13}
 	e := NewEncoder()
@@ -247,7 +477,7 @@ func TestEncoder_IntArrayParam(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	expLen := 18
+	expLen := 16
 	if e.Len() != expLen {
 		t.Fatalf("expected %d got %d", expLen, e.Len())
 	}
@@ -263,6 +493,15 @@ func TestEncoder_IntArrayParam(t *testing.T) {
 	}
 	offset++
 
+	v, err = a.ByteAt(offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int8(v) != IntColumn {
+		t.Errorf("expected %v got %v", IntColumn, v)
+	}
+	offset++
+
 	i, err := a.Int16At(offset)
 	if err != nil {
 		t.Fatal(err)
@@ -272,15 +511,6 @@ func TestEncoder_IntArrayParam(t *testing.T) {
 	}
 	offset += 2
 	for _, exp := range array {
-		v, err = a.ByteAt(offset)
-		if err != nil {
-			t.Fatal(err)
-		}
-		if int8(v) != IntColumn {
-			t.Errorf("expected %v got %v", IntColumn, v)
-		}
-		offset++
-
 		iv, err := a.Int32At(offset)
 		if err != nil {
 			t.Fatal(err)
@@ -305,7 +535,7 @@ func TestEncoder_StringSliceParam(t *testing.T) {
 		"sixteen", "seventeen",
 		"eighteen", "nineteen",
 	}
-	expLen := 213
+	expLen := 194
 	e := NewEncoder()
 	_, err := e.Marshal(array)
 	if err != nil {
@@ -326,6 +556,15 @@ func TestEncoder_StringSliceParam(t *testing.T) {
 	}
 	offset++
 
+	v, err = a.ByteAt(offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int8(v) != StringColumn {
+		t.Errorf("expected %v got %v", StringColumn, v)
+	}
+	offset++
+
 	i, err := a.Int16At(offset)
 	if err != nil {
 		t.Fatal(err)
@@ -335,29 +574,60 @@ func TestEncoder_StringSliceParam(t *testing.T) {
 	}
 	offset += 2
 	for _, exp := range array {
-		v, err = a.ByteAt(offset)
+		iv, err := a.StringAt(offset)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if int8(v) != StringColumn {
-			t.Errorf("expected %v got %v", StringColumn, v)
+		if iv != exp {
+			t.Errorf("expected %v got %v", exp, iv)
 		}
-		offset++
+		offset += int64((4 + len(exp)))
+	}
+}
 
-		iv, err := a.StringAt(offset)
+func TestEncoder_StringArrayFraming(t *testing.T) {
+	b := "b"
+	sample := []struct {
+		v   interface{}
+		exp []byte
+	}{
+		{[]string{""}, []byte{0x9d, byte(StringColumn), 0, 1, 0, 0, 0, 0}},
+		{[]string{"a", "", "bc"}, []byte{
+			0x9d, byte(StringColumn), 0, 3, // ArrayColumn is -99
+			0, 0, 0, 1, 'a',
+			0, 0, 0, 0,
+			0, 0, 0, 2, 'b', 'c',
+		}},
+		// NULL elements have a length of -1 and no bytes.
+		{[]*string{&b, nil, new(string)}, []byte{
+			0x9d, byte(StringColumn), 0, 3,
+			0, 0, 0, 1, 'b',
+			0xff, 0xff, 0xff, 0xff,
+			0, 0, 0, 0,
+		}},
+		{[]sql.NullString{{}, {String: "b", Valid: true}, {Valid: true}}, []byte{
+			0x9d, byte(StringColumn), 0, 3,
+			0xff, 0xff, 0xff, 0xff,
+			0, 0, 0, 1, 'b',
+			0, 0, 0, 0,
+		}},
+	}
+	e := NewEncoder()
+	for _, v := range sample {
+		e.Reset()
+		n, err := e.Marshal(v.v)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if iv != exp {
-			t.Errorf("expected %v got %v", exp, iv)
+		if n != len(v.exp) || !bytes.Equal(e.Bytes(), v.exp) {
+			t.Errorf("%v: expected %v got %v", v.v, v.exp, e.Bytes())
 		}
-		offset += int64((4 + len(exp)))
 	}
 }
 
 func TestEncoder_FloatSLiceParam(t *testing.T) {
 	array := []float64{-459.67, 32.0, 212.0}
-	expLen := 30
+	expLen := 28
 	e := NewEncoder()
 	_, err := e.Marshal(array)
 	if err != nil {
@@ -379,6 +649,15 @@ func TestEncoder_FloatSLiceParam(t *testing.T) {
 	}
 	offset++
 
+	v, err = a.ByteAt(offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int8(v) != FloatColumn {
+		t.Errorf("expected %v got %v", FloatColumn, v)
+	}
+	offset++
+
 	i, err := a.Int16At(offset)
 	if err != nil {
 		t.Fatal(err)
@@ -388,15 +667,6 @@ func TestEncoder_FloatSLiceParam(t *testing.T) {
 	}
 	offset += 2
 	for _, exp := range array {
-		v, err = a.ByteAt(offset)
-		if err != nil {
-			t.Fatal(err)
-		}
-		if int8(v) != FloatColumn {
-			t.Errorf("expected %v got %v", FloatColumn, v)
-		}
-		offset++
-
 		iv, err := a.Float64At(offset)
 		if err != nil {
 			t.Fatal(err)
@@ -408,6 +678,144 @@ func TestEncoder_FloatSLiceParam(t *testing.T) {
 	}
 }
 
+// The array of an INTEGER[] parameter holds the element type once, it isn't
+// repeated before every element.
+func TestEncoder_IntArrayFixture(t *testing.T) {
+	e := NewEncoder()
+	n, err := e.Marshal([]int32{11, -2, math.MaxInt32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []byte{
+		0x9d, byte(IntColumn), 0, 3, // ArrayColumn is -99
+		0, 0, 0, 11,
+		0xff, 0xff, 0xff, 0xfe,
+		0x7f, 0xff, 0xff, 0xff,
+	}
+	if n != len(exp) || !bytes.Equal(e.Bytes(), exp) {
+		t.Errorf("expected %v got %v", exp, e.Bytes())
+	}
+}
+
+func TestEncoder_VarbinaryArrayParam(t *testing.T) {
+	array := [][]byte{{1, 2, 3}, {}, {4, 5}}
+	e := NewEncoder()
+	n, err := e.Marshal(array)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []byte{
+		0x9d, byte(VarBinColumn), 0, 3, // ArrayColumn is -99
+		0, 0, 0, 3, 1, 2, 3,
+		0, 0, 0, 0,
+		0, 0, 0, 2, 4, 5,
+	}
+	if n != len(exp) || !bytes.Equal(e.Bytes(), exp) {
+		t.Errorf("expected %v got %v", exp, e.Bytes())
+	}
+
+	e.Reset()
+	if _, err := e.Marshal([][]int32{{1}}); err != errUnknownParam {
+		t.Errorf("expected %v got %v", errUnknownParam, err)
+	}
+}
+
+func TestEncoder_EmptyArrayParam(t *testing.T) {
+	e := NewEncoder()
+	n, err := e.Marshal([]int32{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []byte{0x9d, byte(IntColumn), 0, 0} // ArrayColumn is -99
+	if n != len(exp) || !bytes.Equal(e.Bytes(), exp) {
+		t.Errorf("expected %v got %v", exp, e.Bytes())
+	}
+}
+
+func TestEncoder_ArrayParamErrors(t *testing.T) {
+	e := NewEncoder()
+	e.Int32(7)
+	// 1/3 has more fractional digits than a DECIMAL can hold.
+	array := []*big.Rat{big.NewRat(1, 1), big.NewRat(1, 2), big.NewRat(1, 3)}
+	if _, err := e.Marshal(array); err != errDecimalScale {
+		t.Errorf("expected %v got %v", errDecimalScale, err)
+	}
+	// the array is taken back, what was encoded before it is kept.
+	if e.Len() != IntegerSize {
+		t.Errorf("expected %d bytes got %d", IntegerSize, e.Len())
+	}
+
+	e.Reset()
+	if _, err := e.Marshal([]interface{}{int32(1), "two"}); err != errUnknownParam {
+		t.Errorf("expected %v got %v", errUnknownParam, err)
+	}
+}
+
+func TestEncoder_MarshalMap(t *testing.T) {
+	e := NewEncoder()
+	// maps are only sent once they're asked to be sent as JSON.
+	if _, err := e.Marshal(map[string]interface{}{"a": "one"}); err != errMapAsJSON {
+		t.Errorf("expected %v got %v", errMapAsJSON, err)
+	}
+	if e.Len() != 0 {
+		t.Errorf("expected nothing to be written got %d bytes", e.Len())
+	}
+
+	e.SetMapAsJSON(true)
+	n, err := e.Marshal(map[string]interface{}{"b": 2, "a": "one"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// encoding/json sorts the keys.
+	payload := `{"a":"one","b":2}`
+	exp := append([]byte{byte(StringColumn), 0, 0, 0, byte(len(payload))}, payload...)
+	if n != len(exp) || !bytes.Equal(e.Bytes(), exp) {
+		t.Errorf("expected %q got %q", exp, e.Bytes())
+	}
+
+	e.Reset()
+	if _, err := e.Marshal(map[string]interface{}(nil)); err != nil {
+		t.Fatal(err)
+	}
+	exp = []byte{byte(StringColumn), 0xff, 0xff, 0xff, 0xff}
+	if !bytes.Equal(e.Bytes(), exp) {
+		t.Errorf("expected %v got %v", exp, e.Bytes())
+	}
+
+	e.Reset()
+	if _, err := e.Marshal(map[int]string{1: "one"}); err != errMapKey {
+		t.Errorf("expected %v got %v", errMapKey, err)
+	}
+}
+
+func TestEncoder_UnsupportedParams(t *testing.T) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("marshalling panicked: %v", r)
+		}
+	}()
+	sample := []interface{}{
+		make(chan int),
+		func() {},
+		complex(1, 2),
+		(*chan int)(nil),
+		map[int]string{1: "one"},
+		[][]int32{{1}},
+		[]complex128{1},
+		NewNullValue(ArrayColumn),
+	}
+	e := NewEncoder()
+	for _, v := range sample {
+		e.Reset()
+		if _, err := e.Marshal(v); err == nil {
+			t.Errorf("%T: expected an error", v)
+		}
+		if e.Len() != 0 {
+			t.Errorf("%T: expected nothing to be written got %v", v, e.Bytes())
+		}
+	}
+}
+
 func TestEncoder_Login(t *testing.T) {
 	e := NewEncoder()
 	v, err := e.Login(1, "hello", "world")
@@ -422,3 +830,603 @@ func TestEncoder_Login(t *testing.T) {
 		t.Fatal("login message doesn't match expected contents")
 	}
 }
+
+func TestEncoder_EncodeLogin(t *testing.T) {
+	sample := []struct {
+		scheme HashScheme
+		digest []byte
+	}{
+		{HashSHA1, func() []byte { h := sha1.Sum([]byte("world")); return h[:] }()},
+		{HashSHA256, func() []byte { h := sha256.Sum256([]byte("world")); return h[:] }()},
+	}
+	e := NewEncoder()
+	for _, s := range sample {
+		e.Reset()
+		v, err := e.EncodeLogin(LoginRequest{Version: 1, Scheme: s.scheme, User: "hello", Password: "world"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// message length, protocol version and password hash version.
+		if v[4] != 1 || v[5] != byte(s.scheme) {
+			t.Errorf("scheme %d: unexpected prologue %v", s.scheme, v[:6])
+		}
+		if digest := v[len(v)-len(s.digest):]; !bytes.Equal(digest, s.digest) {
+			t.Errorf("scheme %d: expected digest %x got %x", s.scheme, s.digest, digest)
+		}
+		// prologue, service, user and digest.
+		if n := 4 + 2 + 4 + len("database") + 4 + len("hello") + len(s.digest); len(v) != n {
+			t.Errorf("scheme %d: expected %d bytes got %d", s.scheme, n, len(v))
+		}
+	}
+	e.Reset()
+	if _, err := e.EncodeLogin(LoginRequest{Version: 1, Scheme: 2}); err != errHashScheme {
+		t.Errorf("expected %v got %v", errHashScheme, err)
+	}
+}
+
+func TestEncoder_LoginService(t *testing.T) {
+	for _, version := range []int{0, 1} {
+		e := NewEncoder()
+		v, err := e.Login(version, "u", "p")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the service follows the message length, protocol version and
+		// password hash version.
+		d := NewDecoder(bytes.NewReader(v[6:]))
+		service, err := d.String()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if service != DefaultService {
+			t.Errorf("version %d: expected service %q got %q", version, DefaultService, service)
+		}
+		user, err := d.String()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user != "u" {
+			t.Errorf("version %d: expected user %q got %q", version, "u", user)
+		}
+	}
+}
+
+func TestEncoder_EncodeLoginPasswordHash(t *testing.T) {
+	sample := []struct {
+		scheme HashScheme
+		size   int
+	}{
+		{HashSHA1, sha1.Size},
+		{HashSHA256, sha256.Size},
+	}
+	e := NewEncoder()
+	for _, s := range sample {
+		hash := bytes.Repeat([]byte{0xab}, s.size)
+		e.Reset()
+		v, err := e.EncodeLogin(LoginRequest{Version: 1, Scheme: s.scheme, User: "hello", Password: "ignored", PasswordHash: hash})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if digest := v[len(v)-s.size:]; !bytes.Equal(digest, hash) {
+			t.Errorf("scheme %d: expected digest %x got %x", s.scheme, hash, digest)
+		}
+		if n := 4 + 2 + 4 + len("database") + 4 + len("hello") + s.size; len(v) != n {
+			t.Errorf("scheme %d: expected %d bytes got %d", s.scheme, n, len(v))
+		}
+		e.Reset()
+		_, err = e.EncodeLogin(LoginRequest{Version: 1, Scheme: s.scheme, User: "hello", PasswordHash: hash[1:]})
+		if err != errPasswordHashSize {
+			t.Errorf("scheme %d: expected %v got %v", s.scheme, errPasswordHashSize, err)
+		}
+		if e.Len() != 0 {
+			t.Errorf("scheme %d: expected nothing written got %d bytes", s.scheme, e.Len())
+		}
+	}
+}
+
+func TestEncoder_EncodeLoginPrologue(t *testing.T) {
+	e := NewEncoder()
+	v, err := e.EncodeLogin(LoginRequest{Version: 1, Scheme: HashSHA256, Service: "export", User: "u", Password: "p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []byte{
+		0, 0, 0, 49, // message length
+		1, // protocol version
+		1, // password hash version
+		0, 0, 0, 6, 'e', 'x', 'p', 'o', 'r', 't',
+		0, 0, 0, 1, 'u',
+	}
+	if !bytes.Equal(v[:len(exp)], exp) {
+		t.Errorf("expected prologue %v got %v", exp, v[:len(exp)])
+	}
+	if len(v) != len(exp)+sha256.Size {
+		t.Errorf("expected %d bytes got %d", len(exp)+sha256.Size, len(v))
+	}
+}
+
+func TestEncoder_MarshalDecimal(t *testing.T) {
+	sample := []struct {
+		v   interface{}
+		exp []byte
+	}{
+		{big.NewRat(3, 2), []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01, 0x5d, 0x3e, 0xf7, 0x98, 0x00}},
+		{big.NewRat(-1, 1), []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x17, 0x2b, 0x5a, 0xf0, 0x00}},
+		{*big.NewRat(0, 1), make([]byte, DecimalSize)},
+		{VoltDecimal{Unscaled: big.NewInt(15), Scale: 1}, []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01, 0x5d, 0x3e, 0xf7, 0x98, 0x00}},
+	}
+	e := NewEncoder()
+	for _, s := range sample {
+		e.Reset()
+		n, err := e.Marshal(s.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1+DecimalSize {
+			t.Errorf("expected %d got %d", 1+DecimalSize, n)
+		}
+		b := e.Bytes()
+		if int8(b[0]) != DecimalColumn {
+			t.Errorf("expected %v got %v", DecimalColumn, b[0])
+		}
+		if !bytes.Equal(b[1:], s.exp) {
+			t.Errorf("%v: expected %v got %v", s.v, s.exp, b[1:])
+		}
+
+		// decode the two's complement value and compare with the original
+		got := new(big.Int).SetBytes(b[1:])
+		if b[1]&0x80 != 0 {
+			got.Sub(got, new(big.Int).Lsh(big.NewInt(1), 128))
+		}
+		r := new(big.Rat).SetFrac(got, decimalScaleFactor)
+		var want *big.Rat
+		switch x := s.v.(type) {
+		case *big.Rat:
+			want = x
+		case big.Rat:
+			want = &x
+		case VoltDecimal:
+			want = x.Rat()
+		}
+		if r.Cmp(want) != 0 {
+			t.Errorf("expected %v got %v", want, r)
+		}
+	}
+}
+
+func TestEncoder_MarshalNullDecimal(t *testing.T) {
+	exp := append([]byte{byte(DecimalColumn), 0x80}, make([]byte, DecimalSize-1)...)
+	for _, v := range []interface{}{NullDecimal(), (*big.Rat)(nil), VoltDecimal{}} {
+		e := NewEncoder()
+		if _, err := e.Marshal(v); err != nil {
+			t.Fatalf("%T: %v", v, err)
+		}
+		if !bytes.Equal(e.Bytes(), exp) {
+			t.Errorf("%T: expected %v got %v", v, exp, e.Bytes())
+		}
+	}
+}
+
+func TestEncoder_MarshalDecimalErrors(t *testing.T) {
+	e := NewEncoder()
+	_, err := e.Marshal(big.NewRat(1, 3))
+	if err != errDecimalScale {
+		t.Errorf("expected %v got %v", errDecimalScale, err)
+	}
+
+	if e.Len() != 0 {
+		t.Errorf("expected nothing to be written got %d bytes", e.Len())
+	}
+
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(26), nil)
+	_, err = e.Marshal(new(big.Rat).SetInt(max))
+	if err != errDecimalOverflow {
+		t.Errorf("expected %v got %v", errDecimalOverflow, err)
+	}
+	if e.Len() != 0 {
+		t.Errorf("expected nothing to be written got %d bytes", e.Len())
+	}
+	_, err = e.Marshal(new(big.Rat).SetInt(max.Neg(max)))
+	if err != errDecimalOverflow {
+		t.Errorf("expected %v got %v", errDecimalOverflow, err)
+	}
+
+	// largest representable value, 26 integer digits and 12 fractional
+	largest := new(big.Int).Sub(decimalMaxUnscaled, big.NewInt(1))
+	e.Reset()
+	_, err = e.Marshal(VoltDecimal{Unscaled: largest, Scale: DecimalScale})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncoder_MarshalGeographyPoint(t *testing.T) {
+	p := GeographyPoint{Longitude: -71.0589, Latitude: 42.3601}
+	e := NewEncoder()
+	n, err := e.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1+GeographyPointSize {
+		t.Errorf("expected %d got %d", 1+GeographyPointSize, n)
+	}
+	a := NewDecoderAt(bytes.NewReader(e.Bytes()))
+	v, err := a.ByteAt(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int8(v) != GeographyPointColumn {
+		t.Errorf("expected %v got %v", GeographyPointColumn, v)
+	}
+	lng, err := a.Float64At(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lat, err := a.Float64At(9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lng != p.Longitude || lat != p.Latitude {
+		t.Errorf("expected %v got %v %v", p, lng, lat)
+	}
+
+	invalid := []struct {
+		p   GeographyPoint
+		err error
+	}{
+		{GeographyPoint{Longitude: 180.1}, errLongitude},
+		{GeographyPoint{Longitude: -180.1}, errLongitude},
+		{GeographyPoint{Latitude: 90.1}, errLatitude},
+		{GeographyPoint{Latitude: -90.1}, errLatitude},
+	}
+	for _, s := range invalid {
+		e.Reset()
+		_, err = e.Marshal(s.p)
+		if err != s.err {
+			t.Errorf("%v: expected %v got %v", s.p, s.err, err)
+		}
+		if e.Len() != 0 {
+			t.Errorf("expected nothing to be written got %d bytes", e.Len())
+		}
+	}
+}
+
+func TestEncoder_MarshalNull(t *testing.T) {
+	sample := []struct {
+		colType int8
+		exp     []byte
+	}{
+		{TinyIntColumn, []byte{0x80}},
+		{ShortColumn, []byte{0x80, 0}},
+		{IntColumn, []byte{0x80, 0, 0, 0}},
+		{LongColumn, []byte{0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{TimestampColumn, []byte{0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{FloatColumn, []byte{0xff, 0xee, 0x42, 0xd1, 0x30, 0x77, 0x3b, 0x76}},
+		{StringColumn, []byte{0xff, 0xff, 0xff, 0xff}},
+		{VarBinColumn, []byte{0xff, 0xff, 0xff, 0xff}},
+		{DecimalColumn, []byte{0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{GeographyPointColumn, []byte{0x40, 0x76, 0x80, 0, 0, 0, 0, 0, 0x40, 0x76, 0x80, 0, 0, 0, 0, 0}},
+		{GeographyColumn, []byte{0xff, 0xff, 0xff, 0xff}},
+	}
+	e := NewEncoder()
+	for _, s := range sample {
+		e.Reset()
+		_, err := e.Marshal(NewNullValue(s.colType))
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp := append([]byte{byte(s.colType)}, s.exp...)
+		if !bytes.Equal(e.Bytes(), exp) {
+			t.Errorf("%d: expected %v got %v", s.colType, exp, e.Bytes())
+		}
+	}
+	_, err := e.Marshal(NewNullValue(ArrayColumn))
+	if err != errUnknownParam {
+		t.Errorf("expected %v got %v", errUnknownParam, err)
+	}
+}
+
+func TestEncoder_MarshalSQLNull(t *testing.T) {
+	ts := time.Unix(1, 0)
+	sample := []struct {
+		v     interface{}
+		value interface{}
+		null  int8
+	}{
+		{sql.NullBool{Bool: true, Valid: true}, true, TinyIntColumn},
+		{sql.NullByte{Byte: 200, Valid: true}, int16(200), ShortColumn},
+		{sql.NullInt16{Int16: -2, Valid: true}, int16(-2), ShortColumn},
+		{sql.NullInt32{Int32: 3, Valid: true}, int32(3), IntColumn},
+		{sql.NullInt64{Int64: 1 << 40, Valid: true}, int64(1 << 40), LongColumn},
+		{sql.NullFloat64{Float64: 1.5, Valid: true}, 1.5, FloatColumn},
+		{sql.NullString{String: "hello", Valid: true}, "hello", StringColumn},
+		{sql.NullTime{Time: ts, Valid: true}, ts, TimestampColumn},
+	}
+	for _, s := range sample {
+		e := NewEncoder()
+		if _, err := e.Marshal(s.v); err != nil {
+			t.Fatal(err)
+		}
+		exp := NewEncoder()
+		exp.Marshal(s.value)
+		if !bytes.Equal(e.Bytes(), exp.Bytes()) {
+			t.Errorf("%T: expected %v got %v", s.v, exp.Bytes(), e.Bytes())
+		}
+
+		// the zero value is not valid.
+		null := reflect.Zero(reflect.TypeOf(s.v)).Interface()
+		e.Reset()
+		if _, err := e.Marshal(null); err != nil {
+			t.Fatal(err)
+		}
+		exp.Reset()
+		exp.Marshal(NewNullValue(s.null))
+		if !bytes.Equal(e.Bytes(), exp.Bytes()) {
+			t.Errorf("%T NULL: expected %v got %v", s.v, exp.Bytes(), e.Bytes())
+		}
+		if ct, err := ColumnType(reflect.TypeOf(s.v)); err != nil || ct != s.null {
+			t.Errorf("%T: expected column type %d got %d %v", s.v, s.null, ct, err)
+		}
+	}
+}
+
+func TestEncoder_MarshalFixedVarbinary(t *testing.T) {
+	e := NewEncoder()
+	n, err := e.Marshal(FixedVarbinary{Width: 3, Bytes: []byte{1, 2, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []byte{byte(VarBinColumn), 0, 0, 0, 3, 1, 2, 3}
+	if n != len(exp) || !bytes.Equal(e.Bytes(), exp) {
+		t.Errorf("expected %v got %v", exp, e.Bytes())
+	}
+
+	for _, b := range [][]byte{{1, 2}, {1, 2, 3, 4}, nil} {
+		e.Reset()
+		if _, err := e.Marshal(FixedVarbinary{Width: 3, Bytes: b}); err != errVarbinaryWidth {
+			t.Errorf("%v: expected %v got %v", b, errVarbinaryWidth, err)
+		}
+		if e.Len() != 0 {
+			t.Errorf("%v: expected nothing to be encoded got %v", b, e.Bytes())
+		}
+	}
+
+	e.Reset()
+	if _, err := e.Marshal((*FixedVarbinary)(nil)); err != nil {
+		t.Fatal(err)
+	}
+	exp = []byte{byte(VarBinColumn), 0xff, 0xff, 0xff, 0xff}
+	if !bytes.Equal(e.Bytes(), exp) {
+		t.Errorf("expected %v got %v", exp, e.Bytes())
+	}
+}
+
+func TestEncoder_MarshalStream(t *testing.T) {
+	e := NewEncoder()
+	if _, err := e.Int16(2); err != nil {
+		t.Fatal(err)
+	}
+	n, err := e.Marshal(StreamString(strings.NewReader("volt db"), 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 9 {
+		t.Errorf("expected 9 got %d", n)
+	}
+	if _, err := e.Marshal(StreamVarbinary(bytes.NewReader([]byte{1, 2}), 2)); err != nil {
+		t.Fatal(err)
+	}
+	// only the types and the lengths are buffered.
+	if e.Len() != 12 {
+		t.Errorf("expected 12 buffered bytes got %d", e.Len())
+	}
+	var buf bytes.Buffer
+	written, err := e.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []byte{0, 2, byte(StringColumn), 0, 0, 0, 4, 'v', 'o', 'l', 't', byte(VarBinColumn), 0, 0, 0, 2, 1, 2}
+	if written != int64(len(exp)) || !bytes.Equal(buf.Bytes(), exp) {
+		t.Errorf("expected %v got %v", exp, buf.Bytes())
+	}
+
+	// a reader holding fewer bytes than the length fails the write.
+	e.Reset()
+	e.Marshal(StreamVarbinary(bytes.NewReader([]byte{1}), 2))
+	if _, err := e.WriteTo(ioutil.Discard); err != io.EOF {
+		t.Errorf("expected %v got %v", io.EOF, err)
+	}
+
+	e.Reset()
+	if _, err := e.Marshal(StreamVarbinary(nil, -1)); err != errStreamLength {
+		t.Errorf("expected %v got %v", errStreamLength, err)
+	}
+	if e.Len() != 0 {
+		t.Errorf("expected nothing to be encoded got %v", e.Bytes())
+	}
+}
+
+func TestEncoder_MaxStringLen(t *testing.T) {
+	e := NewEncoder()
+	s := strings.Repeat("v", DefaultMaxStringLen)
+	if _, err := e.Marshal(s); err != nil {
+		t.Errorf("expected a string of the max length to be encoded got %v", err)
+	}
+	e.Reset()
+	exp := StringLengthError{Len: DefaultMaxStringLen + 1, Max: DefaultMaxStringLen}
+	if _, err := e.Marshal(s + "v"); err != exp {
+		t.Errorf("expected %v got %v", exp, err)
+	}
+	if e.Len() != 0 {
+		t.Errorf("expected nothing to be encoded got %d bytes", e.Len())
+	}
+
+	e.SetMaxStringLen(4)
+	over := []interface{}{
+		"voltdb",
+		[]string{"ab", "voltdb"},
+		map[string]int{"v": 1},
+		StreamString(strings.NewReader("voltdb"), 6),
+	}
+	for _, v := range over {
+		e.Reset()
+		if _, err := e.Marshal(v); err == nil {
+			t.Errorf("%v: expected an error", v)
+		}
+	}
+	e.Reset()
+	if _, err := e.Marshal("volt"); err != nil {
+		t.Error(err)
+	}
+
+	e.SetMaxStringLen(-1)
+	e.Reset()
+	if _, err := e.Marshal(s + "v"); err != nil {
+		t.Errorf("expected no limit got %v", err)
+	}
+}
+
+func TestNullValue_Constructors(t *testing.T) {
+	sample := []struct {
+		v       NullValue
+		colType int8
+	}{
+		{NullTinyInt(), TinyIntColumn},
+		{NullSmallInt(), ShortColumn},
+		{NullInteger(), IntColumn},
+		{NullBigInt(), LongColumn},
+		{NullFloat(), FloatColumn},
+		{NullString(), StringColumn},
+		{NullVarbinary(), VarBinColumn},
+		{NullTimestamp(), TimestampColumn},
+		{NullDecimal(), DecimalColumn},
+		{NullGeographyPoint(), GeographyPointColumn},
+		{NullGeography(), GeographyColumn},
+	}
+	e := NewEncoder()
+	for _, s := range sample {
+		if s.v.ColType() != s.colType {
+			t.Errorf("expected column type %d got %d", s.colType, s.v.ColType())
+		}
+		if v, err := s.v.Value(); v != nil || err != nil {
+			t.Errorf("%d: expected a nil value got %v %v", s.colType, v, err)
+		}
+		e.Reset()
+		if _, err := e.Marshal(s.v); err != nil {
+			t.Errorf("%d: %v", s.colType, err)
+			continue
+		}
+		exp := NewEncoder()
+		exp.MarshalNull(s.colType)
+		if !bytes.Equal(e.Bytes(), exp.Bytes()) {
+			t.Errorf("%d: expected %v got %v", s.colType, exp.Bytes(), e.Bytes())
+		}
+	}
+}
+
+func TestEncoder_MarshalGeography(t *testing.T) {
+	p := GeographyPolygon{
+		OuterRing: []GeographyPoint{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}},
+		InnerRings: [][]GeographyPoint{
+			{{2, 2}, {2, 8}, {8, 8}, {8, 2}, {2, 2}},
+		},
+	}
+	e := NewEncoder()
+	n, err := e.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(e.Bytes()) {
+		t.Errorf("expected %d got %d", len(e.Bytes()), n)
+	}
+	d := NewDecoder(bytes.NewReader(e.Bytes()))
+	readByte := func(expect int8) {
+		b, err := d.Byte()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b != expect {
+			t.Errorf("expected %v got %v", expect, b)
+		}
+	}
+	readInt32 := func(expect int32) {
+		i, err := d.Int32()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i != expect {
+			t.Errorf("expected %v got %v", expect, i)
+		}
+	}
+	readFloat64 := func(expect float64) {
+		f, err := d.Float64()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(f-expect) > 1e-12 {
+			t.Errorf("expected %v got %v", expect, f)
+		}
+	}
+	readBound := func() {
+		readByte(0)
+		readFloat64(1)
+		readFloat64(0)
+		readFloat64(math.Pi)
+		readFloat64(-math.Pi)
+	}
+	readByte(GeographyColumn)
+	readInt32(int32(n - 5))
+	readByte(0)
+	readByte(0)
+	readByte(0)
+	readInt32(2)
+	// closing points are dropped and the hole is reversed
+	rings := [][]GeographyPoint{
+		{{0, 0}, {10, 0}, {10, 10}, {0, 10}},
+		{{2, 2}, {8, 2}, {8, 8}, {2, 8}},
+	}
+	for _, r := range rings {
+		readByte(0)
+		readInt32(int32(len(r)))
+		for _, v := range r {
+			lat := v.Latitude * math.Pi / 180
+			lng := v.Longitude * math.Pi / 180
+			readFloat64(math.Cos(lat) * math.Cos(lng))
+			readFloat64(math.Cos(lat) * math.Sin(lng))
+			readFloat64(math.Sin(lat))
+		}
+		readByte(0)
+		readInt32(0)
+		readBound()
+	}
+	readBound()
+	if _, err := d.Byte(); err == nil {
+		t.Error("expected the end of the encoded polygon")
+	}
+
+	invalid := []struct {
+		ring []GeographyPoint
+		err  error
+	}{
+		{nil, errRingEmpty},
+		{[]GeographyPoint{}, errRingEmpty},
+		{[]GeographyPoint{{0, 0}, {1, 0}, {0, 0}}, errRingTooShort},
+		{[]GeographyPoint{{0, 0}, {1, 0}, {1, 1}, {0, 1}}, errRingNotClosed},
+		{[]GeographyPoint{{0, 0}, {181, 0}, {1, 1}, {0, 0}}, errLongitude},
+		{[]GeographyPoint{{0, 0}, {1, 0}, {1, 91}, {0, 0}}, errLatitude},
+	}
+	for _, v := range invalid {
+		e.Reset()
+		_, err := e.Marshal(GeographyPolygon{OuterRing: v.ring})
+		if err != v.err {
+			t.Errorf("%v: expected %v got %v", v.ring, v.err, err)
+		}
+		if e.Len() != 0 {
+			t.Errorf("%v: expected nothing to be encoded got %v", v.ring, e.Bytes())
+		}
+		_, err = e.Marshal(GeographyPolygon{OuterRing: p.OuterRing, InnerRings: [][]GeographyPoint{v.ring}})
+		if err != v.err {
+			t.Errorf("%v: expected %v got %v", v.ring, v.err, err)
+		}
+	}
+}